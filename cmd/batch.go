@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/leotaku/kojirou/cmd/formats"
+	"github.com/spf13/pflag"
+)
+
+var (
+	fromFileArg string
+	followedArg bool
+)
+
+// readIdentifiers reads one identifier or URL per line, ignoring empty
+// lines and lines starting with "#".  The filename "-" reads stdin.
+func readIdentifiers(filename string) ([]string, error) {
+	var r io.Reader = os.Stdin
+	if filename != "-" {
+		f, err := os.Open(filename)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	result := make([]string, 0)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		result = append(result, line)
+	}
+
+	return result, scanner.Err()
+}
+
+func runBatch(ctx context.Context, filename string) error {
+	identifiers, err := readIdentifiers(filename)
+	if err != nil {
+		return fmt.Errorf("read: %w", err)
+	} else if len(identifiers) == 0 {
+		return fmt.Errorf("no identifiers found in '%v'", filename)
+	}
+
+	return runIdentifiers(ctx, identifiers)
+}
+
+// runFollowed builds every manga followed by the logged in MangaDex
+// account.
+func runFollowed(ctx context.Context) error {
+	if _, err := loadSession(); err != nil {
+		return fmt.Errorf(`not logged in, run "kojirou login": %w`, err)
+	}
+	identifiers, err := getDownloader().MangadexFollows(ctx)
+	if err != nil {
+		return fmt.Errorf("follows: %w", err)
+	} else if len(identifiers) == 0 {
+		return fmt.Errorf("no followed manga found")
+	}
+
+	return runIdentifiers(ctx, identifiers)
+}
+
+// runIdentifiers builds every manga in turn, continuing after failures.
+// Every manga starts from the same flags, with the options configured
+// for it in the "series" table applied.
+func runIdentifiers(ctx context.Context, identifiers []string) error {
+	prefetchBatch(ctx, identifiers)
+	restore := snapshotFlags(manifestFlags)
+	err := runEach(ctx, identifiers, func(identifier string) error {
+		if err := restore(); err != nil {
+			return err
+		} else if err := applySeriesConfig(manifestFlags, identifier); err != nil {
+			return fmt.Errorf("config: %w", err)
+		} else if err := validateSeriesFlags(); err != nil {
+			return fmt.Errorf("config: %w", err)
+		} else if err := restartPlugins(ctx); err != nil {
+			return fmt.Errorf("config: plugin: %w", err)
+		}
+		identifierArg = identifier
+		return run(ctx)
+	})
+	identifierArg = ""
+	if err := restore(); err != nil {
+		return err
+	}
+
+	return err
+}
+
+// snapshotFlags records the values of all flags and returns a function
+// that restores them, so that changes made while building one manga do
+// not carry over to the next.
+func snapshotFlags(flags *pflag.FlagSet) func() error {
+	values := make(map[string]string)
+	changed := make(map[string]bool)
+	flags.VisitAll(func(f *pflag.Flag) {
+		values[f.Name] = f.Value.String()
+		changed[f.Name] = f.Changed
+	})
+
+	return func() error {
+		for name, value := range values {
+			f := flags.Lookup(name)
+			if err := f.Value.Set(value); err != nil {
+				return fmt.Errorf("flag %v: %w", name, err)
+			}
+			f.Changed = changed[name]
+		}
+		return nil
+	}
+}
+
+// runEach runs the function for every manga in turn, continuing after
+// failures, which are summarized in the returned error.
+func runEach(ctx context.Context, names []string, f func(name string) error) error {
+	failed, partial := 0, 0
+	for _, name := range names {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := f(name); isPartial(err) {
+			partial++
+		} else if err != nil {
+			formats.PrintResultError(name, err)
+			formats.Report("Failed", "%v: %v", name, err)
+			failed++
+		}
+	}
+	switch {
+	case failed == len(names):
+		return fmt.Errorf("%v of %v manga failed", failed, len(names))
+	case failed > 0 || partial > 0:
+		message := fmt.Sprintf("%v of %v manga failed, %v partially", failed+partial, len(names), partial)
+		return &partialError{message: message}
+	default:
+		return nil
+	}
+}
+
+// prefetchBatch requests the metadata and covers of all manga in the
+// batch at once, instead of a few requests for every manga.  Failures
+// are not fatal, as every run requests missing metadata again.
+func prefetchBatch(ctx context.Context, identifiers []string) {
+	mangaIDs := make([]string, 0)
+	for _, identifier := range identifiers {
+		if _, err := os.Stat(identifier); err == nil {
+			continue
+		} else if mangaID, ok := mangadexID(identifier); ok {
+			mangaIDs = append(mangaIDs, mangaID)
+		}
+	}
+	if len(mangaIDs) < 2 {
+		return
+	}
+
+	if err := getDownloader().PrefetchMangadex(ctx, mangaIDs...); err != nil {
+		formats.Debug("Prefetching metadata failed", "error", err)
+	} else {
+		formats.Debug("Prefetched metadata", "manga", len(mangaIDs))
+	}
+}
@@ -1,175 +1,512 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"image"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
 
+	"github.com/leotaku/kojirou/cmd/cache"
 	"github.com/leotaku/kojirou/cmd/crop"
 	"github.com/leotaku/kojirou/cmd/filter"
 	"github.com/leotaku/kojirou/cmd/formats"
 	"github.com/leotaku/kojirou/cmd/formats/disk"
 	"github.com/leotaku/kojirou/cmd/formats/download"
 	"github.com/leotaku/kojirou/cmd/formats/kindle"
+	"github.com/leotaku/kojirou/cmd/pipeline"
+	"github.com/leotaku/kojirou/codec"
 	md "github.com/leotaku/kojirou/mangadex"
-	"golang.org/x/text/language"
+	mu "github.com/leotaku/kojirou/mangaupdates"
+	"golang.org/x/sync/errgroup"
 )
 
-func run() error {
-	manga, err := download.MangadexSkeleton(identifierArg)
+func run(ctx context.Context) error {
+	p := formats.VanishingProgress("Metadata")
+	sources, err := getProviders(ctx)
 	if err != nil {
+		p.Cancel("Error")
+		return err
+	}
+	manga, err := getSkeleton(ctx, sources)
+	if err != nil {
+		p.Cancel("Error")
 		return fmt.Errorf("skeleton: %w", err)
 	}
+	p.Done()
 
-	chapters, err := getChapters(*manga)
+	if mangaupdatesArg {
+		if err := enrichMangaUpdates(ctx, manga); err != nil {
+			formats.Report("Metadata", "MangaUpdates: %v", err)
+		}
+	}
+	applyOverrides(manga)
+	activeManga = manga.Info
+
+	chapters, err := getChapters(ctx, sources, *manga)
 	if err != nil {
 		return fmt.Errorf("chapters: %w", err)
 	}
-	*manga = manga.WithChapters(chapters)
+	*manga = manga.WithChapters(restrictToPlan(chapters))
+
+	if interactiveArg {
+		selected, err := selectChapters(*manga, os.Stdin, os.Stdout)
+		if err != nil {
+			return fmt.Errorf("select: %w", err)
+		}
+		*manga = manga.WithChapters(selected)
+	}
+
+	if jsonArg {
+		if err := formats.PrintJSON(formats.Summarize(manga)); err != nil {
+			return fmt.Errorf("summary: %w", err)
+		}
+	} else {
+		formats.PrintSummary(manga)
+	}
 
-	formats.PrintSummary(manga)
+	dir := seriesDirectory(manga.Info.Title)
+	plan, err := planRun(*manga, dir)
+	if err != nil {
+		return err
+	}
+	if savePlanArg != "" {
+		if err := savePlan(plan, savePlanArg); err != nil {
+			return fmt.Errorf("plan: %w", err)
+		}
+	}
 	if dryRunArg {
+		if !jsonArg && savePlanArg != "-" {
+			printPlan(plan)
+		}
 		return nil
 	}
 
-	covers, err := getCovers(manga)
+	unlock, err := lockDirectory(dir.Directory())
 	if err != nil {
-		return fmt.Errorf("covers: %w", err)
+		return fmt.Errorf("lock: %w", err)
 	}
-	*manga = manga.WithCovers(covers)
+	defer unlock()
 
-	dir := kindle.NewNormalizedDirectory(outArg, manga.Info.Title, kindleFolderModeArg)
-	for _, volume := range manga.Sorted() {
-		if err := handleVolume(*manga, volume, dir); err != nil {
-			return fmt.Errorf("volume %v: %w", volume.Info.Identifier, err)
-		}
+	results, err := handleVolumes(ctx, sources, *manga, plan, dir)
+	if results != nil && !jsonArg && (err == nil || isPartial(err)) {
+		formats.PrintResults(identifierArg, dir.Directory(), results)
+	}
+
+	return err
+}
+
+func enrichMangaUpdates(ctx context.Context, manga *md.Manga) error {
+	series, err := mu.NewClient().WithHTTPClient(getDownloader().HTTPClient()).FetchSeries(ctx, manga.Info.Links["mu"], manga.Info.Title)
+	if err != nil {
+		return err
+	}
+
+	if publisher := series.Publisher("English"); publisher != "" {
+		manga.Info.Publisher = publisher
+	} else {
+		manga.Info.Publisher = series.Publisher("Original")
+	}
+	if series.Licensed {
+		formats.Report("Metadata", "Licensed in English by %v (%v volumes)", series.Publisher("English"), series.EnglishVolumes())
 	}
 
 	return nil
 }
 
-func handleVolume(skeleton md.Manga, volume md.Volume, dir kindle.NormalizedDirectory) error {
+// reportMissing records chapters that did not contain any pages and
+// volumes without a cover.
+func reportMissing(volume md.Volume, pages md.ImageList) {
+	counts := make(map[md.Identifier]int)
+	for _, page := range pages {
+		counts[page.ChapterIdentifier]++
+	}
+	for _, chapter := range volume.Sorted() {
+		if counts[chapter.Info.Identifier] == 0 {
+			formats.Report("Pages", "Chapter %v has no pages", chapter.Info.Identifier)
+		}
+	}
+	if volume.Cover == nil {
+		formats.Report("Metadata", "Volume %v has no cover", volume.Info.Identifier)
+	}
+}
+
+// applyOverrides replaces metadata with values given on the command
+// line, for cases where the original metadata is wrong or unwanted.
+func applyOverrides(manga *md.Manga) {
+	if setTitleArg != "" {
+		manga.Info.Title = setTitleArg
+	}
+	if setAuthorsArg != "" {
+		authors := make([]string, 0)
+		for _, author := range strings.Split(setAuthorsArg, ",") {
+			if author = strings.TrimSpace(author); author != "" {
+				authors = append(authors, author)
+			}
+		}
+		manga.Info.Authors = authors
+	}
+	if setSeriesArg != "" {
+		manga.Info.Series = setSeriesArg
+	}
+}
+
+// seriesName returns the name used for the output directory, which
+// defaults to the title of the manga.
+func seriesName(title string) string {
+	if setSeriesArg != "" {
+		return setSeriesArg
+	}
+
+	return title
+}
+
+// seriesDirectory returns the output directory for the manga.  If the
+// directory is named after the series, an existing directory whose name
+// only differs in case is reused if it belongs to the same manga.
+func seriesDirectory(title string) kindle.NormalizedDirectory {
+	if manifestDirectory != "" {
+		return kindle.ExistingDirectory(manifestDirectory, kindleFolderModeArg)
+	}
+
+	dir := kindle.NewNormalizedDirectory(outArg, seriesName(title), kindleFolderModeArg, sanitizer())
+	if outArg != "" && !kindleFolderModeArg {
+		return dir
+	}
+
+	dir.AvoidCollisions(func(directory string) bool {
+		m, err := readManifest(directory)
+		return err == nil && (m.Identifier == "" || sameIdentifier(m.Identifier, manifestIdentifier()))
+	})
+
+	return dir
+}
+
+// handleVolumes builds all volumes in order.  Pages are fetched for
+// one volume at a time, while earlier volumes are processed and written
+// in the background.  Every volume waits for a free worker before its
+// pages are fetched, so that the pages of at most one volume more than
+// there are workers are kept in memory.  A volume that fails does not
+// stop the others, instead it is marked as failed in the results.
+func handleVolumes(ctx context.Context, sources []formats.Provider, manga md.Manga, plan pipeline.Plan, dir kindle.NormalizedDirectory) ([]formats.VolumeResult, error) {
+	volumes := manga.Sorted()
+	results := make([]formats.VolumeResult, len(volumes))
+	failures := make([]error, len(volumes))
+	workers := make(chan struct{}, buildWorkers()+1)
+	eg, groupCtx := errgroup.WithContext(ctx)
+
+	for i, volume := range volumes {
+		i, volume := i, volume
+		workers <- struct{}{}
+		build, err := handleVolume(groupCtx, sources, manga, volume, plan.Volumes[i], dir, i < len(volumes)-1, &results[i])
+		if err != nil {
+			<-workers
+			if groupCtx.Err() != nil {
+				break
+			}
+			failures[i] = failVolume(dir.Directory(), manga, volume, &results[i], err)
+			continue
+		}
+		eg.Go(func() error {
+			defer func() { <-workers }()
+			if err := build(); err != nil && groupCtx.Err() != nil {
+				return fmt.Errorf("volume %v: %w", volume.Info.Identifier, err)
+			} else if err != nil {
+				failures[i] = failVolume(dir.Directory(), manga, volume, &results[i], err)
+			}
+			return nil
+		})
+	}
+
+	// Only interruptions stop all volumes, in which case no results
+	// are reported.
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	} else if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	errs := make([]error, 0)
+	for _, err := range failures {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return results, volumesError(errs, len(volumes))
+}
+
+// buildWorkers returns how many volumes may be processed and written
+// at once, which bounds how many volumes are held in memory.  Unless
+// given by "--volume-jobs", only one volume is built at a time when
+// memory is limited.
+func buildWorkers() int {
+	if volumeJobsArg > 0 {
+		return volumeJobsArg
+	} else if memoryLimit > 0 {
+		return 1
+	}
+
+	return runtime.NumCPU()
+}
+
+// handleVolume fetches the pages of the volume and returns a function
+// that builds the volume from them, which sets the result once it has
+// finished.  Volumes in the background do not draw progress bars while
+// they are built.
+func handleVolume(ctx context.Context, sources []formats.Provider, skeleton md.Manga, volume md.Volume, plan pipeline.VolumePlan, dir kindle.NormalizedDirectory, background bool, result *formats.VolumeResult) (func() error, error) {
+	writers, err := newWriters()
+	if err != nil {
+		return nil, err
+	}
+	filename := dir.Filename(volume.Info.Identifier, writers[0].Extension())
+	*result = formats.VolumeResult{
+		Filename: filename,
+		Volume:   volume.Info.Identifier.String(),
+		Chapters: len(volume.Chapters),
+		Status:   formats.VolumeNew,
+	}
+
 	p := formats.TitledProgress(fmt.Sprintf("Volume: %v", volume.Info.Identifier))
-	if dir.Has(volume.Info.Identifier) && !forceArg {
+	if plan.Action == pipeline.ActionSkip {
 		p.Cancel("Skipped")
-		return nil
+		formats.Report("Skipped", "Volume %v already exists, use --force to overwrite", volume.Info.Identifier)
+		for _, chapter := range volume.Chapters {
+			result.Pages += chapter.Info.Pages
+		}
+		result.Status = formats.VolumeSkipped
+		result.Size = fileSize(filepath.Join(dir.Directory(), filename))
+		return func() error { return nil }, nil
+	} else if plan.Action == pipeline.ActionRebuild {
+		result.Status = formats.VolumeRebuilt
 	}
 
-	pages, err := getPages(volume, p)
-	if err != nil {
-		return fmt.Errorf("pages: %w", err)
+	fetch, build := volumePipeline(sources, dir, writers).Split(pipeline.Fetch)
+	job := &pipeline.Job{Manga: skeleton, Volume: volume, Plan: plan, Progress: p, Background: background}
+	if err := fetch.Run(ctx, job); err != nil {
+		return nil, err
 	}
 
-	if autocropArg {
-		if err := autoCrop(pages); err != nil {
-			return fmt.Errorf("autocrop: %w", err)
+	return func() error {
+		if err := build.Run(ctx, job); err != nil {
+			return err
+		}
+		result.Pages = len(job.Pages)
+		result.Size = fileSize(filepath.Join(dir.Directory(), filename))
+
+		return nil
+	}, nil
+}
+
+// hasVolume reports whether the volume exists in every output format.
+func hasVolume(dir kindle.NormalizedDirectory, identifier md.Identifier, writers []formats.FormatWriter) bool {
+	for _, w := range writers {
+		if !dir.Has(identifier, w.Extension()) {
+			return false
 		}
 	}
 
-	mangaForVolume := skeleton.WithChapters(volume.Sorted()).WithPages(pages)
-	mobi := kindle.GenerateMOBI(mangaForVolume)
-	mobi.RightToLeft = !leftToRightArg
-	mobi.Title = fmt.Sprintf("%v: %v",
-		skeleton.Info.Title,
-		volume.Info.Identifier.StringFilled(fillVolumeNumberArg, 0, false),
-	)
+	return true
+}
 
-	p = formats.VanishingProgress("Writing...")
-	if err := dir.Write(volume.Info.Identifier, mobi, p); err != nil {
-		p.Cancel("Error")
-		return fmt.Errorf("write: %w", err)
+func fileSize(pathname string) int64 {
+	if info, err := os.Stat(pathname); err == nil {
+		return info.Size()
 	}
-	p.Done()
 
-	return nil
+	return 0
 }
 
-func getChapters(manga md.Manga) (md.ChapterList, error) {
-	chapters, err := download.MangadexChapters(identifierArg)
-	if err != nil {
-		return nil, fmt.Errorf("mangadex: %w", err)
+// HTTPMiddleware wraps every attempt of every request, after the
+// default retrying, rate limiting and metrics.  Programs that embed
+// Kojirou may add middleware here before calling Execute, for example
+// to inject headers or trace requests.
+var HTTPMiddleware []download.Middleware
+
+var (
+	downloader *download.Downloader
+	pageCache  cache.Cache
+)
+
+// getDownloader returns the downloader shared by all commands, which is
+// only created once it is needed.
+func getDownloader() *download.Downloader {
+	if downloader == nil {
+		options := download.DefaultTransportOptions()
+		options.MaxConnsPerHost = connectionsArg
+		options.DisableHTTP2 = http1Arg
+		client := &http.Client{Transport: download.NewTransport(options)}
+		middleware := download.DefaultMiddleware(download.DefaultRetryOptions(), nil)
+		downloader = download.NewDownloaderWith(client, append(middleware, HTTPMiddleware...)...)
+		downloader.WithPageRetries(pageRetriesArg).WithDataSaver(dataSaverArg)
+		downloader.WithOptions(download.Options{ChapterJobs: chapterJobsArg, ImageJobs: imageJobsArg})
+		if pageCache != nil {
+			downloader.WithCache(pageCache)
+		}
+		if memoryLimit > 0 {
+			downloader.WithMemoryBudget(download.NewMemoryBudget(memoryLimit))
+		}
+		useSession(downloader)
 	}
 
-	if diskArg != "" {
-		p := formats.VanishingProgress("Disk...")
-		diskChapters, err := disk.LoadChapters(diskArg, language.Make(languageArg), p)
+	return downloader
+}
+
+// getProviders returns all sources for the current identifier, with
+// the source of the manga metadata first.
+func getProviders(ctx context.Context) ([]formats.Provider, error) {
+	sources := make([]formats.Provider, 0)
+	if !isLocal() {
+		source, err := pluginSource(ctx, identifierArg)
+		if err != nil {
+			return nil, fmt.Errorf("plugin: %w", err)
+		} else if source == nil {
+			mangaID, err := resolveIdentifier(ctx, identifierArg)
+			if err != nil {
+				return nil, fmt.Errorf("identifier: %w", err)
+			}
+			source = download.NewMangadexProvider(getDownloader(), mangaID)
+		}
+		sources = append(sources, source)
+	}
+	for _, directory := range diskDirectories() {
+		sources = append(sources, disk.NewProvider(directory, primaryLanguage()))
+	}
+
+	return sources, nil
+}
+
+func getSkeleton(ctx context.Context, sources []formats.Provider) (*md.Manga, error) {
+	return sources[0].FetchSeries(ctx)
+}
+
+func getChapters(ctx context.Context, sources []formats.Provider, manga md.Manga) (md.ChapterList, error) {
+	chapters := make(md.ChapterList, 0)
+	for _, source := range sources {
+		p := formats.VanishingProgress(fmt.Sprintf("Chapters: %v", source.Name()))
+		sourceChapters, err := source.FetchChapters(ctx, manga, p)
 		if err != nil {
 			p.Cancel("Error")
-			return nil, fmt.Errorf("disk: %w", err)
+			return nil, fmt.Errorf("%v: %w", strings.ToLower(source.Name()), err)
 		}
 		p.Done()
-		chapters = append(chapters, diskChapters...)
+		chapters = append(chapters, sourceChapters...)
 	}
 
-	chapters, err = sortFromFlags(chapters)
+	chapters, err := sortFromFlags(chapters)
 	if err != nil {
 		return nil, fmt.Errorf("filter: %w", err)
 	}
+	if chapters, err = disambiguate(chapters); err != nil {
+		return nil, fmt.Errorf("group: %w", err)
+	}
 
 	// Ensure chapters from disk are preferred
-	if diskArg != "" {
+	if len(diskDirectories()) > 0 {
 		chapters.SortBy(func(a md.ChapterInfo, b md.ChapterInfo) bool {
 			return a.GroupNames.String() == "Filesystem" && b.GroupNames.String() != "Filesystem"
 		})
 	}
 
+	if chapters, err = assignNoVolume(chapters); err != nil {
+		return nil, fmt.Errorf("no-volume: %w", err)
+	}
+
 	return filter.RemoveDuplicates(chapters), nil
 }
 
-func getCovers(manga *md.Manga) (md.ImageList, error) {
-	p := formats.VanishingProgress("Covers")
-	covers, err := download.MangadexCovers(manga, p)
-	if err != nil {
-		p.Cancel("Error")
-		return nil, fmt.Errorf("mangadex: %w", err)
-	}
-	p.Done()
-
-	// Covers from disk should automatically be preferred, because
-	// they appear later in the list and thus should override the
-	// earlier downloaded covers.
-	if diskArg != "" {
-		p := formats.VanishingProgress("Disk...")
-		diskCovers, err := disk.LoadCovers(diskArg, p)
+// getCovers collects covers from all sources.  Covers from later
+// sources, such as the disk, automatically override earlier covers.
+func getCovers(ctx context.Context, sources []formats.Provider, manga md.Manga) (md.ImageList, error) {
+	covers := make(md.ImageList, 0)
+	for _, source := range sources {
+		p := formats.VanishingProgress(fmt.Sprintf("Covers: %v", source.Name()))
+		sourceCovers, err := source.FetchCovers(ctx, manga, p)
 		if err != nil {
 			p.Cancel("Error")
-			return nil, fmt.Errorf("disk: %w", err)
+			return nil, fmt.Errorf("%v: %w", strings.ToLower(source.Name()), err)
 		}
 		p.Done()
-		covers = append(covers, diskCovers...)
+		covers = append(covers, sourceCovers...)
 	}
 
 	return covers, nil
 }
 
-func getPages(volume md.Volume, p formats.CliProgress) (md.ImageList, error) {
-	mangadexPages, err := download.MangadexPages(volume.Sorted().FilterBy(func(ci md.ChapterInfo) bool {
-		return ci.GroupNames.String() != "Filesystem"
-	}), p)
-	if err != nil {
-		p.Cancel("Error")
-		return nil, fmt.Errorf("mangadex: %w", err)
-	}
-	diskPages, err := disk.LoadPages(volume.Sorted().FilterBy(func(ci md.ChapterInfo) bool {
-		return ci.GroupNames.String() == "Filesystem"
-	}), p)
-	if err != nil {
-		p.Cancel("Error")
-		return nil, fmt.Errorf("disk: %w", err)
+// getPages fetches the pages of every chapter from the source that
+// provided the chapter.
+func getPages(ctx context.Context, sources []formats.Provider, volume md.Volume, p formats.CliProgress) (md.ImageList, error) {
+	pages := make(md.ImageList, 0)
+	handled := make(map[string]bool)
+	for _, source := range sources {
+		name := source.Name()
+		if handled[name] {
+			continue
+		}
+		handled[name] = true
+		if len(sources) > 1 {
+			p.SetStage(name)
+		}
+
+		sourcePages, err := source.FetchPages(ctx, volume.Sorted().FilterBy(func(ci md.ChapterInfo) bool {
+			return ci.Source == name
+		}), p)
+		if err != nil && (strictArg || !errors.Is(err, md.ErrChapterUnavailable)) {
+			p.Cancel("Error")
+			return nil, fmt.Errorf("%v: %w", strings.ToLower(name), err)
+		} else if err != nil {
+			reportUnavailable(err)
+		}
+		pages = append(pages, sourcePages...)
 	}
 	p.Done()
 
-	return append(mangadexPages, diskPages...), nil
+	return pages, nil
 }
 
-func autoCrop(pages md.ImageList) error {
-	p := formats.VanishingProgress("Cropping..")
+// reportUnavailable reports every chapter that is left out of a volume
+// because its pages could not be downloaded.
+func reportUnavailable(err error) {
+	errs := []error{err}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = joined.Unwrap()
+	}
+	for _, err := range errs {
+		var unavailable *md.ChapterUnavailableError
+		if errors.As(err, &unavailable) {
+			formats.Report("Pages", "Chapter %v left out: %v", unavailable.Chapter, unavailable.Err)
+		} else {
+			formats.Report("Pages", "%v", err)
+		}
+	}
+}
+
+func autoCrop(pages md.ImageList, p formats.CliProgress) error {
+	return filterPages(pages, p, func(img image.Image) (image.Image, error) {
+		decoded, err := codec.Decoded(img)
+		if err != nil {
+			return nil, fmt.Errorf("decode: %w", err)
+		}
+		return crop.Crop(img, crop.Limited(decoded, 0.1))
+	})
+}
+
+// filterPages replaces every page with the result of the filter, which
+// may return the page itself if it does not need to be changed.
+func filterPages(pages md.ImageList, p formats.CliProgress, filter func(image.Image) (image.Image, error)) error {
 	p.Increase(len(pages))
 
 	for i, page := range pages {
-		if cropped, err := crop.Crop(pages[i].Image, crop.Limited(pages[i].Image, 0.1)); err != nil {
+		if filtered, err := filter(page.Image); err != nil {
 			p.Cancel("Error")
 			return fmt.Errorf("chapter %v: page %v: %w", page.ChapterIdentifier, page.ImageIdentifier, err)
 		} else {
-			pages[i].Image = cropped
+			pages[i].Image = filtered
 			p.Add(1)
 		}
 	}
@@ -178,22 +515,52 @@ func autoCrop(pages md.ImageList) error {
 	return nil
 }
 
+// isLocal reports whether the identifier refers to a directory on disk
+// instead of a manga on MangaDex.
+func isLocal() bool {
+	info, err := os.Stat(identifierArg)
+	return err == nil && info.IsDir()
+}
+
+func diskDirectories() []string {
+	result := make([]string, 0)
+	if isLocal() {
+		result = append(result, identifierArg)
+	}
+	if diskArg != "" {
+		result = append(result, diskArg)
+	}
+
+	return result
+}
+
 func sortFromFlags(cl md.ChapterList) (md.ChapterList, error) {
-	if languageArg != "" {
-		lang := language.Make(languageArg)
-		cl = filter.FilterByLanguage(cl, lang)
+	all, available := cl, cl
+	langs := filter.MatchLanguages(all, chapterLanguages())
+	if len(langs) > 0 {
+		available = filter.SelectLanguages(cl, langs)
 	}
-	if groupsFilter != "" {
-		cl = filter.FilterByRegex(cl, "GroupNames", groupsFilter)
+	cl, err := filterFromFlags(available)
+	if err != nil {
+		return nil, err
 	}
-	if volumesFilter != "" {
-		ranges := filter.ParseRanges(volumesFilter)
-		cl = filter.FilterByIdentifier(cl, "VolumeIdentifier", ranges)
+	if len(langs) > 1 {
+		reportFallback(cl.FilterBy(func(ci md.ChapterInfo) bool {
+			return ci.Language != langs[0]
+		}))
 	}
-	if chaptersFilter != "" {
-		ranges := filter.ParseRanges(chaptersFilter)
-		cl = filter.FilterByIdentifier(cl, "Identifier", ranges)
+	gaps := filter.FindGaps(available)
+	if langs := fallbackLanguages(); len(langs) > 0 && len(gaps) > 0 {
+		langs = filter.MatchLanguages(all, langs)
+		fallback, err := filterFromFlags(filter.FillGaps(all, gaps, langs))
+		if err != nil {
+			return nil, err
+		}
+		reportFallback(fallback)
+		cl = append(cl, fallback...)
+		gaps = filter.FindGaps(append(fallback, available...))
 	}
+	reportGaps(cl, gaps)
 
 	switch rankArg {
 	case "newest":
@@ -210,5 +577,46 @@ func sortFromFlags(cl md.ChapterList) (md.ChapterList, error) {
 		return nil, fmt.Errorf(`not a valid rankinging algorithm: "%v"`, rankArg)
 	}
 
+	return preferGroup(cl), nil
+}
+
+// filterFromFlags applies all chapter filters except for the language.
+func filterFromFlags(cl md.ChapterList) (md.ChapterList, error) {
+	if groupsFilter != "" {
+		cl = filter.FilterByRegex(cl, "GroupNames", groupsFilter)
+	}
+	if volumesFilter != "" {
+		ranges := filter.ParseRanges(volumesFilter)
+		cl = filter.FilterByIdentifier(cl, "VolumeIdentifier", ranges)
+	}
+	if chaptersFilter != "" {
+		ranges := filter.ParseRanges(chaptersFilter)
+		cl = filter.FilterByIdentifier(cl, "Identifier", ranges)
+	}
+	if sinceFilter != "" || untilFilter != "" {
+		var since, until time.Time
+		var err error
+		if sinceFilter != "" {
+			if since, err = filter.ParseTime(sinceFilter, time.Now(), false); err != nil {
+				return nil, fmt.Errorf("since: %w", err)
+			}
+		}
+		if untilFilter != "" {
+			if until, err = filter.ParseTime(untilFilter, time.Now(), true); err != nil {
+				return nil, fmt.Errorf("until: %w", err)
+			}
+		}
+		cl = filter.FilterByReadable(cl, since, until)
+	}
+	if expressionFilter != "" {
+		expr, err := filter.ParseExpression(expressionFilter)
+		if err != nil {
+			return nil, fmt.Errorf("expression: %w", err)
+		}
+		if cl, err = filter.FilterByExpression(cl, expr); err != nil {
+			return nil, fmt.Errorf("expression: %w", err)
+		}
+	}
+
 	return cl, nil
 }
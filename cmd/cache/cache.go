@@ -0,0 +1,71 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned by Get and Stat for keys that are not
+// cached.
+var ErrNotFound = errors.New("not found in cache")
+
+// Info describes a cached entry.
+type Info struct {
+	Size     int64
+	Modified time.Time
+}
+
+// Cache stores immutable content such as downloaded pages.  Keys are
+// slash-separated paths, and implementations must be safe for
+// concurrent use.
+type Cache interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Put(ctx context.Context, key string, data []byte) error
+	Stat(ctx context.Context, key string) (Info, error)
+}
+
+// Open returns the cache described by spec, which is either "memory",
+// "user" for a directory in the cache directory of the user, an URL of
+// the form "s3://bucket/prefix" or the path to a directory.
+// Options for S3 are given as query parameters "endpoint" and "region",
+// while credentials are read from the usual AWS environment variables.
+func Open(spec string) (Cache, error) {
+	switch {
+	case spec == "memory":
+		return NewMemory(), nil
+	case spec == "user":
+		directory, err := os.UserCacheDir()
+		if err != nil {
+			return nil, err
+		}
+		return NewDisk(filepath.Join(directory, "kojirou")), nil
+	case strings.HasPrefix(spec, "s3://"):
+		u, err := url.Parse(spec)
+		if err != nil {
+			return nil, err
+		}
+		options := S3Options{
+			Endpoint:     u.Query().Get("endpoint"),
+			Region:       u.Query().Get("region"),
+			Bucket:       u.Host,
+			Prefix:       strings.TrimPrefix(u.Path, "/"),
+			AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		}
+		if options.Region == "" {
+			options.Region = os.Getenv("AWS_REGION")
+		}
+		return NewS3(options)
+	case spec == "":
+		return nil, fmt.Errorf("no cache given")
+	default:
+		return NewDisk(spec), nil
+	}
+}
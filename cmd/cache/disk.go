@@ -0,0 +1,124 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Disk stores every entry as a file in a directory.  File names are
+// derived from a hash of the key, so keys may contain any character.
+type Disk struct {
+	directory string
+}
+
+func NewDisk(directory string) *Disk {
+	return &Disk{directory: directory}
+}
+
+func (d *Disk) pathname(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	name := hex.EncodeToString(sum[:])
+	return filepath.Join(d.directory, name[:2], name)
+}
+
+// Get also updates the modification time of the entry, so that Prune
+// removes entries that have not been used for the longest time first.
+func (d *Disk) Get(ctx context.Context, key string) ([]byte, error) {
+	pathname := d.pathname(key)
+	data, err := os.ReadFile(pathname)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	} else if err == nil {
+		now := time.Now()
+		_ = os.Chtimes(pathname, now, now)
+	}
+
+	return data, err
+}
+
+// Put writes the entry to a temporary file first, so that concurrent
+// readers never see partial entries.
+func (d *Disk) Put(ctx context.Context, key string, data []byte) error {
+	pathname := d.pathname(key)
+	if err := os.MkdirAll(filepath.Dir(pathname), os.ModePerm); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(pathname), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	} else if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), pathname)
+}
+
+func (d *Disk) Stat(ctx context.Context, key string) (Info, error) {
+	info, err := os.Stat(d.pathname(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return Info{}, ErrNotFound
+	} else if err != nil {
+		return Info{}, err
+	}
+
+	return Info{Size: info.Size(), Modified: info.ModTime()}, nil
+}
+
+// Prune removes the least recently used entries until all entries
+// together are no larger than the given size.
+func (d *Disk) Prune(ctx context.Context, maxSize int64) error {
+	type entry struct {
+		pathname string
+		size     int64
+		modified time.Time
+	}
+
+	entries := make([]entry, 0)
+	total := int64(0)
+	err := filepath.WalkDir(d.directory, func(pathname string, de fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		} else if err != nil {
+			return err
+		} else if de.IsDir() {
+			return ctx.Err()
+		}
+		info, err := de.Info()
+		if err != nil {
+			return nil
+		}
+		entries = append(entries, entry{pathname, info.Size(), info.ModTime()})
+		total += info.Size()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].modified.Before(entries[j].modified)
+	})
+	for _, e := range entries {
+		if total <= maxSize {
+			break
+		} else if err := os.Remove(e.pathname); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		total -= e.size
+	}
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory keeps all entries in memory until the program exits.
+type Memory struct {
+	mutex   sync.RWMutex
+	entries map[string]memoryEntry
+}
+
+type memoryEntry struct {
+	data     []byte
+	modified time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{entries: make(map[string]memoryEntry)}
+}
+
+func (m *Memory) Get(ctx context.Context, key string) ([]byte, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	if entry, ok := m.entries[key]; ok {
+		return entry.data, nil
+	}
+
+	return nil, ErrNotFound
+}
+
+func (m *Memory) Put(ctx context.Context, key string, data []byte) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.entries[key] = memoryEntry{data: append([]byte(nil), data...), modified: time.Now()}
+
+	return nil
+}
+
+func (m *Memory) Stat(ctx context.Context, key string) (Info, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	if entry, ok := m.entries[key]; ok {
+		return Info{Size: int64(len(entry.data)), Modified: entry.modified}, nil
+	}
+
+	return Info{}, ErrNotFound
+}
@@ -0,0 +1,190 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3Options configure access to a bucket of an S3-compatible object
+// storage.  The endpoint defaults to AWS and the region to us-east-1.
+type S3Options struct {
+	Endpoint     string
+	Region       string
+	Bucket       string
+	Prefix       string
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+	Client       *http.Client
+}
+
+// S3 stores entries as objects in a bucket, so that a cache can be
+// shared by multiple machines.  Requests use path-style addressing,
+// which is supported by most S3-compatible services.
+type S3 struct {
+	options S3Options
+}
+
+func NewS3(options S3Options) (*S3, error) {
+	if options.Bucket == "" {
+		return nil, fmt.Errorf("s3: no bucket given")
+	} else if options.AccessKey == "" || options.SecretKey == "" {
+		return nil, fmt.Errorf("s3: no credentials given")
+	}
+	if options.Region == "" {
+		options.Region = "us-east-1"
+	}
+	if options.Endpoint == "" {
+		options.Endpoint = fmt.Sprintf("https://s3.%v.amazonaws.com", options.Region)
+	}
+	if options.Client == nil {
+		options.Client = http.DefaultClient
+	}
+	options.Endpoint = strings.TrimSuffix(options.Endpoint, "/")
+	options.Prefix = strings.Trim(options.Prefix, "/")
+
+	return &S3{options: options}, nil
+}
+
+func (s *S3) Get(ctx context.Context, key string) ([]byte, error) {
+	resp, err := s.do(ctx, "GET", key, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return io.ReadAll(resp.Body)
+}
+
+func (s *S3) Put(ctx context.Context, key string, data []byte) error {
+	resp, err := s.do(ctx, "PUT", key, data)
+	if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
+
+func (s *S3) Stat(ctx context.Context, key string) (Info, error) {
+	resp, err := s.do(ctx, "HEAD", key, nil)
+	if err != nil {
+		return Info{}, err
+	}
+	defer resp.Body.Close()
+
+	size, _ := strconv.ParseInt(resp.Header.Get("Content-Length"), 10, 64)
+	modified, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+
+	return Info{Size: size, Modified: modified}, nil
+}
+
+func (s *S3) do(ctx context.Context, method, key string, body []byte) (*http.Response, error) {
+	pathname := "/" + s.options.Bucket + "/" + key
+	if s.options.Prefix != "" {
+		pathname = "/" + s.options.Bucket + "/" + s.options.Prefix + "/" + key
+	}
+	req, err := http.NewRequestWithContext(ctx, method, s.options.Endpoint+uriEncode(pathname), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("prepare: %w", err)
+	}
+	s.sign(req, body, time.Now().UTC())
+
+	resp, err := s.options.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("do: %w", err)
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		resp.Body.Close()
+		return nil, ErrNotFound
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		resp.Body.Close()
+		return nil, fmt.Errorf("s3: status: %v", resp.Status)
+	}
+
+	return resp, nil
+}
+
+// sign adds an AWS Signature Version 4 to the request.
+func (s *S3) sign(req *http.Request, body []byte, now time.Time) {
+	date := now.Format("20060102")
+	timestamp := now.Format("20060102T150405Z")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", timestamp)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	signed := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	if s.options.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.options.SessionToken)
+		signed = append(signed, "x-amz-security-token")
+	}
+
+	var headers strings.Builder
+	for _, name := range signed {
+		value := req.Header.Get(name)
+		if name == "host" {
+			value = req.URL.Host
+		}
+		fmt.Fprintf(&headers, "%v:%v\n", name, strings.TrimSpace(value))
+	}
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		"",
+		headers.String(),
+		strings.Join(signed, ";"),
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{date, s.options.Region, "s3", "aws4_request"}, "/")
+	toSign := strings.Join([]string{"AWS4-HMAC-SHA256", timestamp, scope, sha256Hex([]byte(canonical))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.options.SecretKey), date)
+	for _, part := range []string{s.options.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%v/%v, SignedHeaders=%v, Signature=%v",
+		s.options.AccessKey, scope, strings.Join(signed, ";"), signature,
+	))
+}
+
+// uriEncode escapes everything but unreserved characters and slashes,
+// as required for canonical requests.
+func uriEncode(pathname string) string {
+	var b strings.Builder
+	for _, c := range []byte(pathname) {
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9':
+			b.WriteByte(c)
+		case c == '-', c == '_', c == '.', c == '~', c == '/':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/leotaku/kojirou/cmd/formats"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var exportArg string
+
+// chaptersFlags are the flags of the chapters command, which are set
+// by init to avoid an initialization cycle.
+var chaptersFlags *pflag.FlagSet
+
+var chaptersCmd = &cobra.Command{
+	Use:   "chapters [flags..] <identifier>",
+	Short: "List the chapters that would be downloaded",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		identifierArg = args[0]
+
+		return chapters(cmd.Context())
+	},
+}
+
+func chapters(ctx context.Context) error {
+	p := formats.VanishingProgress("Metadata")
+	sources, err := getProviders(ctx)
+	if err != nil {
+		p.Cancel("Error")
+		return err
+	}
+	manga, err := getSkeleton(ctx, sources)
+	if err != nil {
+		p.Cancel("Error")
+		return fmt.Errorf("skeleton: %w", err)
+	}
+	p.Done()
+
+	chapters, err := getChapters(ctx, sources, *manga)
+	if err != nil {
+		return fmt.Errorf("chapters: %w", err)
+	}
+
+	summaries := formats.SummarizeChapters(chapters)
+	if exportArg != "" {
+		if err := formats.ExportChapters(exportArg, summaries); err != nil {
+			return fmt.Errorf("export: %w", err)
+		}
+		return nil
+	} else if jsonArg {
+		return formats.PrintJSON(summaries)
+	}
+	formats.PrintChapters(summaries)
+
+	return nil
+}
+
+func init() {
+	chaptersCmd.Flags().StringVarP(&languageArg, "language", "l", "en", "languages for chapter downloads, in order of preference")
+	chaptersCmd.Flags().StringVarP(&rankArg, "rank", "r", "most", "chapter ranking method to use")
+	chaptersCmd.Flags().StringVarP(&fallbackLanguageArg, "fallback-language", "", "", "comma-separated languages used to fill missing chapters")
+	chaptersCmd.Flags().StringVarP(&diskArg, "disk", "D", "", "load additional content from disk")
+	chaptersCmd.Flags().StringVarP(&volumesFilter, "volumes", "V", "", "volume identifiers for chapter downloads")
+	chaptersCmd.Flags().StringVarP(&chaptersFilter, "chapters", "C", "", "chapter identifiers for chapter downloads")
+	chaptersCmd.Flags().StringVarP(&groupsFilter, "groups", "G", "", "scantlation groups for chapter downloads")
+	chaptersCmd.Flags().StringVarP(&sinceFilter, "since", "", "", "only chapters readable since this date or duration")
+	chaptersCmd.Flags().StringVarP(&untilFilter, "until", "", "", "only chapters readable until this date or duration")
+	chaptersCmd.Flags().StringVarP(&expressionFilter, "filter", "", "", "expression for complex chapter selections")
+	chaptersCmd.Flags().StringVarP(&preferGroupArg, "prefer-group", "P", "", "prefer uploads by these comma-separated groups, in order")
+	chaptersCmd.Flags().StringVarP(&noVolumeArg, "no-volume", "", "special", "chapters without a volume go to special, last or group[:N]")
+	chaptersCmd.Flags().StringVarP(&exportArg, "export", "e", "", "write chapter table to this CSV or JSON file")
+	chaptersCmd.Flags().SortFlags = false
+	markFilters(chaptersCmd.Flags(), "volumes", "chapters", "groups", "since", "until", "filter")
+	chaptersFlags = chaptersCmd.Flags()
+	rootCmd.AddCommand(chaptersCmd)
+}
@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/leotaku/kojirou/cmd/formats/pdf"
+	"github.com/leotaku/kojirou/cmd/scale"
+	"github.com/leotaku/kojirou/codec"
+	"github.com/spf13/cobra"
+)
+
+// completionDepth limits how deep the library is searched for manifests,
+// which is enough for both regular and Kindle folder layouts.
+const completionDepth = 3
+
+// completeIdentifiers suggests the identifiers of all series tracked in
+// the library, using their titles as descriptions.  Paths are completed
+// as usual if no series matches.
+func completeIdentifiers(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	suggestions := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, m := range libraryManifests(libraryRoot(cmd)) {
+		if seen[m.Identifier] || !strings.HasPrefix(m.Identifier, toComplete) {
+			continue
+		}
+		seen[m.Identifier] = true
+		suggestions = append(suggestions, fmt.Sprintf("%v\t%v", m.Identifier, m.Title))
+	}
+	sort.Strings(suggestions)
+
+	return suggestions, cobra.ShellCompDirectiveDefault
+}
+
+// libraryRoot returns the output directory given on the command line
+// or in the configuration file, falling back to the current directory.
+func libraryRoot(cmd *cobra.Command) string {
+	if f := cmd.Flags().Lookup("out"); f != nil && f.Value.String() != "" {
+		return f.Value.String()
+	}
+	if cfg, err := readConfig(); err == nil {
+		if out, ok := cfg["out"].(string); ok && out != "" {
+			return out
+		}
+	}
+
+	return "."
+}
+
+func libraryManifests(root string) []manifest {
+	result := make([]manifest, 0)
+	root = filepath.Clean(root)
+	filepath.WalkDir(root, func(pathname string, d fs.DirEntry, err error) error { //nolint:errcheck
+		switch {
+		case err != nil:
+			return nil
+		case d.IsDir() && strings.Count(strings.TrimPrefix(pathname, root), string(filepath.Separator)) >= completionDepth:
+			return fs.SkipDir
+		case d.IsDir() || d.Name() != manifestFilename:
+			return nil
+		}
+
+		if m, err := readManifest(filepath.ToSlash(filepath.Dir(pathname))); err == nil && m.Identifier != "" {
+			result = append(result, *m)
+		}
+		return nil
+	})
+
+	return result
+}
+
+func completeValues(values ...string) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+		return values, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// registerCompletions must run after all flags have been defined.
+func registerCompletions() {
+	for _, cmd := range []*cobra.Command{rootCmd, chaptersCmd, infoCmd, coversCmd} {
+		cmd.ValidArgsFunction = completeIdentifiers
+		cmd.RegisterFlagCompletionFunc("rank", completeValues("most", "newest", "newest-total", "views", "views-total"))      //nolint:errcheck
+		cmd.RegisterFlagCompletionFunc("filename-reserved", completeValues("auto", "windows", "darwin", "posix", "portable")) //nolint:errcheck
+	}
+	for _, cmd := range []*cobra.Command{rootCmd, chaptersCmd} {
+		cmd.RegisterFlagCompletionFunc("no-volume", completeValues("special", "last", "group")) //nolint:errcheck
+	}
+	rootCmd.RegisterFlagCompletionFunc("log-level", completeValues("error", "warning", "info", "debug")) //nolint:errcheck
+	rootCmd.RegisterFlagCompletionFunc("log-format", completeValues("text", "json"))                     //nolint:errcheck
+	rootCmd.RegisterFlagCompletionFunc("notify", completeValues("bell", "title", "desktop"))             //nolint:errcheck
+	rootCmd.RegisterFlagCompletionFunc("progress", completeValues("bar", "json"))                        //nolint:errcheck
+	for _, cmd := range []*cobra.Command{rootCmd, convertCmd} {
+		cmd.RegisterFlagCompletionFunc("format", completeValues(formatNames()...))                                               //nolint:errcheck
+		cmd.RegisterFlagCompletionFunc("pdf-page-size", completeValues(pdf.PageSizeNames()...))                                  //nolint:errcheck
+		cmd.RegisterFlagCompletionFunc("resize", completeValues(scale.ResolutionNames()...))                                     //nolint:errcheck
+		cmd.RegisterFlagCompletionFunc("resize-filter", completeValues(scale.FilterNames()...))                                  //nolint:errcheck
+		cmd.RegisterFlagCompletionFunc("image-format", completeValues(append([]string{"original"}, codec.EncoderNames()...)...)) //nolint:errcheck
+	}
+}
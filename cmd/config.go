@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var configArg string
+
+// givenFlags are the flags given on the command line or in the
+// environment, which the configuration file does not override.
+var givenFlags = make(map[string]bool)
+
+// config maps flag names to default values.  Top-level options apply
+// to all commands, while tables named after a command or listed under
+// "series" only apply to that command or manga identifier.
+type config map[string]interface{}
+
+func configPath() (string, error) {
+	if configArg != "" {
+		return configArg, nil
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "kojirou", "config.toml"), nil
+}
+
+func readConfig() (config, error) {
+	filename, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, fs.ErrNotExist) && configArg == "" {
+		return make(config), nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	result := make(config)
+	if err := toml.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("decode: %w", err)
+	}
+
+	return result, nil
+}
+
+// applyConfig sets all flags of the command that have not been given
+// on the command line to the values from the environment or, failing
+// that, the configuration file.
+func applyConfig(cmd *cobra.Command, args []string) error {
+	explicit := make(map[string]bool)
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	var envErr error
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		value, ok := os.LookupEnv(envName(f.Name))
+		if !ok || explicit[f.Name] || envErr != nil {
+			return
+		}
+		if err := cmd.Flags().Set(f.Name, value); err != nil {
+			envErr = fmt.Errorf("%v: %w", envName(f.Name), err)
+		}
+		explicit[f.Name] = true
+	})
+	if envErr != nil {
+		return envErr
+	}
+	for name := range explicit {
+		givenFlags[name] = true
+	}
+
+	cfg, err := readConfig()
+	if err != nil {
+		return err
+	}
+
+	tables := make([]config, 0)
+	if table, ok := cfg.series(args); ok && !cmd.HasParent() {
+		tables = append(tables, table)
+	}
+	if table, ok := cfg[cmd.Name()].(map[string]interface{}); ok {
+		tables = append(tables, table)
+	}
+	tables = append(tables, cfg)
+
+	for _, table := range tables {
+		if err := applyConfigTable(cmd.Flags(), table, explicit); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// applySeriesConfig sets the flags from the table of the identifier in
+// the "series" table, for manga that are not given on the command line.
+// Flags given on the command line or in the environment are kept.
+func applySeriesConfig(flags *pflag.FlagSet, identifier string) error {
+	cfg, err := readConfig()
+	if err != nil {
+		return err
+	}
+	table, ok := cfg.series([]string{identifier})
+	if !ok {
+		return nil
+	}
+	explicit := make(map[string]bool)
+	for name := range givenFlags {
+		explicit[name] = true
+	}
+
+	return applyConfigTable(flags, table, explicit)
+}
+
+// series returns the table under "series" for the manga identifier that
+// is the first argument, if any.
+func (c config) series(args []string) (config, bool) {
+	series, ok := c["series"].(map[string]interface{})
+	if !ok || len(args) == 0 {
+		return nil, false
+	}
+	table, ok := series[args[0]].(map[string]interface{})
+
+	return table, ok
+}
+
+// applyConfigTable sets the flags from the table that are not marked
+// as explicit, and marks them, so that later tables do not override
+// them.
+func applyConfigTable(flags *pflag.FlagSet, table config, explicit map[string]bool) error {
+	for name, value := range table {
+		if _, ok := value.(map[string]interface{}); ok {
+			continue
+		}
+		if flags.Lookup(name) == nil || explicit[name] || name == "config" {
+			continue
+		}
+		if err := flags.Set(name, configValue(value)); err != nil {
+			return fmt.Errorf("option %v: %w", name, err)
+		}
+		explicit[name] = true
+	}
+
+	return nil
+}
+
+func configValue(value interface{}) string {
+	if list, ok := value.([]interface{}); ok {
+		result := make([]string, 0)
+		for _, it := range list {
+			result = append(result, fmt.Sprint(it))
+		}
+		return strings.Join(result, ",")
+	}
+
+	return fmt.Sprint(value)
+}
+
+func envName(flag string) string {
+	return "KOJIROU_" + strings.ToUpper(strings.ReplaceAll(flag, "-", "_"))
+}
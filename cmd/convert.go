@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/leotaku/kojirou/cmd/formats"
+	"github.com/leotaku/kojirou/cmd/formats/archive"
+	"github.com/leotaku/kojirou/cmd/pipeline"
+	md "github.com/leotaku/kojirou/mangadex"
+	"github.com/spf13/cobra"
+)
+
+var (
+	profileArg string
+	titleArg   string
+)
+
+var convertCmd = &cobra.Command{
+	Use:   "convert [flags..] <archive>...",
+	Short: "Convert CBZ/CBR archives to e-books",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return convert(cmd.Context(), args)
+	},
+}
+
+func convert(ctx context.Context, filenames []string) error {
+	switch profileArg {
+	case "kindle":
+	default:
+		return fmt.Errorf(`not a valid profile: "%v"`, profileArg)
+	}
+
+	for _, filename := range filenames {
+		if err := convertArchive(ctx, filename); err != nil {
+			return fmt.Errorf("%v: %w", filename, err)
+		}
+	}
+
+	return nil
+}
+
+func convertArchive(ctx context.Context, filename string) error {
+	p := formats.TitledProgress(fmt.Sprintf("Archive: %v", filepath.Base(filename)))
+	a, err := archive.Load(filename, p)
+	if err != nil {
+		p.Cancel("Error")
+		return err
+	}
+
+	title := titleArg
+	if title == "" {
+		title = a.Info.Series
+	}
+	if title == "" {
+		abs, _ := filepath.Abs(filename)
+		title = filepath.Base(filepath.Dir(abs))
+	}
+
+	dir := seriesDirectory(title)
+	unlock, err := lockDirectory(dir.Directory())
+	if err != nil {
+		p.Cancel("Error")
+		return fmt.Errorf("lock: %w", err)
+	}
+	defer unlock()
+
+	writers, err := newWriters()
+	if err != nil {
+		p.Cancel("Error")
+		return err
+	}
+	if hasVolume(dir, a.Volume, writers) && !forceArg {
+		p.Cancel("Skipped")
+		return nil
+	}
+	p.Done()
+
+	skeleton := md.Manga{
+		Info: md.MangaInfo{
+			Title:   title,
+			Authors: a.Info.Authors(),
+			Artists: a.Info.Artists(),
+		},
+		Volumes: make(map[md.Identifier]md.Volume),
+	}
+	applyOverrides(&skeleton)
+	skeleton = skeleton.WithChapters(a.Chapters).WithCovers(md.ImageList{{
+		Image:            a.Cover,
+		VolumeIdentifier: a.Volume,
+	}})
+
+	job := &pipeline.Job{Manga: skeleton, Volume: skeleton.Volumes[a.Volume], Pages: a.Pages}
+	steps := volumePipeline(nil, dir, writers).Without(pipeline.Fetch).Replace(deliverStep(dir, writers, false))
+
+	return steps.Run(ctx, job)
+}
+
+func init() {
+	convertCmd.Flags().StringVarP(&profileArg, "profile", "P", "kindle", "device profile for generated e-books")
+	convertCmd.Flags().StringVarP(&formatArg, "format", "", "azw3", "comma-separated output formats")
+	convertCmd.Flags().StringVarP(&titleArg, "title", "t", "", "series title for generated e-books")
+	convertCmd.Flags().StringVarP(&setAuthorsArg, "set-authors", "", "", "override comma-separated authors of generated e-books")
+	convertCmd.Flags().StringVarP(&setSeriesArg, "set-series", "", "", "override series name used for metadata and the output directory")
+	convertCmd.Flags().StringVarP(&setLanguageArg, "set-language", "", "", "override language of generated e-books")
+	convertCmd.Flags().BoolVarP(&autocropArg, "autocrop", "a", false, "crop whitespace from pages automatically")
+	convertCmd.Flags().BoolVarP(&einkOptimizeArg, "eink-optimize", "", false, "convert pages to 16 gray levels tuned for e-ink screens")
+	convertCmd.Flags().BoolVarP(&kindleFolderModeArg, "kindle-folder-mode", "k", false, "generate folder structure for Kindle devices")
+	convertCmd.Flags().BoolVarP(&leftToRightArg, "left-to-right", "p", false, "make reading direction left to right")
+	convertCmd.Flags().IntVarP(&fillVolumeNumberArg, "fill-volume-number", "n", 0, "fill volume number with leading zeros in title")
+	convertCmd.Flags().StringVarP(&outArg, "out", "o", "", "output directory")
+	convertCmd.Flags().BoolVarP(&forceArg, "force", "f", false, "overwrite existing volumes")
+	convertCmd.Flags().BoolVarP(&waitArg, "wait", "w", false, "wait for other runs on the same output directory")
+	addPDFFlags(convertCmd.Flags())
+	addScaleFlags(convertCmd.Flags())
+	addImageFlags(convertCmd.Flags())
+	addFilenameFlags(convertCmd.Flags())
+	addHookFlags(convertCmd.Flags())
+	convertCmd.Flags().SortFlags = false
+	rootCmd.AddCommand(convertCmd)
+}
@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/leotaku/kojirou/cmd/filter"
+	"github.com/leotaku/kojirou/cmd/formats"
+	md "github.com/leotaku/kojirou/mangadex"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/text/language"
+)
+
+var coversLocalesArg string
+
+var coversCmd = &cobra.Command{
+	Use:   "covers [flags..] <identifier>",
+	Short: "Download all cover art of a manga at full resolution",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return covers(cmd.Context(), args[0])
+	},
+}
+
+func covers(ctx context.Context, identifier string) error {
+	mangaID, err := resolveIdentifier(ctx, identifier)
+	if err != nil {
+		return fmt.Errorf("identifier: %w", err)
+	}
+	manga, err := getDownloader().MangadexSkeleton(ctx, mangaID)
+	if err != nil {
+		return fmt.Errorf("skeleton: %w", err)
+	}
+
+	locales := make([]language.Tag, 0)
+	for _, locale := range strings.Split(coversLocalesArg, ",") {
+		if locale = strings.TrimSpace(locale); locale != "" {
+			locales = append(locales, language.Make(locale))
+		}
+	}
+	paths, err := getDownloader().MangadexCoverPaths(ctx, mangaID, locales...)
+	if err != nil {
+		return fmt.Errorf("covers: %w", err)
+	}
+	if volumesFilter != "" {
+		ranges := filter.ParseRanges(volumesFilter)
+		paths = paths.FilterBy(func(p md.Path) bool {
+			return ranges.Contains(p.VolumeIdentifier)
+		})
+	}
+
+	directory := outArg
+	if directory == "" {
+		directory = sanitizer().Pathname(manga.Info.Title)
+	}
+	directory = formats.LongPath(directory)
+	if err := os.MkdirAll(directory, os.ModePerm); err != nil {
+		return fmt.Errorf("directory: %w", err)
+	}
+
+	p := formats.TitledProgress("Covers")
+	p.Increase(len(paths))
+	counts := make(map[md.Identifier]int)
+	d := getDownloader()
+	eg := new(errgroup.Group)
+	eg.SetLimit(d.Options().ImageJobs)
+	for _, cover := range paths {
+		name := cover.VolumeIdentifier.StringFilled(4, 2, false)
+		if counts[cover.VolumeIdentifier] > 0 {
+			name = fmt.Sprintf("%v-%v", name, counts[cover.VolumeIdentifier]+1)
+		}
+		counts[cover.VolumeIdentifier]++
+
+		cover, pathname := cover, filepath.Join(directory, name+path.Ext(cover.URL))
+		if _, err := os.Stat(pathname); err == nil && !forceArg {
+			p.Add(1)
+			continue
+		}
+		eg.Go(func() error {
+			if err := d.DownloadFile(ctx, cover.URL, pathname, p); err != nil {
+				if ctx.Err() != nil {
+					return fmt.Errorf("volume %v: %w", cover.VolumeIdentifier, err)
+				}
+				p.Fail(fmt.Sprintf("Volume %v", cover.VolumeIdentifier), err)
+				return nil
+			}
+			p.Add(1)
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		p.Cancel("Error")
+		return err
+	}
+	if p.Failed() > 0 {
+		p.Cancel("Error")
+		return fmt.Errorf("%v of %v covers failed", p.Failed(), len(paths))
+	}
+	p.Done()
+
+	return nil
+}
+
+func init() {
+	coversCmd.Flags().StringVarP(&coversLocalesArg, "locales", "L", "", "only download covers for these languages")
+	coversCmd.Flags().StringVarP(&outArg, "out", "o", "", "output directory")
+	coversCmd.Flags().BoolVarP(&forceArg, "force", "f", false, "overwrite existing covers")
+	coversCmd.Flags().StringVarP(&volumesFilter, "volumes", "V", "", "volume identifiers for cover downloads")
+	addFilenameFlags(coversCmd.Flags())
+	coversCmd.Flags().SortFlags = false
+	markFilters(coversCmd.Flags(), "volumes")
+	rootCmd.AddCommand(coversCmd)
+}
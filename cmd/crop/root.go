@@ -4,16 +4,27 @@ import (
 	"fmt"
 	"image"
 	"image/color"
+
+	"github.com/leotaku/kojirou/codec"
 )
 
 const grayDarknessLimit = 128
 
+// Crop returns the part of the image within the bounds.  Images are
+// returned unchanged if there is nothing to crop, so that they keep
+// their encoded data.
 func Crop(img image.Image, bounds image.Rectangle) (image.Image, error) {
 	type subImager interface {
 		SubImage(r image.Rectangle) image.Image
 	}
 
-	if img, ok := img.(subImager); !ok {
+	if bounds == img.Bounds() {
+		return img, nil
+	}
+	decoded, err := codec.Decoded(img)
+	if err != nil {
+		return nil, fmt.Errorf("decode: %w", err)
+	} else if img, ok := decoded.(subImager); !ok {
 		return nil, fmt.Errorf("image does not support cropping")
 	} else {
 		return img.SubImage(bounds), nil
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"path/filepath"
+	"runtime"
+	rpprof "runtime/pprof"
+	"time"
+
+	"github.com/leotaku/kojirou/cmd/formats"
+)
+
+// profileDuration is how long the CPU is profiled after a signal.
+const profileDuration = 10 * time.Second
+
+var pprofArg string
+
+// servePprof exposes runtime profiles on the given address until the
+// program exits, so that they can be read using "go tool pprof".
+func servePprof(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	go func() {
+		if err := http.Serve(listener, mux); err != nil {
+			formats.Debug("Profiling server stopped", "error", err)
+		}
+	}()
+
+	return nil
+}
+
+// dumpProfiles writes a heap profile immediately and a CPU profile once
+// the CPU has been profiled for a while, both to the temporary
+// directory.
+func dumpProfiles() {
+	prefix := filepath.Join(os.TempDir(), fmt.Sprintf("kojirou-%v-%v", os.Getpid(), time.Now().Unix()))
+	if err := writeHeapProfile(prefix + "-heap.pprof"); err != nil {
+		fmt.Fprintf(os.Stderr, "Writing heap profile failed: %v\n", err)
+	} else {
+		fmt.Fprintf(os.Stderr, "Wrote heap profile to %v\n", prefix+"-heap.pprof")
+	}
+
+	if err := writeCPUProfile(prefix+"-cpu.pprof", profileDuration); err != nil {
+		fmt.Fprintf(os.Stderr, "Writing CPU profile failed: %v\n", err)
+	} else {
+		fmt.Fprintf(os.Stderr, "Wrote CPU profile to %v\n", prefix+"-cpu.pprof")
+	}
+}
+
+func writeHeapProfile(filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	runtime.GC()
+	if err := rpprof.WriteHeapProfile(f); err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+// writeCPUProfile fails if the CPU is already being profiled, for
+// example because of --cpuprofile.
+func writeCPUProfile(filename string, duration time.Duration) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := rpprof.StartCPUProfile(f); err != nil {
+		os.Remove(filename)
+		return err
+	}
+	time.Sleep(duration)
+	rpprof.StopCPUProfile()
+
+	return f.Close()
+}
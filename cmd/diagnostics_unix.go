@@ -0,0 +1,21 @@
+//go:build !windows
+
+package cmd
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// watchProfileSignal dumps profiles whenever the program receives
+// SIGUSR1, e.g. from "kill -USR1 <pid>".
+func watchProfileSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1)
+	go func() {
+		for range signals {
+			dumpProfiles()
+		}
+	}()
+}
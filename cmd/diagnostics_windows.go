@@ -0,0 +1,4 @@
+package cmd
+
+// watchProfileSignal does nothing, as there is no SIGUSR1 on Windows.
+func watchProfileSignal() {}
@@ -0,0 +1,281 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/leotaku/kojirou/cmd/formats"
+	md "github.com/leotaku/kojirou/mangadex"
+	"github.com/mattn/go-isatty"
+	"github.com/pelletier/go-toml/v2"
+	"github.com/spf13/pflag"
+)
+
+var preferGroupArg string
+
+type groupCount struct {
+	name  string
+	count int
+}
+
+// preferGroup moves chapters by preferred groups in front of other
+// uploads of the same chapter, so they survive duplicate removal.
+// Uploads by groups listed earlier are preferred over later ones.
+func preferGroup(cl md.ChapterList) md.ChapterList {
+	groups := preferredGroups()
+	if len(groups) == 0 {
+		return cl
+	}
+
+	return cl.SortBy(func(a, b md.ChapterInfo) bool {
+		return groupRank(a, groups) < groupRank(b, groups)
+	})
+}
+
+// preferredGroups returns the comma-separated groups of --prefer-group
+// from the most to the least preferred.
+func preferredGroups() []string {
+	groups := make([]string, 0)
+	for _, group := range strings.Split(preferGroupArg, ",") {
+		if group = strings.TrimSpace(group); group != "" {
+			groups = append(groups, group)
+		}
+	}
+
+	return groups
+}
+
+// groupRank returns the position of the most preferred group that
+// uploaded the chapter, or the number of groups if none of them did.
+// Joint uploads match both their combined name and each of their
+// groups.
+func groupRank(info md.ChapterInfo, groups []string) int {
+	for i, group := range groups {
+		if info.GroupNames.String() == group {
+			return i
+		}
+		for _, name := range info.GroupNames {
+			if name == group {
+				return i
+			}
+		}
+	}
+
+	return len(groups)
+}
+
+// canDisambiguate reports whether the user should be asked to pick a
+// group, which is only the case for interactive sessions without a
+// configured ranking strategy.
+func canDisambiguate() bool {
+	switch {
+	case preferGroupArg != "", groupsFilter != "", rankGiven():
+		return false
+	case jsonArg, formats.EventsEnabled(), repairing, !formats.Enabled(formats.LevelInfo):
+		return false
+	default:
+		return isatty.IsTerminal(os.Stdin.Fd()) && isatty.IsTerminal(os.Stdout.Fd())
+	}
+}
+
+// rankGiven reports whether a ranking has been chosen on the command
+// line, in the environment or in the configuration file, even if it is
+// the default ranking.
+func rankGiven() bool {
+	for _, flags := range []*pflag.FlagSet{manifestFlags, chaptersFlags} {
+		if flags != nil && flags.Changed("rank") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// duplicateGroups counts how many duplicated chapters each group has
+// uploaded, ordered by that count.
+func duplicateGroups(cl md.ChapterList) []groupCount {
+	type key struct {
+		chapter md.Identifier
+		volume  md.Identifier
+	}
+	uploads := make(map[key][]string)
+	for _, chapter := range cl {
+		k := key{chapter.Info.Identifier, chapter.Info.VolumeIdentifier}
+		uploads[k] = append(uploads[k], chapter.Info.GroupNames.String())
+	}
+
+	counts := make(map[string]int)
+	for _, groups := range uploads {
+		if len(groups) < 2 {
+			continue
+		}
+		seen := make(map[string]bool)
+		for _, group := range groups {
+			if !seen[group] {
+				counts[group]++
+				seen[group] = true
+			}
+		}
+	}
+	if len(counts) < 2 {
+		return nil
+	}
+
+	result := make([]groupCount, 0)
+	for name, count := range counts {
+		result = append(result, groupCount{name, count})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].count != result[j].count {
+			return result[i].count > result[j].count
+		}
+		return result[i].name < result[j].name
+	})
+
+	return result
+}
+
+// askGroup lets the user pick one of the groups.  An empty result means
+// the configured ranking should be used.
+func askGroup(groups []groupCount, in io.Reader, out io.Writer) (string, error) {
+	fmt.Fprintln(out, "Some chapters have been uploaded by multiple groups:")
+	for i, group := range groups {
+		fmt.Fprintf(out, "  %-3v %v (%v chapters)\n", i+1, group.name, group.count)
+	}
+
+	r := bufio.NewReader(in)
+	for {
+		fmt.Fprint(out, "Preferred group or Enter to use the ranking: ")
+		line, err := r.ReadString('\n')
+		if err != nil && !(errors.Is(err, io.EOF) && line != "") {
+			return "", fmt.Errorf("read: %w", err)
+		}
+
+		line = strings.TrimSpace(line)
+		if line == "" {
+			return "", nil
+		}
+		n, err := strconv.Atoi(line)
+		if err != nil || n < 1 || n > len(groups) {
+			fmt.Fprintf(out, "Not a valid entry: %v\n", line)
+			continue
+		}
+
+		return groups[n-1].name, nil
+	}
+}
+
+// rememberGroup stores the preferred group for the current series in
+// the configuration file, so the question is only asked once.  Only the
+// table of the series is changed, so that the comments and formatting
+// of the rest of the file are kept.
+func rememberGroup(group string) error {
+	filename, err := configPath()
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	value, err := tomlValue(group)
+	if err != nil {
+		return fmt.Errorf("encode: %w", err)
+	}
+	updated, err := setSeriesOption(string(data), identifierArg, "prefer-group", value)
+	if err != nil {
+		return fmt.Errorf("encode: %w", err)
+	}
+	cfg := make(config)
+	if err := toml.Unmarshal([]byte(updated), &cfg); err != nil {
+		return fmt.Errorf("update: %w", err)
+	} else if table, ok := cfg.series([]string{identifierArg}); !ok || table["prefer-group"] != group {
+		return fmt.Errorf("update: series table is not a plain table")
+	}
+
+	return formats.WriteFileAtomic(filename, []byte(updated))
+}
+
+// setSeriesOption sets the option in the table of the identifier under
+// "series" of the TOML text, replacing an existing line for the option.
+// The table is added to the end of the text if it does not exist yet.
+func setSeriesOption(text, identifier, name, value string) (string, error) {
+	key, err := tomlValue(identifier)
+	if err != nil {
+		return "", err
+	}
+	header := regexp.MustCompile(`^\s*\[\s*series\s*\.\s*` + tomlKeyPattern(identifier) + `\s*\]\s*(#.*)?$`)
+	option := regexp.MustCompile(`^\s*` + tomlKeyPattern(name) + `\s*=`)
+	entry := name + " = " + value
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if !header.MatchString(line) {
+			continue
+		}
+		for j := i + 1; j < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[j]), "["); j++ {
+			if option.MatchString(lines[j]) {
+				lines[j] = entry
+				return strings.Join(lines, "\n"), nil
+			}
+		}
+		lines = append(lines[:i+1], append([]string{entry}, lines[i+1:]...)...)
+		return strings.Join(lines, "\n"), nil
+	}
+
+	if text != "" && !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	if text != "" {
+		text += "\n"
+	}
+
+	return text + fmt.Sprintf("[series.%v]\n%v\n", key, entry), nil
+}
+
+// tomlKeyPattern matches the key as a bare, basic or literal key.
+func tomlKeyPattern(key string) string {
+	quoted := regexp.QuoteMeta(key)
+
+	return `(?:` + quoted + `|"` + quoted + `"|'` + quoted + `')`
+}
+
+// tomlValue encodes the string as a TOML value.
+func tomlValue(s string) (string, error) {
+	data, err := toml.Marshal(map[string]string{"v": s})
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(strings.TrimPrefix(string(data), "v = ")), nil
+}
+
+func disambiguate(cl md.ChapterList) (md.ChapterList, error) {
+	if !canDisambiguate() {
+		return cl, nil
+	}
+	groups := duplicateGroups(cl)
+	if len(groups) == 0 {
+		return cl, nil
+	}
+
+	group, err := askGroup(groups, os.Stdin, os.Stdout)
+	if err != nil || group == "" {
+		return cl, err
+	}
+	preferGroupArg = group
+	if err := rememberGroup(group); err != nil {
+		formats.Report("Config", "could not remember preferred group: %v", err)
+	}
+
+	return preferGroup(cl), nil
+}
@@ -0,0 +1,87 @@
+package eink
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"math"
+
+	"github.com/leotaku/kojirou/codec"
+)
+
+const (
+	// Levels is the number of gray levels that Kindle panels display.
+	Levels = 16
+	// Gamma darkens midtones, which appear washed out on e-ink panels.
+	Gamma = 1.8
+	// clip is the fraction of the darkest and brightest pixels that is
+	// ignored when stretching the contrast, so that a few stray pixels
+	// do not prevent it.
+	clip = 0.005
+)
+
+// Optimize converts the image to grayscale, stretches its contrast to
+// the full range, darkens it using Gamma and reduces it to Levels gray
+// levels.  Pages that are reduced to the levels of the panel are
+// smaller and are not dithered inconsistently by the device.
+func Optimize(img image.Image) (image.Image, error) {
+	decoded, err := codec.Decoded(img)
+	if err != nil {
+		return nil, fmt.Errorf("decode: %w", err)
+	}
+
+	bounds := decoded.Bounds()
+	gray := image.NewGray(bounds)
+	draw.Draw(gray, bounds, decoded, bounds.Min, draw.Src)
+	histogram := [256]int{}
+	for _, v := range gray.Pix {
+		histogram[v]++
+	}
+
+	table := levelTable(histogram)
+	for i, v := range gray.Pix {
+		gray.Pix[i] = table[v]
+	}
+
+	return gray, nil
+}
+
+// levelTable maps every gray value to its optimized value, based on the
+// histogram of the image.
+func levelTable(histogram [256]int) [256]uint8 {
+	low, high := clipRange(histogram)
+	if high <= low {
+		low, high = 0, 255
+	}
+	table := [256]uint8{}
+	for i := range table {
+		v := math.Max(0, math.Min(1, float64(i-low)/float64(high-low)))
+		level := math.Round(math.Pow(v, Gamma) * (Levels - 1))
+		table[i] = uint8(level * 255 / (Levels - 1))
+	}
+
+	return table
+}
+
+// clipRange returns the darkest and brightest gray values, ignoring
+// the clipped fraction of pixels on both ends.
+func clipRange(histogram [256]int) (int, int) {
+	total := 0
+	for _, n := range histogram {
+		total += n
+	}
+	limit := int(float64(total) * clip)
+
+	low, count := 0, 0
+	for low < 255 && count+histogram[low] <= limit {
+		count += histogram[low]
+		low++
+	}
+	high, count := 255, 0
+	for high > low && count+histogram[high] <= limit {
+		count += histogram[high]
+		high--
+	}
+
+	return low, high
+}
@@ -0,0 +1,107 @@
+package events
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	md "github.com/leotaku/kojirou/mangadex"
+)
+
+// Event is implemented by all events published on a bus.  Subscribers
+// select the events they are interested in using a type switch.
+type Event interface {
+	event()
+}
+
+// ChapterStarted is published when the pages of a chapter are about to
+// be downloaded.
+type ChapterStarted struct {
+	Chapter md.ChapterInfo
+}
+
+// PageFetched is published for every page that has been downloaded
+// and decoded.
+type PageFetched struct {
+	Volume  md.Identifier
+	Chapter md.Identifier
+	Page    int
+}
+
+// VolumeBuilt is published after all output files of a volume have
+// been written.
+type VolumeBuilt struct {
+	Manga    md.MangaInfo
+	Volume   md.Identifier
+	Pages    int
+	Duration time.Duration
+}
+
+// Finished is published once the program is done, with the error that
+// caused it to fail, if any.
+type Finished struct {
+	Err error
+}
+
+func (ChapterStarted) event() {}
+func (PageFetched) event()    {}
+func (VolumeBuilt) event()    {}
+func (Finished) event()       {}
+
+// Bus delivers published events to all subscribers.  Delivery happens
+// synchronously and in the order of subscription, so subscribers must
+// return quickly and be safe for concurrent use.
+type Bus struct {
+	subscribers map[int]func(Event)
+	next        int
+	mutex       sync.RWMutex
+}
+
+// Default is the bus used by Kojirou.  Programs that embed Kojirou may
+// subscribe to it before calling Execute.
+var Default = new(Bus)
+
+// Subscribe registers a function that receives all events published
+// from now on, and returns a function that unsubscribes it again.
+func (b *Bus) Subscribe(fn func(Event)) func() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.subscribers == nil {
+		b.subscribers = make(map[int]func(Event))
+	}
+	id := b.next
+	b.subscribers[id] = fn
+	b.next++
+
+	return func() {
+		b.mutex.Lock()
+		defer b.mutex.Unlock()
+		delete(b.subscribers, id)
+	}
+}
+
+func (b *Bus) Publish(e Event) {
+	b.mutex.RLock()
+	ids := make([]int, 0, len(b.subscribers))
+	for id := range b.subscribers {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	fns := make([]func(Event), 0, len(ids))
+	for _, id := range ids {
+		fns = append(fns, b.subscribers[id])
+	}
+	b.mutex.RUnlock()
+
+	for _, fn := range fns {
+		fn(e)
+	}
+}
+
+func Subscribe(fn func(Event)) func() {
+	return Default.Subscribe(fn)
+}
+
+func Publish(e Event) {
+	Default.Publish(e)
+}
@@ -0,0 +1,57 @@
+package filter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	md "github.com/leotaku/kojirou/mangadex"
+)
+
+var relativeUnits = map[string]time.Duration{
+	"h": time.Hour,
+	"d": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour,
+}
+
+// ParseTime parses dates such as "2023-01-31", timestamps in RFC 3339
+// format and durations relative to now such as "7d", "2w" or "12h".
+// Dates without a time refer to the start of the day, or the end of
+// the day if end is true.
+func ParseTime(s string, now time.Time, end bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, now.Location()); err == nil {
+		if end {
+			return t.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
+		}
+		return t, nil
+	}
+	for suffix, unit := range relativeUnits {
+		if n, err := strconv.Atoi(strings.TrimSuffix(s, suffix)); err == nil && strings.HasSuffix(s, suffix) && n >= 0 {
+			return now.Add(-time.Duration(n) * unit), nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf(`not a valid date: "%v"`, s)
+}
+
+// FilterByReadable keeps chapters that became readable between since
+// and until, both inclusive.  A zero time leaves that end of the range
+// open, while chapters without a known date never match.
+func FilterByReadable(cl md.ChapterList, since, until time.Time) md.ChapterList {
+	return cl.FilterBy(func(ci md.ChapterInfo) bool {
+		switch {
+		case ci.Readable.IsZero():
+			return false
+		case !since.IsZero() && ci.Readable.Before(since):
+			return false
+		case !until.IsZero() && ci.Readable.After(until):
+			return false
+		default:
+			return true
+		}
+	})
+}
@@ -0,0 +1,563 @@
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	md "github.com/leotaku/kojirou/mangadex"
+)
+
+// Expression is a boolean expression over the fields of a chapter, such
+// as `chapter >= 100 && lang == "en" && !contains(groups, "BadGroup")`.
+type Expression struct {
+	source string
+	eval   evaluator
+}
+
+type evaluator = func(md.ChapterInfo) (interface{}, error)
+
+// valueType is the type of a value in an expression.  Types are checked
+// when the expression is parsed, so that operands that are skipped by
+// '&&' and '||' are checked as well.
+type valueType int
+
+const (
+	typeBool valueType = iota
+	typeNumber
+	typeString
+	typeList
+	typeIdentifier
+)
+
+func (t valueType) String() string {
+	return [...]string{"boolean", "number", "string", "list", "identifier"}[t]
+}
+
+// node is a parsed part of an expression.  Constants keep their value,
+// so that patterns can be compiled while parsing.
+type node struct {
+	eval  evaluator
+	typ   valueType
+	pos   int
+	value interface{}
+}
+
+type field struct {
+	typ   valueType
+	value func(md.ChapterInfo) interface{}
+}
+
+var expressionFields = map[string]field{
+	"id":        {typeString, func(ci md.ChapterInfo) interface{} { return ci.ID }},
+	"chapter":   {typeIdentifier, func(ci md.ChapterInfo) interface{} { return ci.Identifier }},
+	"volume":    {typeIdentifier, func(ci md.ChapterInfo) interface{} { return ci.VolumeIdentifier }},
+	"title":     {typeString, func(ci md.ChapterInfo) interface{} { return ci.Title }},
+	"lang":      {typeString, func(ci md.ChapterInfo) interface{} { return ci.Language.String() }},
+	"group":     {typeString, func(ci md.ChapterInfo) interface{} { return ci.GroupNames.String() }},
+	"groups":    {typeList, func(ci md.ChapterInfo) interface{} { return []string(ci.GroupNames) }},
+	"pages":     {typeNumber, func(ci md.ChapterInfo) interface{} { return float64(ci.Pages) }},
+	"views":     {typeNumber, func(ci md.ChapterInfo) interface{} { return float64(ci.Views) }},
+	"published": {typeString, func(ci md.ChapterInfo) interface{} { return ci.Published.Format("2006-01-02") }},
+	"readable":  {typeString, func(ci md.ChapterInfo) interface{} { return ci.Readable.Format("2006-01-02") }},
+}
+
+// function is a function that can be called in expressions.  Check
+// returns the type of the result for the given arguments, or an error
+// if they are not accepted.
+type function struct {
+	check func(args []node) (valueType, error)
+	call  func(args []interface{}) (interface{}, error)
+}
+
+var expressionFunctions = map[string]function{
+	"contains": {
+		check: func(args []node) (valueType, error) {
+			if len(args) != 2 {
+				return 0, fmt.Errorf("contains: expected 2 arguments, got %v", len(args))
+			} else if args[0].typ != typeString && args[0].typ != typeList {
+				return 0, fmt.Errorf("contains: expected string or list, got %v", args[0].typ)
+			} else if args[1].typ != typeString {
+				return 0, fmt.Errorf("contains: expected string, got %v", args[1].typ)
+			}
+			return typeBool, nil
+		},
+		call: func(args []interface{}) (interface{}, error) {
+			needle, ok := args[1].(string)
+			if !ok {
+				return nil, fmt.Errorf("contains: expected string, got %v", typeName(args[1]))
+			}
+			switch haystack := args[0].(type) {
+			case string:
+				return strings.Contains(haystack, needle), nil
+			case []string:
+				for _, it := range haystack {
+					if it == needle {
+						return true, nil
+					}
+				}
+				return false, nil
+			default:
+				return nil, fmt.Errorf("contains: expected string or list, got %v", typeName(args[0]))
+			}
+		},
+	},
+}
+
+// ParseExpression parses the expression and checks that it evaluates to
+// a boolean for any chapter.  Regular expressions are compiled here, so
+// that invalid patterns are reported before any chapter is filtered.
+func ParseExpression(source string) (*Expression, error) {
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	} else if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected '%v' at %v", p.tokens[p.pos].text, p.tokens[p.pos].pos)
+	} else if root.typ != typeBool {
+		return nil, fmt.Errorf("expression is a %v, not a boolean", root.typ)
+	}
+
+	return &Expression{source: source, eval: root.eval}, nil
+}
+
+// Match reports whether the chapter matches the expression.  Errors are
+// only possible for values that cannot be compared, such as chapter
+// numbers that are not numbers.
+func (e *Expression) Match(ci md.ChapterInfo) (bool, error) {
+	return evalBool(e.eval, ci)
+}
+
+func (e *Expression) String() string {
+	return e.source
+}
+
+// FilterByExpression returns the chapters that match the expression,
+// failing for the first chapter that the expression cannot be evaluated
+// for, instead of silently leaving it out.
+func FilterByExpression(cl md.ChapterList, e *Expression) (md.ChapterList, error) {
+	result := make(md.ChapterList, 0)
+	for _, chapter := range cl {
+		matched, err := e.Match(chapter.Info)
+		if err != nil {
+			return nil, fmt.Errorf("chapter %v: %w", chapter.Info.Identifier, err)
+		} else if matched {
+			result = append(result, chapter)
+		}
+	}
+
+	return result, nil
+}
+
+type tokenKind int
+
+const (
+	tokenIdent tokenKind = iota
+	tokenNumber
+	tokenString
+	tokenOperator
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "=~", "!", "<", ">", "(", ")", ","}
+
+func tokenize(source string) ([]token, error) {
+	tokens := make([]token, 0)
+	runes := []rune(source)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		start := i
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsLetter(r) || r == '_':
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, token{tokenIdent, string(runes[start:i]), start})
+		case unicode.IsDigit(r):
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{tokenNumber, string(runes[start:i]), start})
+		case r == '"' || r == '\'':
+			buf := new(strings.Builder)
+			for i++; i < len(runes) && runes[i] != r; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				buf.WriteRune(runes[i])
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("unterminated string at %v", start)
+			}
+			i++
+			tokens = append(tokens, token{tokenString, buf.String(), start})
+		default:
+			found := false
+			for _, op := range operators {
+				if strings.HasPrefix(string(runes[i:]), op) {
+					tokens = append(tokens, token{tokenOperator, op, start})
+					i += len(op)
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("unexpected character '%c' at %v", r, start)
+			}
+		}
+	}
+
+	return tokens, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) accept(op string) bool {
+	if p.pos < len(p.tokens) && p.tokens[p.pos].kind == tokenOperator && p.tokens[p.pos].text == op {
+		p.pos++
+		return true
+	}
+
+	return false
+}
+
+// position returns the position of the last accepted token.
+func (p *parser) position() int {
+	return p.tokens[p.pos-1].pos
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	for err == nil && p.accept("||") {
+		pos := p.position()
+		var right node
+		if right, err = p.parseAnd(); err == nil {
+			left, err = logical(left, right, true, pos)
+		}
+	}
+
+	return left, err
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	for err == nil && p.accept("&&") {
+		pos := p.position()
+		var right node
+		if right, err = p.parseUnary(); err == nil {
+			left, err = logical(left, right, false, pos)
+		}
+	}
+
+	return left, err
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if !p.accept("!") {
+		return p.parseComparison()
+	}
+
+	pos := p.position()
+	operand, err := p.parseUnary()
+	if err != nil {
+		return node{}, err
+	} else if operand.typ != typeBool {
+		return node{}, fmt.Errorf("'!' at %v expects a boolean, got %v", pos, operand.typ)
+	}
+
+	return node{typ: typeBool, pos: pos, eval: func(ci md.ChapterInfo) (interface{}, error) {
+		b, err := evalBool(operand.eval, ci)
+		return !b, err
+	}}, nil
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return node{}, err
+	}
+	for _, op := range []string{"==", "!=", "<=", ">=", "=~", "<", ">"} {
+		if !p.accept(op) {
+			continue
+		}
+		pos := p.position()
+		right, err := p.parsePrimary()
+		if err != nil {
+			return node{}, err
+		} else if op == "=~" {
+			return matchPattern(left, right, pos)
+		} else if err := checkComparison(op, left.typ, right.typ); err != nil {
+			return node{}, fmt.Errorf("%w at %v", err, pos)
+		}
+		return node{typ: typeBool, pos: pos, eval: func(ci md.ChapterInfo) (interface{}, error) {
+			a, err := left.eval(ci)
+			if err != nil {
+				return nil, err
+			}
+			b, err := right.eval(ci)
+			if err != nil {
+				return nil, err
+			}
+			return compare(op, a, b)
+		}}, nil
+	}
+
+	return left, nil
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	if p.pos >= len(p.tokens) {
+		return node{}, fmt.Errorf("unexpected end of expression")
+	}
+	t := p.tokens[p.pos]
+	p.pos++
+
+	switch {
+	case t.kind == tokenOperator && t.text == "(":
+		inner, err := p.parseOr()
+		if err != nil {
+			return node{}, err
+		} else if !p.accept(")") {
+			return node{}, fmt.Errorf("missing ')' for '(' at %v", t.pos)
+		}
+		return inner, nil
+	case t.kind == tokenNumber:
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return node{}, fmt.Errorf("not a valid number '%v' at %v", t.text, t.pos)
+		}
+		return constant(f, typeNumber, t.pos), nil
+	case t.kind == tokenString:
+		return constant(t.text, typeString, t.pos), nil
+	case t.kind == tokenIdent && p.accept("("):
+		return p.parseCall(t)
+	case t.kind == tokenIdent && (t.text == "true" || t.text == "false"):
+		return constant(t.text == "true", typeBool, t.pos), nil
+	case t.kind == tokenIdent:
+		field, ok := expressionFields[t.text]
+		if !ok {
+			return node{}, fmt.Errorf("unknown field '%v' at %v", t.text, t.pos)
+		}
+		return node{typ: field.typ, pos: t.pos, eval: func(ci md.ChapterInfo) (interface{}, error) {
+			return field.value(ci), nil
+		}}, nil
+	default:
+		return node{}, fmt.Errorf("unexpected '%v' at %v", t.text, t.pos)
+	}
+}
+
+func (p *parser) parseCall(name token) (node, error) {
+	function, ok := expressionFunctions[name.text]
+	if !ok {
+		return node{}, fmt.Errorf("unknown function '%v' at %v", name.text, name.pos)
+	}
+
+	args := make([]node, 0)
+	for !p.accept(")") {
+		if len(args) > 0 && !p.accept(",") {
+			return node{}, fmt.Errorf("missing ',' or ')' in call to %v at %v", name.text, name.pos)
+		}
+		arg, err := p.parseOr()
+		if err != nil {
+			return node{}, err
+		}
+		args = append(args, arg)
+	}
+	typ, err := function.check(args)
+	if err != nil {
+		return node{}, fmt.Errorf("%w at %v", err, name.pos)
+	}
+
+	return node{typ: typ, pos: name.pos, eval: func(ci md.ChapterInfo) (interface{}, error) {
+		values := make([]interface{}, 0, len(args))
+		for _, arg := range args {
+			value, err := arg.eval(ci)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		return function.call(values)
+	}}, nil
+}
+
+func constant(value interface{}, typ valueType, pos int) node {
+	return node{typ: typ, pos: pos, value: value, eval: func(md.ChapterInfo) (interface{}, error) {
+		return value, nil
+	}}
+}
+
+func logical(left, right node, or bool, pos int) (node, error) {
+	op := "&&"
+	if or {
+		op = "||"
+	}
+	if left.typ != typeBool || right.typ != typeBool {
+		return node{}, fmt.Errorf("'%v' at %v expects booleans, got %v and %v", op, pos, left.typ, right.typ)
+	}
+
+	return node{typ: typeBool, pos: pos, eval: func(ci md.ChapterInfo) (interface{}, error) {
+		a, err := evalBool(left.eval, ci)
+		if err != nil || a == or {
+			return a, err
+		}
+		return evalBool(right.eval, ci)
+	}}, nil
+}
+
+// matchPattern returns a node that matches a string against a regular
+// expression, which must be a constant so that it is compiled once.
+func matchPattern(left, right node, pos int) (node, error) {
+	pattern, ok := right.value.(string)
+	if left.typ != typeString || !ok {
+		return node{}, fmt.Errorf("'=~' at %v expects a string and a constant pattern, got %v and %v", pos, left.typ, right.typ)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return node{}, fmt.Errorf("not a valid pattern at %v: %w", right.pos, err)
+	}
+
+	return node{typ: typeBool, pos: pos, eval: func(ci md.ChapterInfo) (interface{}, error) {
+		value, err := left.eval(ci)
+		if err != nil {
+			return nil, err
+		}
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("cannot match %v against pattern", typeName(value))
+		}
+		return re.MatchString(s), nil
+	}}, nil
+}
+
+// checkComparison reports whether values of the given types can be
+// compared using the operator, following the rules of compare.
+func checkComparison(op string, a, b valueType) error {
+	switch {
+	case a == typeIdentifier || b == typeIdentifier:
+		for _, t := range []valueType{a, b} {
+			if t != typeIdentifier && t != typeNumber && t != typeString {
+				return fmt.Errorf("cannot compare %v with identifier", t)
+			}
+		}
+		return nil
+	case a == b && (a == typeNumber || a == typeString):
+		return nil
+	case a == b && a == typeBool && (op == "==" || op == "!="):
+		return nil
+	default:
+		return fmt.Errorf("cannot compare %v %v %v", a, op, b)
+	}
+}
+
+func evalBool(eval evaluator, ci md.ChapterInfo) (bool, error) {
+	value, err := eval(ci)
+	if err != nil {
+		return false, err
+	}
+	b, ok := value.(bool)
+	if !ok {
+		return false, fmt.Errorf("expected boolean, got %v", typeName(value))
+	}
+
+	return b, nil
+}
+
+func compare(op string, a, b interface{}) (interface{}, error) {
+	_, id1 := a.(md.Identifier)
+	_, id2 := b.(md.Identifier)
+	if id1 || id2 {
+		x, err := toIdentifier(a)
+		if err != nil {
+			return nil, err
+		}
+		y, err := toIdentifier(b)
+		if err != nil {
+			return nil, err
+		}
+		return order(op, x.Equal(y), x.Less(y))
+	}
+
+	switch x := a.(type) {
+	case float64:
+		if y, ok := b.(float64); ok {
+			return order(op, x == y, x < y)
+		}
+	case string:
+		if y, ok := b.(string); ok {
+			return order(op, x == y, x < y)
+		}
+	case bool:
+		if y, ok := b.(bool); ok && (op == "==" || op == "!=") {
+			return order(op, x == y, false)
+		}
+	}
+
+	return nil, fmt.Errorf("cannot compare %v %v %v", typeName(a), op, typeName(b))
+}
+
+func order(op string, equal, less bool) (bool, error) {
+	switch op {
+	case "==":
+		return equal, nil
+	case "!=":
+		return !equal, nil
+	case "<":
+		return less, nil
+	case "<=":
+		return less || equal, nil
+	case ">":
+		return !less && !equal, nil
+	case ">=":
+		return !less, nil
+	default:
+		return false, fmt.Errorf("unknown operator '%v'", op)
+	}
+}
+
+func toIdentifier(value interface{}) (md.Identifier, error) {
+	switch v := value.(type) {
+	case md.Identifier:
+		return v, nil
+	case float64:
+		return md.NewIdentifier(strconv.FormatFloat(v, 'f', -1, 64)), nil
+	case string:
+		return md.NewIdentifier(v), nil
+	default:
+		return md.Identifier{}, fmt.Errorf("cannot compare %v with identifier", typeName(value))
+	}
+}
+
+func typeName(value interface{}) string {
+	switch value.(type) {
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []string:
+		return "list"
+	case md.Identifier:
+		return "identifier"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
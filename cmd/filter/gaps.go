@@ -0,0 +1,97 @@
+package filter
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	md "github.com/leotaku/kojirou/mangadex"
+	"golang.org/x/text/language"
+)
+
+// Gap is a range of whole chapter numbers that are missing between two
+// chapters of a list.
+type Gap struct {
+	From int
+	To   int
+}
+
+// String formats the gap as a range that is accepted by ParseRanges.
+func (g Gap) String() string {
+	if g.From == g.To {
+		return strconv.Itoa(g.From)
+	}
+
+	return fmt.Sprintf("%v..%v", g.From, g.To)
+}
+
+// Contains reports whether a chapter belongs to the gap.
+func (g Gap) Contains(id md.Identifier) bool {
+	return !id.IsSpecial() && g.From <= id.Whole() && id.Whole() <= g.To
+}
+
+// FindGaps returns the gaps in the numbered chapters of the list, in
+// order.  Chapters before the first and after the last chapter are not
+// considered missing.
+func FindGaps(cl md.ChapterList) []Gap {
+	ids := make([]md.Identifier, 0)
+	for _, chapter := range cl {
+		if id := chapter.Info.Identifier; !id.IsSpecial() {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i].Less(ids[j])
+	})
+
+	gaps := make([]Gap, 0)
+	for i := 1; i < len(ids); i++ {
+		last, this := ids[i-1], ids[i]
+		if last.Equal(this) || last.IsNext(this) {
+			continue
+		}
+		to := this.Whole()
+		if this.Equal(md.NewIdentifier(strconv.Itoa(to))) {
+			to--
+		}
+		if from := last.Whole() + 1; from <= to {
+			gaps = append(gaps, Gap{From: from, To: to})
+		}
+	}
+
+	return gaps
+}
+
+// FillGaps returns the chapters that fill the given gaps, preferring
+// earlier languages over later ones.  All uploads of a chapter in the
+// chosen language are returned, so that they can still be ranked.
+func FillGaps(cl md.ChapterList, gaps []Gap, langs []language.Tag) md.ChapterList {
+	result := make(md.ChapterList, 0)
+	filled := make(map[md.Identifier]bool)
+	for _, lang := range langs {
+		found := make(map[md.Identifier]bool)
+		for _, chapter := range cl {
+			id := chapter.Info.Identifier
+			if chapter.Info.Language != lang || filled[id] || !inGaps(gaps, id) {
+				continue
+			}
+			found[id] = true
+			result = append(result, chapter)
+		}
+		for id := range found {
+			filled[id] = true
+		}
+	}
+
+	return result
+}
+
+func inGaps(gaps []Gap, id md.Identifier) bool {
+	for _, gap := range gaps {
+		if gap.Contains(id) {
+			return true
+		}
+	}
+
+	return false
+}
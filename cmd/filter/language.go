@@ -0,0 +1,62 @@
+package filter
+
+import (
+	md "github.com/leotaku/kojirou/mangadex"
+	"golang.org/x/text/language"
+)
+
+// MatchLanguages resolves languages without a region to the closest
+// language that chapters are available in, such as "pt-BR" for "pt",
+// so that regional variants need not be spelled out.  Languages that
+// are available, have a region or have no close match are kept as they
+// are.
+func MatchLanguages(cl md.ChapterList, langs []language.Tag) []language.Tag {
+	available := make([]language.Tag, 0)
+	seen := make(map[language.Tag]bool)
+	for _, chapter := range cl {
+		if lang := chapter.Info.Language; !seen[lang] {
+			available = append(available, lang)
+			seen[lang] = true
+		}
+	}
+	if len(available) == 0 {
+		return langs
+	}
+
+	matcher := language.NewMatcher(available)
+	result := make([]language.Tag, 0, len(langs))
+	for _, lang := range langs {
+		if _, explicit := lang.Region(); seen[lang] || explicit == language.Exact {
+			result = append(result, lang)
+		} else if _, i, confidence := matcher.Match(lang); confidence >= language.High {
+			result = append(result, available[i])
+		} else {
+			result = append(result, lang)
+		}
+	}
+
+	return result
+}
+
+// SelectLanguages returns all uploads of every chapter in the first of
+// the given languages that the chapter is available in, so that earlier
+// languages are preferred over later ones.
+func SelectLanguages(cl md.ChapterList, langs []language.Tag) md.ChapterList {
+	rank := make(map[language.Tag]int)
+	for i := len(langs) - 1; i >= 0; i-- {
+		rank[langs[i]] = i
+	}
+
+	best := make(map[md.Identifier]int)
+	for _, chapter := range cl {
+		r, ok := rank[chapter.Info.Language]
+		if b, seen := best[chapter.Info.Identifier]; ok && (!seen || r < b) {
+			best[chapter.Info.Identifier] = r
+		}
+	}
+
+	return cl.FilterBy(func(ci md.ChapterInfo) bool {
+		r, ok := rank[ci.Language]
+		return ok && r == best[ci.Identifier]
+	})
+}
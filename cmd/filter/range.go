@@ -40,12 +40,13 @@ type singleRange struct {
 	end   *md.Identifier
 }
 
+// parseRangeList parses a comma-separated list of identifiers and
+// ranges.  Ranges are written as "start..end", or as "start-end" if
+// both ends are numbers, so that "1-3,5" selects 1, 2, 3 and 5.
 func parseRangeList(s string) []singleRange {
 	ranges := make([]singleRange, 0)
 	for _, rangeExpr := range strings.Split(s, ",") {
-		if startAndEnd := strings.Split(rangeExpr, ".."); len(startAndEnd) == 2 {
-			start := md.NewIdentifier(startAndEnd[0])
-			end := md.NewIdentifier(startAndEnd[1])
+		if start, end, ok := splitRange(rangeExpr); ok {
 			ranges = append(ranges, singleRange{
 				start: start,
 				end:   &end,
@@ -61,6 +62,20 @@ func parseRangeList(s string) []singleRange {
 	return ranges
 }
 
+func splitRange(rangeExpr string) (md.Identifier, md.Identifier, bool) {
+	if start, end, ok := strings.Cut(rangeExpr, ".."); ok {
+		return md.NewIdentifier(start), md.NewIdentifier(end), true
+	}
+
+	start, end, ok := strings.Cut(rangeExpr, "-")
+	startID, endID := md.NewIdentifier(start), md.NewIdentifier(end)
+	if !ok || startID.IsSpecial() || endID.IsSpecial() {
+		return md.Identifier{}, md.Identifier{}, false
+	}
+
+	return startID, endID, true
+}
+
 func (r *singleRange) contains(id md.Identifier) bool {
 	if r.end != nil {
 		return r.start.LessOrEqual(id) && id.LessOrEqual(*r.end)
@@ -0,0 +1,304 @@
+package archive
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/leotaku/kojirou/cmd/formats"
+	"github.com/leotaku/kojirou/codec"
+	md "github.com/leotaku/kojirou/mangadex"
+	"github.com/nwaples/rardecode"
+	"golang.org/x/text/language"
+)
+
+var (
+	volumePattern = regexp.MustCompile(`(?i)(?:^|[^a-z])(?:v|vol|volume)\.?\s*([0-9]+(?:\.[0-9]+)?)`)
+	numberPattern = regexp.MustCompile(`[0-9]+(?:\.[0-9]+)?`)
+)
+
+type Archive struct {
+	Info     ComicInfo
+	Volume   md.Identifier
+	Chapters md.ChapterList
+	Pages    md.ImageList
+	Cover    image.Image
+}
+
+// ComicInfo is the metadata stored in the ComicInfo.xml file of comic
+// archives.  Fields are ordered as required by the schema.
+type ComicInfo struct {
+	XMLName     xml.Name    `xml:"ComicInfo"`
+	Title       string      `xml:",omitempty"`
+	Series      string      `xml:",omitempty"`
+	Number      string      `xml:",omitempty"`
+	Volume      string      `xml:",omitempty"`
+	Summary     string      `xml:",omitempty"`
+	Year        int         `xml:",omitempty"`
+	Month       int         `xml:",omitempty"`
+	Day         int         `xml:",omitempty"`
+	Writer      string      `xml:",omitempty"`
+	Penciller   string      `xml:",omitempty"`
+	Translator  string      `xml:",omitempty"`
+	Publisher   string      `xml:",omitempty"`
+	Genre       string      `xml:",omitempty"`
+	PageCount   int         `xml:",omitempty"`
+	LanguageISO string      `xml:",omitempty"`
+	Manga       string      `xml:",omitempty"`
+	Pages       *ComicPages `xml:",omitempty"`
+}
+
+type ComicPages struct {
+	Page []ComicPage
+}
+
+// ComicPage describes the page at the given index, in the order of
+// images in the archive.
+type ComicPage struct {
+	Image int    `xml:",attr"`
+	Type  string `xml:",attr,omitempty"`
+}
+
+func (c ComicInfo) Authors() []string {
+	return splitList(c.Writer)
+}
+
+func (c ComicInfo) Artists() []string {
+	return splitList(c.Penciller)
+}
+
+type entry struct {
+	name string
+	data []byte
+}
+
+// Load reads all pages from a CBZ or CBR archive.  Every archive is
+// treated as a single volume, with subdirectories becoming chapters.
+func Load(filename string, p formats.Progress) (*Archive, error) {
+	var entries []entry
+	var err error
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".cbz", ".zip":
+		entries, err = readZip(filename)
+	case ".cbr", ".rar":
+		entries, err = readRar(filename)
+	default:
+		return nil, fmt.Errorf("unsupported archive: %v", filepath.Ext(filename))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read: %w", err)
+	}
+
+	info := ComicInfo{}
+	images := make([]entry, 0)
+	covers := make([]entry, 0)
+	for _, e := range entries {
+		switch {
+		case strings.EqualFold(path.Base(e.name), "ComicInfo.xml"):
+			if err := xml.Unmarshal(e.data, &info); err != nil {
+				return nil, fmt.Errorf("comicinfo: %w", err)
+			}
+		case isImage(e.name) && isCover(e.name):
+			covers = append(covers, e)
+		case isImage(e.name):
+			images = append(images, e)
+		}
+	}
+	if len(images) == 0 {
+		images, covers = covers, nil
+	}
+	sort.SliceStable(images, func(i, j int) bool {
+		return md.NaturalLess(images[i].name, images[j].name)
+	})
+
+	stem := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	result := &Archive{
+		Info:   info,
+		Volume: volumeIdentifier(info, stem),
+	}
+
+	lang := language.Und
+	if info.LanguageISO != "" {
+		lang = language.Make(info.LanguageISO)
+	}
+
+	if len(covers) > 0 {
+		decoded, err := codec.DecodeBytes(covers[0].data)
+		if err != nil {
+			return nil, fmt.Errorf("decode '%v': %w", covers[0].name, err)
+		}
+		result.Cover = decoded
+	}
+
+	p.Increase(len(images))
+	chapterIndex := make(map[string]md.Identifier)
+	pageIndex := make(map[string]int)
+	for _, img := range images {
+		dir := path.Dir(img.name)
+		chapterID, ok := chapterIndex[dir]
+		if !ok {
+			chapterID = chapterIdentifier(info, dir, len(chapterIndex))
+			chapterIndex[dir] = chapterID
+			result.Chapters = append(result.Chapters, md.Chapter{
+				Info: md.ChapterInfo{
+					Title:            chapterTitle(info, dir),
+					Language:         lang,
+					GroupNames:       splitList(info.Translator),
+					ID:               path.Join(filename, dir),
+					Identifier:       chapterID,
+					VolumeIdentifier: result.Volume,
+				},
+				Pages: make(map[int]image.Image),
+			})
+		}
+
+		decoded, err := codec.DecodeBytes(img.data)
+		if err != nil {
+			return nil, fmt.Errorf("decode '%v': %w", img.name, err)
+		}
+		if result.Cover == nil {
+			result.Cover = decoded
+		}
+
+		result.Pages = append(result.Pages, md.Image{
+			Image:             decoded,
+			ImageIdentifier:   pageIndex[dir],
+			ChapterIdentifier: chapterID,
+			VolumeIdentifier:  result.Volume,
+		})
+		pageIndex[dir]++
+		p.Add(1)
+	}
+
+	if len(result.Pages) == 0 {
+		return nil, fmt.Errorf("no pages")
+	}
+
+	return result, nil
+}
+
+func readZip(filename string) ([]entry, error) {
+	r, err := zip.OpenReader(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	result := make([]entry, 0)
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, entry{f.Name, data})
+	}
+
+	return result, nil
+}
+
+func readRar(filename string) ([]entry, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r, err := rardecode.NewReader(f, "")
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]entry, 0)
+	for {
+		header, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return result, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if header.IsDir {
+			continue
+		}
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, entry{header.Name, data})
+	}
+}
+
+func volumeIdentifier(info ComicInfo, stem string) md.Identifier {
+	if info.Volume != "" {
+		return md.NewIdentifier(info.Volume)
+	} else if m := volumePattern.FindStringSubmatch(stem); m != nil {
+		return md.NewIdentifier(m[1])
+	} else if ms := numberPattern.FindAllString(stem, -1); len(ms) > 0 {
+		return md.NewIdentifier(ms[len(ms)-1])
+	} else {
+		return md.NewIdentifier(stem)
+	}
+}
+
+func chapterIdentifier(info ComicInfo, dir string, index int) md.Identifier {
+	switch {
+	case dir != ".":
+		if m := numberPattern.FindString(path.Base(dir)); m != "" {
+			return md.NewIdentifier(m)
+		}
+		return md.NewIdentifier(path.Base(dir))
+	case info.Number != "":
+		return md.NewIdentifier(info.Number)
+	default:
+		return md.NewIdentifier(strconv.Itoa(index + 1))
+	}
+}
+
+func chapterTitle(info ComicInfo, dir string) string {
+	if dir == "." {
+		return info.Title
+	}
+
+	return path.Base(dir)
+}
+
+func isImage(name string) bool {
+	return codec.Default.HasExtension(path.Ext(name)) && !strings.HasPrefix(path.Base(name), ".")
+}
+
+// isCover reports whether the image is a cover at the top level of the
+// archive, such as "cover.jpg" or "000 Cover.jpg", which is not a page.
+func isCover(name string) bool {
+	stem := strings.TrimSuffix(name, path.Ext(name))
+	stem = strings.TrimLeft(stem, "0123456789 -_")
+
+	return !strings.Contains(name, "/") && strings.EqualFold(stem, "cover")
+}
+
+func splitList(s string) []string {
+	result := make([]string, 0)
+	for _, it := range strings.Split(s, ",") {
+		if it = strings.TrimSpace(it); it != "" {
+			result = append(result, it)
+		}
+	}
+
+	return result
+}
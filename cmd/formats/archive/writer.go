@@ -0,0 +1,254 @@
+package archive
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"io"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/leotaku/kojirou/cmd/formats"
+	"github.com/leotaku/kojirou/codec"
+	md "github.com/leotaku/kojirou/mangadex"
+	"golang.org/x/text/language"
+)
+
+// originalExtensions are the file extensions of formats whose data is
+// copied into archives unmodified.  Images in other formats, and images
+// that have been modified, are encoded using the encoder of the options.
+var originalExtensions = map[string]string{
+	codec.JPEG.Name: ".jpg",
+	codec.PNG.Name:  ".png",
+	codec.GIF.Name:  ".gif",
+}
+
+// CBZOptions customizes generated comic archives.
+type CBZOptions struct {
+	LeftToRight      bool
+	FillVolumeNumber int
+	// Language overrides the language of the chapters, unless it is
+	// undetermined.
+	Language language.Tag
+	// Encoder encodes images that are not copied, which defaults to
+	// codec.DefaultEncoder.  If Reencode is set, all images are
+	// encoded.
+	Encoder  codec.Encoder
+	Reencode bool
+}
+
+// CBZWriter generates Comic Book Zip archives with a ComicInfo.xml
+// file, which comic servers such as Komga and Kavita read for metadata.
+// Every chapter is stored in its own directory, so that Load reads the
+// chapters of generated archives again.  Pages are written to a spool
+// as they are added, named after their chapter and page number.
+type CBZWriter struct {
+	options  CBZOptions
+	skeleton md.Manga
+	volume   md.Identifier
+	chapters md.ChapterList
+	spool    *formats.Spool
+	zip      *zip.Writer
+	pages    int
+	modified time.Time
+}
+
+func NewCBZWriter(options CBZOptions) *CBZWriter {
+	return &CBZWriter{options: options}
+}
+
+func (w *CBZWriter) Extension() string {
+	return ".cbz"
+}
+
+func (w *CBZWriter) Begin(manga md.Manga, volume md.Identifier) error {
+	w.Abort()
+	spool, err := formats.CreateSpool()
+	if err != nil {
+		return err
+	}
+	w.skeleton = manga
+	w.volume = volume
+	w.chapters = make(md.ChapterList, 0)
+	w.spool = spool
+	w.zip = zip.NewWriter(spool)
+	w.pages = 0
+	w.modified = formats.ZipTimestamp(manga.Volumes[volume].Sorted())
+
+	if cover := manga.Volumes[volume].Cover; cover != nil {
+		if err := w.writeImage("000 Cover", cover); err != nil {
+			return fmt.Errorf("cover: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (w *CBZWriter) AddChapter(chapter md.ChapterInfo) error {
+	w.chapters = append(w.chapters, md.Chapter{
+		Info:  chapter,
+		Pages: make(map[int]image.Image),
+	})
+
+	return nil
+}
+
+// AddPage writes the page to the spool.  Pages of chapters that are not
+// part of the volume are ignored.
+func (w *CBZWriter) AddPage(page md.Image) error {
+	if _, ok := w.skeleton.Volumes[w.volume].Chapters[page.ChapterIdentifier]; !ok {
+		return nil
+	}
+	name := fmt.Sprintf("%v/%03d", chapterDirectory(page.ChapterIdentifier), page.ImageIdentifier+1)
+	if err := w.writeImage(name, page.Image); err != nil {
+		return fmt.Errorf("image %v: %w", name, err)
+	}
+
+	return nil
+}
+
+// Finish adds the ComicInfo.xml file, which counts the written pages,
+// and copies the archive to the output.
+func (w *CBZWriter) Finish(out io.Writer) error {
+	defer w.Abort()
+	volume := w.skeleton.WithChapters(w.chapters).Volumes[w.volume]
+	info := w.comicInfo(volume, w.pages)
+	data, err := xml.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return fmt.Errorf("comicinfo: %w", err)
+	}
+
+	f, err := w.zip.CreateHeader(&zip.FileHeader{
+		Name:     "ComicInfo.xml",
+		Method:   zip.Deflate,
+		Modified: w.modified,
+	})
+	if err != nil {
+		return err
+	} else if _, err := f.Write(append([]byte(xml.Header), data...)); err != nil {
+		return err
+	} else if err := w.zip.Close(); err != nil {
+		return err
+	}
+
+	return w.spool.CopyTo(out)
+}
+
+// Abort removes the spool of an unfinished archive.
+func (w *CBZWriter) Abort() {
+	w.spool.Remove()
+}
+
+// Timestamp returns the stable modification time of the archive and
+// its entries, see formats.Timestamp.
+func (w *CBZWriter) Timestamp() time.Time {
+	return formats.ZipTimestamp(w.chapters)
+}
+
+// comicInfo maps the metadata of the volume to ComicInfo fields.  The
+// cover, if any, is the first of the given number of pages.
+func (w *CBZWriter) comicInfo(volume md.Volume, pageCount int) ComicInfo {
+	info := w.skeleton.Info
+	result := ComicInfo{
+		Title:     fmt.Sprintf("%v: %v", info.Title, w.volume.StringFilled(w.options.FillVolumeNumber, 0, false)),
+		Series:    info.SeriesName(),
+		Summary:   info.Description,
+		Writer:    strings.Join(info.Authors, ", "),
+		Penciller: strings.Join(info.Artists, ", "),
+		Publisher: info.Publisher,
+		Genre:     strings.Join(info.Tags, ", "),
+		PageCount: pageCount,
+		Manga:     "YesAndRightToLeft",
+	}
+	if lang := w.language(); lang != language.Und {
+		result.LanguageISO = lang.String()
+	}
+	if !w.volume.IsSpecial() {
+		result.Number = w.volume.String()
+		result.Volume = w.volume.String()
+	}
+	if w.options.LeftToRight {
+		result.Manga = "Yes"
+	}
+
+	groups := make([]string, 0)
+	seen := make(map[string]bool)
+	var published time.Time
+	for _, chapter := range volume.Sorted() {
+		for _, group := range chapter.Info.GroupNames {
+			if !seen[group] {
+				groups = append(groups, group)
+				seen[group] = true
+			}
+		}
+		if t := chapter.Info.Published; !t.IsZero() && (published.IsZero() || t.Before(published)) {
+			published = t
+		}
+	}
+	result.Translator = strings.Join(groups, ", ")
+	if !published.IsZero() {
+		result.Year, result.Month, result.Day = published.Year(), int(published.Month()), published.Day()
+	}
+	if volume.Cover != nil {
+		result.Pages = &ComicPages{Page: []ComicPage{{Image: 0, Type: "FrontCover"}}}
+	}
+
+	return result
+}
+
+func (w *CBZWriter) language() language.Tag {
+	if w.options.Language != language.Und {
+		return w.options.Language
+	}
+	for _, chapter := range w.chapters {
+		if chapter.Info.Language != language.Und {
+			return chapter.Info.Language
+		}
+	}
+
+	return language.Und
+}
+
+// chapterDirectory returns the name of the directory for the pages of
+// a chapter, which sorts numbered chapters in order.
+func chapterDirectory(identifier md.Identifier) string {
+	name := identifier.StringFilled(4, 0, false)
+	name = strings.NewReplacer("/", "_", "\\", "_").Replace(name)
+
+	return "Chapter " + name
+}
+
+// writeImage adds the image to the archive, copying the original data
+// if the image is unmodified.  Images are stored without compression,
+// as they are already compressed.
+func (w *CBZWriter) writeImage(name string, img image.Image) error {
+	data, ext := []byte(nil), ""
+	if encoded, ok := codec.Original(img); ok && originalExtensions[encoded.Format] != "" && !w.options.Reencode {
+		data, ext = encoded.Data, originalExtensions[encoded.Format]
+	} else {
+		encoded, format, err := codec.Encode(w.options.Encoder, img)
+		if err != nil {
+			return err
+		}
+		data, ext = encoded, originalExtensions[format]
+		if ext == "" {
+			ext = "." + format
+		}
+	}
+
+	f, err := w.zip.CreateHeader(&zip.FileHeader{
+		Name:     name + ext,
+		Method:   zip.Store,
+		Modified: w.modified,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	runtime.KeepAlive(img)
+	w.pages++
+
+	return err
+}
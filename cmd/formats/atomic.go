@@ -0,0 +1,72 @@
+package formats
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// AtomicFile is written under a temporary name next to its target,
+// which is only replaced once the file is committed.  Interrupted runs
+// therefore never leave truncated files, such as e-books that devices
+// fail to open.
+type AtomicFile struct {
+	*os.File
+	pathname string
+}
+
+// CreateAtomic creates the directory of the target and a temporary
+// file in it.  Temporary files are hidden and do not have the extension
+// of the target, so they are not picked up by e-book readers.
+func CreateAtomic(pathname string) (*AtomicFile, error) {
+	if err := os.MkdirAll(filepath.Dir(pathname), os.ModePerm); err != nil {
+		return nil, fmt.Errorf("directory: %w", err)
+	}
+	f, err := os.CreateTemp(filepath.Dir(pathname), "."+filepath.Base(pathname)+".tmp-*")
+	if err != nil {
+		return nil, fmt.Errorf("file: %w", err)
+	}
+
+	return &AtomicFile{File: f, pathname: pathname}, nil
+}
+
+// Commit flushes the file to disk and moves it to the target, replacing
+// any existing file.
+func (f *AtomicFile) Commit() error {
+	err := f.Chmod(0o644)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.File.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), f.pathname)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+
+	return err
+}
+
+// Abort removes the temporary file, leaving any existing target intact.
+func (f *AtomicFile) Abort() {
+	f.File.Close()
+	os.Remove(f.Name())
+}
+
+// WriteFileAtomic writes the data to the file like os.WriteFile, but
+// never leaves a partially written file behind.
+func WriteFileAtomic(pathname string, data []byte) error {
+	f, err := CreateAtomic(pathname)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Abort()
+		return err
+	}
+
+	return f.Commit()
+}
@@ -0,0 +1,45 @@
+package disk
+
+import (
+	"context"
+
+	"github.com/leotaku/kojirou/cmd/formats"
+	md "github.com/leotaku/kojirou/mangadex"
+	"golang.org/x/text/language"
+)
+
+// Provider loads a manga from a directory, which contains a directory
+// for every volume containing a directory for every chapter.
+type Provider struct {
+	directory string
+	lang      language.Tag
+}
+
+func NewProvider(directory string, lang language.Tag) *Provider {
+	return &Provider{directory: directory, lang: lang}
+}
+
+func (d *Provider) Name() string {
+	return "Disk"
+}
+
+func (d *Provider) FetchSeries(ctx context.Context) (*md.Manga, error) {
+	return LoadSkeleton(d.directory)
+}
+
+func (d *Provider) FetchChapters(ctx context.Context, manga md.Manga, p formats.Progress) (md.ChapterList, error) {
+	chapters, err := LoadChapters(d.directory, d.lang, p)
+	if err != nil {
+		return nil, err
+	}
+
+	return formats.WithSource(chapters, d), nil
+}
+
+func (d *Provider) FetchCovers(ctx context.Context, manga md.Manga, p formats.Progress) (md.ImageList, error) {
+	return LoadCovers(d.directory, manga, p)
+}
+
+func (d *Provider) FetchPages(ctx context.Context, cl md.ChapterList, p formats.Progress) (md.ImageList, error) {
+	return LoadPages(cl, p)
+}
@@ -4,23 +4,26 @@ import (
 	"errors"
 	"fmt"
 	"image"
-	_ "image/gif"
-	_ "image/jpeg"
-	_ "image/png"
+	"io"
 	"io/fs"
 	"os"
-	"path"
+	"path/filepath"
 	"strconv"
 	"strings"
 
 	"github.com/leotaku/kojirou/cmd/formats"
+	"github.com/leotaku/kojirou/codec"
 	md "github.com/leotaku/kojirou/mangadex"
 	"golang.org/x/text/language"
 )
 
 func LoadSkeleton(directory string) (*md.Manga, error) {
+	abs, err := filepath.Abs(directory)
+	if err != nil {
+		return nil, fmt.Errorf("resolve '%v': %w", directory, err)
+	}
 	info := md.MangaInfo{
-		Title: path.Base(directory),
+		Title: filepath.Base(abs),
 	}
 
 	return &md.Manga{
@@ -39,7 +42,7 @@ func LoadChapters(directory string, lang language.Tag, p formats.Progress) (md.C
 		if !volume.IsDir() {
 			continue
 		}
-		chapters, err := os.ReadDir(path.Join(directory, volume.Name()))
+		chapters, err := os.ReadDir(filepath.Join(directory, volume.Name()))
 		if err != nil {
 			return nil, fmt.Errorf("list '%v': %w", directory, err)
 		}
@@ -50,12 +53,20 @@ func LoadChapters(directory string, lang language.Tag, p formats.Progress) (md.C
 			p.Increase(1)
 			p.Add(1)
 
+			pages, err := os.ReadDir(filepath.Join(directory, volume.Name(), chapter.Name()))
+			if err != nil {
+				return nil, fmt.Errorf("list '%v': %w", chapter.Name(), err)
+			}
+
+			id, title := splitChapterName(chapter.Name())
 			info := md.ChapterInfo{
-				Identifier:       md.NewIdentifier(chapter.Name()),
+				Title:            title,
+				Identifier:       md.NewIdentifier(id),
 				VolumeIdentifier: md.NewIdentifier(volume.Name()),
 				GroupNames:       []string{"Filesystem"},
 				Language:         lang,
-				ID:               path.Join(directory, volume.Name(), chapter.Name()),
+				Pages:            len(pages),
+				ID:               filepath.Join(directory, volume.Name(), chapter.Name()),
 			}
 			result = append(result, md.Chapter{
 				Info:  info,
@@ -79,16 +90,21 @@ func LoadPages(cl md.ChapterList, p formats.Progress) (md.ImageList, error) {
 		for _, page := range pages {
 			p.Add(1)
 
-			id, err := strconv.Atoi(strings.TrimSuffix(page.Name(), path.Ext(page.Name())))
+			id, err := strconv.Atoi(strings.TrimSuffix(page.Name(), filepath.Ext(page.Name())))
 			if err != nil {
 				continue
 			}
 
-			f, err := os.Open(path.Join(chap.Info.ID, page.Name()))
+			f, err := os.Open(filepath.Join(chap.Info.ID, page.Name()))
+			if err != nil {
+				return nil, err
+			}
+			data, err := io.ReadAll(p.NewProxyReader(f))
+			f.Close()
 			if err != nil {
 				return nil, err
 			}
-			img, _, err := image.Decode(f)
+			img, err := codec.DecodeBytes(data)
 			if err != nil {
 				return nil, err
 			}
@@ -105,7 +121,8 @@ func LoadPages(cl md.ChapterList, p formats.Progress) (md.ImageList, error) {
 	return result, nil
 }
 
-func LoadCovers(directory string, p formats.Progress) (md.ImageList, error) {
+// LoadCovers reads the covers of all volumes of the manga.
+func LoadCovers(directory string, manga md.Manga, p formats.Progress) (md.ImageList, error) {
 	result := make(md.ImageList, 0)
 	volumes, err := os.ReadDir(directory)
 	if err != nil {
@@ -115,9 +132,14 @@ func LoadCovers(directory string, p formats.Progress) (md.ImageList, error) {
 	for _, volume := range volumes {
 		if !volume.IsDir() {
 			continue
+		} else if _, ok := manga.Volumes[md.NewIdentifier(volume.Name())]; !ok {
+			continue
 		}
 
-		img, err := readImage(directory, volume.Name())
+		img, err := readImage(filepath.Join(directory, volume.Name()), "cover")
+		if errors.Is(err, fs.ErrNotExist) {
+			img, err = readImage(directory, volume.Name())
+		}
 		if errors.Is(err, fs.ErrNotExist) {
 			continue
 		} else if err != nil {
@@ -133,16 +155,16 @@ func LoadCovers(directory string, p formats.Progress) (md.ImageList, error) {
 }
 
 func readImage(directory, name string) (image.Image, error) {
-	for _, ext := range []string{".jpg", ".jpeg", ".png", ".gif"} {
-		f, err := os.Open(path.Join(directory, name+ext))
+	for _, ext := range codec.Default.Extensions() {
+		data, err := os.ReadFile(filepath.Join(directory, name+ext))
 		if errors.Is(err, fs.ErrNotExist) {
 			continue
 		} else if err != nil {
 			return nil, fmt.Errorf("open: %w", err)
 		} else {
-			img, _, err := image.Decode(f)
+			img, err := codec.DecodeBytes(data)
 			if err != nil {
-				return nil, fmt.Errorf("decode: %w", err)
+				return nil, &md.DecodeError{Err: err}
 			} else {
 				return img, nil
 			}
@@ -151,3 +173,11 @@ func readImage(directory, name string) (image.Image, error) {
 
 	return nil, fs.ErrNotExist
 }
+
+func splitChapterName(name string) (id, title string) {
+	if split := strings.SplitN(name, ":", 2); len(split) == 2 {
+		return strings.TrimSpace(split[0]), strings.TrimSpace(split[1])
+	}
+
+	return name, ""
+}
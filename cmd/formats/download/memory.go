@@ -0,0 +1,99 @@
+package download
+
+import (
+	"context"
+	"image"
+	"sync"
+
+	"github.com/leotaku/kojirou/cmd/formats"
+	"github.com/leotaku/kojirou/codec"
+)
+
+// pageEstimate is the memory reserved for a page before its size is
+// known, which is generous for the pages served by MangaDex.
+const pageEstimate = 4 << 20
+
+// MemoryBudget limits concurrent image downloads by the memory used by
+// the pages being downloaded.  Every download reserves an estimate of
+// its size before it starts, which is replaced by the size of the page
+// once it has been received.  New downloads wait until enough memory
+// has been released, so that fewer workers run at once.  A single
+// download is always allowed to make progress.
+type MemoryBudget struct {
+	limit int64
+	used  int64
+	freed chan struct{}
+	mutex sync.Mutex
+}
+
+func NewMemoryBudget(limit int64) *MemoryBudget {
+	return &MemoryBudget{limit: limit, freed: make(chan struct{})}
+}
+
+// acquire reserves the given number of bytes, waiting until they fit
+// into the budget.
+func (b *MemoryBudget) acquire(ctx context.Context, n int64) error {
+	if b == nil {
+		return nil
+	}
+
+	throttled := false
+	for {
+		b.mutex.Lock()
+		if b.used == 0 || b.used+n <= b.limit {
+			b.used += n
+			b.mutex.Unlock()
+			return nil
+		}
+		freed := b.freed
+		if !throttled {
+			formats.Debug("Throttling downloads", "used", b.used, "budget", b.limit)
+			throttled = true
+		}
+		b.mutex.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-freed:
+		}
+	}
+}
+
+// resize replaces a reservation of old bytes by one of n bytes without
+// waiting, as the memory is already in use, and returns n.
+func (b *MemoryBudget) resize(old, n int64) int64 {
+	if b != nil {
+		b.change(n - old)
+	}
+
+	return n
+}
+
+// release returns the given number of bytes to the budget.
+func (b *MemoryBudget) release(n int64) {
+	if b != nil {
+		b.change(-n)
+	}
+}
+
+func (b *MemoryBudget) change(delta int64) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.used += delta
+	if delta < 0 {
+		close(b.freed)
+		b.freed = make(chan struct{})
+	}
+}
+
+// imageSize returns the memory used by an image, which is the size of
+// its data for images that have not been decoded yet.
+func imageSize(img image.Image) int64 {
+	if encoded, ok := codec.Original(img); ok {
+		return int64(len(encoded.Data))
+	}
+	size := img.Bounds().Size()
+
+	return int64(size.X) * int64(size.Y) * 4
+}
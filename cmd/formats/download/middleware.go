@@ -0,0 +1,217 @@
+package download
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/leotaku/kojirou/cmd/formats"
+	"github.com/leotaku/kojirou/cmd/metrics"
+	"github.com/leotaku/kojirou/mangadex/api"
+)
+
+// Middleware wraps a transport to add behaviour to every request, such
+// as retrying, rate limiting or adding headers.
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc adapts a function to the http.RoundTripper
+// interface, which is convenient for writing middleware.
+type RoundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Chain wraps the base transport, or the default transport if nil, in
+// the given middleware.  The first middleware sees requests first.
+func Chain(base http.RoundTripper, middleware ...Middleware) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	for i := len(middleware) - 1; i >= 0; i-- {
+		base = middleware[i](base)
+	}
+
+	return base
+}
+
+// DefaultMiddleware returns the middleware used by NewDownloader, which
+// always respects the rate limits of the MangaDex API.  The limiter is
+// optional and limits all requests.  Custom middleware appended to the
+// result runs once for every attempt of a request.
+func DefaultMiddleware(options RetryOptions, limiter Limiter) []Middleware {
+	middleware := []Middleware{Retry(options), MangadexRateLimit(api.DefaultRateLimiter)}
+	if limiter != nil {
+		middleware = append(middleware, RateLimit(limiter))
+	}
+
+	return append(middleware, Metrics(), Trace())
+}
+
+// Limiter delays requests to respect rate limits, and is implemented
+// by *rate.Limiter from golang.org/x/time/rate.
+type Limiter interface {
+	Wait(ctx context.Context) error
+}
+
+// RateLimit waits for the limiter before every request.
+func RateLimit(limiter Limiter) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if err := limiter.Wait(req.Context()); err != nil {
+				return nil, err
+			}
+
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// MangadexRateLimit waits for the given limiter before every request to
+// the MangaDex API and pauses endpoints whose limit the server reports
+// as exhausted.  The limiter should be shared by all clients, such as
+// api.DefaultRateLimiter, as MangaDex limits requests per address.
+func MangadexRateLimit(limiter *api.RateLimiter) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return limiter.Transport(next)
+	}
+}
+
+// RetryOptions control how failed requests are retried.  A maximum of
+// zero retries disables retrying.
+type RetryOptions struct {
+	Max     int
+	WaitMin time.Duration
+	WaitMax time.Duration
+}
+
+func DefaultRetryOptions() RetryOptions {
+	return RetryOptions{
+		Max:     4,
+		WaitMin: time.Second * 5,
+		WaitMax: time.Second * 30,
+	}
+}
+
+// Retry repeats requests that failed because of connection errors or
+// server errors, including rate limiting.  Rate limited requests are
+// repeated once the server allows it, even if that is after the
+// maximum wait.
+func Retry(options RetryOptions) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		retry := retryablehttp.NewClient()
+		retry.HTTPClient = &http.Client{Transport: next}
+		retry.Logger = nil
+		retry.RetryMax = options.Max
+		retry.RetryWaitMin = options.WaitMin
+		retry.RetryWaitMax = options.WaitMax
+		retry.Backoff = backoff
+		retry.ErrorHandler = retryablehttp.PassthroughErrorHandler
+		retry.CheckRetry = checkRetry
+		retry.RequestLogHook = func(_ retryablehttp.Logger, req *http.Request, attempt int) {
+			if attempt > 0 {
+				metrics.Retries.Add(1)
+				formats.Debug("Retrying request", "url", req.URL, "attempt", attempt+1)
+			}
+		}
+
+		return &retryablehttp.RoundTripper{Client: retry}
+	}
+}
+
+type noRetryKey struct{}
+
+// withoutRetry marks requests that should not be repeated by Retry,
+// except when rate limited, as failing them is cheaper than waiting.
+// Pages on MangaDex@Home nodes are requested from another node instead.
+func withoutRetry(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noRetryKey{}, true)
+}
+
+func checkRetry(ctx context.Context, resp *http.Response, err error) (bool, error) {
+	if disabled, _ := ctx.Value(noRetryKey{}).(bool); disabled {
+		if resp == nil || resp.StatusCode != http.StatusTooManyRequests {
+			return false, nil
+		}
+	}
+
+	return retryablehttp.DefaultRetryPolicy(ctx, resp, err)
+}
+
+// backoff waits as long as the server asks for rate limited responses,
+// and linearly longer after every other failed attempt.
+func backoff(waitMin, waitMax time.Duration, attempt int, resp *http.Response) time.Duration {
+	if resp != nil && (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable) {
+		if until, ok := api.RetryAfter(resp); ok {
+			formats.Debug("Rate limited", "url", resp.Request.URL, "until", until)
+			return max(time.Until(until), 0)
+		}
+	}
+
+	return retryablehttp.LinearJitterBackoff(waitMin, waitMax, attempt, resp)
+}
+
+// Headers sets the given headers on every request that does not
+// already have them, for example to change the User-Agent.
+func Headers(headers http.Header) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			req = req.Clone(req.Context())
+			for key, values := range headers {
+				if req.Header.Get(key) == "" {
+					req.Header[http.CanonicalHeaderKey(key)] = values
+				}
+			}
+
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// Metrics records the latency and size of every response.
+func Metrics() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			resp, err := next.RoundTrip(req)
+			metrics.RequestDuration.Observe(req.URL.Host, time.Since(start).Seconds())
+			if err != nil {
+				return nil, err
+			}
+			resp.Body = &measuredBody{resp.Body}
+
+			return resp, nil
+		})
+	}
+}
+
+type measuredBody struct {
+	io.ReadCloser
+}
+
+func (b *measuredBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	metrics.BytesDownloaded.Add(float64(n))
+
+	return n, err
+}
+
+// Trace logs every request with its outcome and latency at the debug
+// level.
+func Trace() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			resp, err := next.RoundTrip(req)
+			if err != nil {
+				formats.Debug("Request failed", "method", req.Method, "url", req.URL, "error", err)
+			} else {
+				formats.Debug("Request done", "method", req.Method, "url", req.URL, "status", resp.StatusCode, "duration", time.Since(start))
+			}
+
+			return resp, err
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/leotaku/kojirou/cmd/formats"
+	"github.com/leotaku/kojirou/cmd/metrics"
+)
+
+// ReportURL is the MangaDex@Home endpoint that receives the outcome of
+// every page downloaded from a node, which MangaDex uses to detect and
+// remove unhealthy nodes.
+const ReportURL = "https://api.mangadex.network/report"
+
+// nodeReport is the outcome of downloading a page from a MangaDex@Home
+// node, in the format expected by ReportURL.
+type nodeReport struct {
+	URL      string `json:"url"`
+	Success  bool   `json:"success"`
+	Cached   bool   `json:"cached"`
+	Bytes    int    `json:"bytes"`
+	Duration int64  `json:"duration"`
+}
+
+// nodeHealth counts the failures of MangaDex@Home nodes and remembers
+// the node that replaced a failed node for every chapter, so that the
+// remaining pages of the chapter skip the failed node.
+type nodeHealth struct {
+	failures map[string]int
+	bases    map[string]string
+	mutex    sync.Mutex
+}
+
+func newNodeHealth() *nodeHealth {
+	return &nodeHealth{
+		failures: make(map[string]int),
+		bases:    make(map[string]string),
+	}
+}
+
+// fail records a failure of the node and returns the number of its
+// failures so far.
+func (h *nodeHealth) fail(node string) int {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.failures[node]++
+
+	return h.failures[node]
+}
+
+func (h *nodeHealth) base(chapterID string) (string, bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	base, ok := h.bases[chapterID]
+
+	return base, ok
+}
+
+func (h *nodeHealth) setBase(chapterID, base string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.bases[chapterID] = base
+}
+
+// atHomeNode returns the host of the MangaDex@Home node serving the
+// URL.  Pages served by MangaDex itself are not served by a node.
+func atHomeNode(rawURL string) (string, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" || strings.Contains(u.Host, "mangadex.org") {
+		return "", false
+	}
+
+	return u.Host, true
+}
+
+// rebase returns the URL of the page on the server with the given base
+// URL, keeping the quality, chapter hash and filename.
+func rebase(rawURL, base string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	page, ok := pagePath(u)
+	if !ok {
+		return "", fmt.Errorf("not a MangaDex@Home page: %v", rawURL)
+	}
+
+	return strings.TrimSuffix(base, "/") + page, nil
+}
+
+// pagePath returns the path of a page below the base URL of the server,
+// starting with its quality.
+func pagePath(u *url.URL) (string, bool) {
+	for _, marker := range []string{"/data/", "/data-saver/"} {
+		if i := strings.Index(u.Path, marker); i >= 0 {
+			return u.Path[i:], true
+		}
+	}
+
+	return "", false
+}
+
+// nodeURL returns the URL of the page on the node that replaced the
+// node of the URL for the chapter, if any.
+func (d *Downloader) nodeURL(chapterID, rawURL string) string {
+	base, ok := d.nodes.base(chapterID)
+	if !ok {
+		return rawURL
+	} else if replaced, err := rebase(rawURL, base); err == nil {
+		return replaced
+	}
+
+	return rawURL
+}
+
+// failover records the failure of the node that served the URL and
+// requests the node that MangaDex@Home now assigns to the chapter.  The
+// returned URL points to the page on that node, which may still be the
+// failed node if MangaDex@Home has not noticed its failure yet.
+func (d *Downloader) failover(ctx context.Context, chapterID, rawURL string, cause error) (string, error) {
+	node, _ := atHomeNode(rawURL)
+	failures := d.nodes.fail(node)
+	metrics.NodeFailures.Add(1)
+	formats.Debug("MangaDex@Home node failed", "node", node, "failures", failures, "error", cause)
+
+	if current := d.nodeURL(chapterID, rawURL); current != rawURL {
+		return current, nil
+	}
+	base, err := d.mangadexClient.FetchBaseURL(ctx, chapterID)
+	if err != nil {
+		return "", fmt.Errorf("failover: %w", err)
+	}
+	d.nodes.setBase(chapterID, base)
+	formats.Debug("Switching MangaDex@Home node", "chapter", chapterID, "from", node, "to", base)
+
+	return rebase(rawURL, base)
+}
+
+// report sends the outcome of downloading a page from a node to
+// ReportURL in the background.  Reports are not retried, as failing to
+// send them does not affect the download.
+func (d *Downloader) report(r nodeReport) {
+	if _, ok := atHomeNode(r.URL); !ok {
+		return
+	}
+	body, err := json.Marshal(r)
+	if err != nil {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(withoutRetry(context.Background()), time.Second*10)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, "POST", ReportURL, bytes.NewReader(body))
+		if err != nil {
+			return
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := d.httpClient.Do(req)
+		if err != nil {
+			formats.Debug("Reporting to MangaDex@Home failed", "url", r.URL, "error", err)
+			return
+		}
+		resp.Body.Close()
+	}()
+}
@@ -0,0 +1,43 @@
+package download
+
+import (
+	"context"
+
+	"github.com/leotaku/kojirou/cmd/formats"
+	md "github.com/leotaku/kojirou/mangadex"
+)
+
+// MangadexProvider fetches a single manga from MangaDex.
+type MangadexProvider struct {
+	downloader *Downloader
+	mangaID    string
+}
+
+func NewMangadexProvider(d *Downloader, mangaID string) *MangadexProvider {
+	return &MangadexProvider{downloader: d, mangaID: mangaID}
+}
+
+func (m *MangadexProvider) Name() string {
+	return "MangaDex"
+}
+
+func (m *MangadexProvider) FetchSeries(ctx context.Context) (*md.Manga, error) {
+	return m.downloader.MangadexSkeleton(ctx, m.mangaID)
+}
+
+func (m *MangadexProvider) FetchChapters(ctx context.Context, manga md.Manga, p formats.Progress) (md.ChapterList, error) {
+	chapters, err := m.downloader.MangadexChapters(ctx, m.mangaID)
+	if err != nil {
+		return nil, err
+	}
+
+	return formats.WithSource(chapters, m), nil
+}
+
+func (m *MangadexProvider) FetchCovers(ctx context.Context, manga md.Manga, p formats.Progress) (md.ImageList, error) {
+	return m.downloader.MangadexCovers(ctx, &manga, p)
+}
+
+func (m *MangadexProvider) FetchPages(ctx context.Context, cl md.ChapterList, p formats.Progress) (md.ImageList, error) {
+	return m.downloader.MangadexPages(ctx, cl, p)
+}
@@ -2,85 +2,349 @@ package download
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"image"
-	_ "image/gif"
-	_ "image/jpeg"
-	_ "image/png"
+	"io"
 	"net/http"
+	"net/url"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
-	"github.com/hashicorp/go-retryablehttp"
+	"github.com/leotaku/kojirou/cmd/cache"
+	"github.com/leotaku/kojirou/cmd/events"
 	"github.com/leotaku/kojirou/cmd/formats"
+	"github.com/leotaku/kojirou/codec"
 	md "github.com/leotaku/kojirou/mangadex"
 	"golang.org/x/sync/errgroup"
+	"golang.org/x/text/language"
 )
 
+// Options control how many requests are made at once.
+type Options struct {
+	// ChapterJobs is how many chapters are listed by MangaDex@Home at
+	// once.
+	ChapterJobs int
+	// ImageJobs is how many images are downloaded at once.
+	ImageJobs int
+}
+
 const (
-	maxJobsChapter = 8
-	maxJobsImage   = 16
+	DefaultChapterJobs = 8
+	DefaultImageJobs   = 16
 )
 
-var (
+func DefaultOptions() Options {
+	return Options{
+		ChapterJobs: DefaultChapterJobs,
+		ImageJobs:   DefaultImageJobs,
+	}
+}
+
+// Downloader fetches manga, covers and pages from MangaDex.  All
+// requests are made using the same HTTP client, so networking can be
+// fully controlled by the caller.
+type Downloader struct {
 	httpClient     *http.Client
 	mangadexClient *md.Client
-)
+	cache          cache.Cache
+	skeletons      map[string]md.MangaInfo
+	coverPaths     map[string]md.PathList
+	mutex          sync.Mutex
+	budget         *MemoryBudget
+	nodes          *nodeHealth
+	pageRetries    int
+	options        Options
+}
 
-func init() {
-	retry := retryablehttp.NewClient()
-	retry.Logger = nil
-	retry.RetryWaitMin = time.Second * 5
-	retry.Backoff = retryablehttp.LinearJitterBackoff
-	httpClient = retry.StandardClient()
-	mangadexClient = md.NewClient().WithHTTPClient(httpClient)
+// DefaultPageRetries is how often corrupted images are fetched again.
+const DefaultPageRetries = 3
+
+// NodeFailovers is how often a page is requested from another
+// MangaDex@Home node after a network failure.  This is independent of
+// the page retries, as node requests are not retried by the HTTP
+// client.
+const NodeFailovers = 4
+
+// nodeFailoverWait is the wait before the first failover, which doubles
+// for every further failover of the same page.
+const nodeFailoverWait = time.Second
+
+// NewDownloader wraps the given client, or the default client if nil,
+// to retry failed requests and wait for the limiter, if not nil,
+// before every attempt.  The given client is not modified.
+func NewDownloader(client *http.Client, options RetryOptions, limiter Limiter) *Downloader {
+	return NewDownloaderWith(client, DefaultMiddleware(options, limiter)...)
 }
 
-func MangadexSkeleton(mangaID string) (*md.Manga, error) {
-	return mangadexClient.FetchManga(context.TODO(), mangaID)
+// NewDownloaderWith wraps the transport of the given client, or the
+// default client if nil, in the given middleware.  Clients without a
+// transport use one with the default transport options.  The given
+// client is not modified.
+func NewDownloaderWith(client *http.Client, middleware ...Middleware) *Downloader {
+	base := *http.DefaultClient
+	if client != nil {
+		base = *client
+	}
+	if base.Transport == nil {
+		base.Transport = NewTransport(DefaultTransportOptions())
+	}
+	base.Transport = Chain(base.Transport, middleware...)
+
+	return &Downloader{
+		httpClient:     &base,
+		mangadexClient: md.NewClient().WithHTTPClient(&base),
+		skeletons:      make(map[string]md.MangaInfo),
+		coverPaths:     make(map[string]md.PathList),
+		nodes:          newNodeHealth(),
+		pageRetries:    DefaultPageRetries,
+		options:        DefaultOptions(),
+	}
 }
 
-func MangadexChapters(mangaID string) (md.ChapterList, error) {
-	return mangadexClient.FetchChapters(context.TODO(), mangaID)
+// HTTPClient returns the client used for all requests, so that other
+// API clients can share its middleware.
+func (d *Downloader) HTTPClient() *http.Client {
+	return d.httpClient
 }
 
-func MangadexCovers(manga *md.Manga, p formats.Progress) (md.ImageList, error) {
-	ctx, cancel := context.WithCancel(context.TODO())
-	defer cancel()
+// WithOptions sets how many requests are made at once.  Values that
+// are not positive keep their defaults.
+func (d *Downloader) WithOptions(options Options) *Downloader {
+	if options.ChapterJobs > 0 {
+		d.options.ChapterJobs = options.ChapterJobs
+	}
+	if options.ImageJobs > 0 {
+		d.options.ImageJobs = options.ImageJobs
+	}
+	return d
+}
+
+// Options returns how many requests are made at once, so that callers
+// that download files themselves can use the same limits.
+func (d *Downloader) Options() Options {
+	return d.options
+}
+
+// WithMemoryBudget limits concurrent image downloads so that the pages
+// being downloaded stay within the given budget.
+func (d *Downloader) WithMemoryBudget(b *MemoryBudget) *Downloader {
+	d.budget = b
+	return d
+}
+
+// WithPageRetries sets how often images with corrupted data are fetched
+// again before giving up.
+func (d *Downloader) WithPageRetries(retries int) *Downloader {
+	d.pageRetries = retries
+	return d
+}
+
+// WithDataSaver makes the downloader fetch the compressed pages of the
+// MangaDex data-saver mode instead of the original pages.
+func (d *Downloader) WithDataSaver(enabled bool) *Downloader {
+	d.mangadexClient.WithDataSaver(enabled)
+	return d
+}
+
+// WithSession logs in to MangaDex using the given token, which is
+// refreshed transparently, see md.Client.WithSession.
+func (d *Downloader) WithSession(clientID, clientSecret string, token *md.Token, save func(*md.Token) error) *Downloader {
+	d.mangadexClient.WithSession(clientID, clientSecret, token, save)
+	return d
+}
+
+// WithCache stores downloaded images in the given cache and reuses
+// them for later downloads of the same image.
+func (d *Downloader) WithCache(c cache.Cache) *Downloader {
+	d.cache = c
+	return d
+}
+
+// MangadexSkeleton returns the manga without chapters, which is only
+// requested if it has not been prefetched.
+func (d *Downloader) MangadexSkeleton(ctx context.Context, mangaID string) (*md.Manga, error) {
+	d.mutex.Lock()
+	info, ok := d.skeletons[mangaID]
+	d.mutex.Unlock()
+	if ok {
+		return &md.Manga{Info: info, Volumes: make(map[md.Identifier]md.Volume)}, nil
+	}
+
+	return d.mangadexClient.FetchManga(ctx, mangaID)
+}
+
+// PrefetchMangadex requests the skeletons and cover lists of many manga
+// using combined requests, which saves most requests when building many
+// manga.  Manga that are missing from the responses are requested again
+// when needed.
+func (d *Downloader) PrefetchMangadex(ctx context.Context, mangaIDs ...string) error {
+	mangas, err := d.mangadexClient.FetchMangas(ctx, mangaIDs...)
+	if err != nil {
+		return err
+	}
+	covers, err := d.mangadexClient.FetchCoversOf(ctx, mangaIDs...)
+	if err != nil {
+		return err
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	for id, manga := range mangas {
+		d.skeletons[id] = manga.Info
+		d.coverPaths[id] = covers[id]
+	}
+
+	return nil
+}
+
+// MangadexLogin requests a token for the account of a personal API
+// client.
+func (d *Downloader) MangadexLogin(ctx context.Context, creds md.Credentials) (*md.Token, error) {
+	return d.mangadexClient.Login(ctx, creds)
+}
+
+// MangadexFollows returns the IDs of all manga followed by the logged
+// in user.
+func (d *Downloader) MangadexFollows(ctx context.Context) ([]string, error) {
+	return d.mangadexClient.FetchFollows(ctx)
+}
+
+func (d *Downloader) MangadexLinked(ctx context.Context, site, siteID string, titles ...string) (string, error) {
+	return d.mangadexClient.FetchLinked(ctx, site, siteID, titles...)
+}
+
+func (d *Downloader) MangadexChapters(ctx context.Context, mangaID string) (md.ChapterList, error) {
+	return d.mangadexClient.FetchChapters(ctx, mangaID)
+}
 
-	covers, err := mangadexClient.FetchCovers(ctx, manga.Info.ID)
+// MangadexCovers downloads the covers of all volumes of the manga.
+// The list of covers is only requested once per manga, so covers can
+// be downloaded one volume at a time to save memory.  Covers are
+// downloaded concurrently and returned in the order of the list.
+func (d *Downloader) MangadexCovers(ctx context.Context, manga *md.Manga, p formats.Progress) (md.ImageList, error) {
+	allCovers, err := d.mangadexCoverList(ctx, manga.Info.ID)
 	if err != nil {
 		return nil, err
 	}
+	covers := allCovers.FilterBy(func(path md.Path) bool {
+		_, ok := manga.Volumes[path.VolumeIdentifier]
+		return ok
+	})
+	p.Increase(len(covers))
 
-	coverPaths := make(chan md.Path)
-	go func() {
-		for _, path := range covers {
-			coverPaths <- path
-			p.Increase(1)
+	images := make(md.ImageList, len(covers))
+	errs := make([]error, len(covers))
+	eg, groupCtx := errgroup.WithContext(ctx)
+	eg.SetLimit(d.options.ImageJobs)
+	var acquireErr error
+	for i, path := range covers {
+		i, path := i, path
+		if acquireErr = d.budget.acquire(groupCtx, pageEstimate); acquireErr != nil {
+			break
 		}
-		close(coverPaths)
-	}()
+		eg.Go(func() error {
+			held := int64(pageEstimate)
+			defer func() { d.budget.release(held) }()
+			image, err := d.getImage(groupCtx, path, p)
+			if err != nil {
+				errs[i] = fmt.Errorf("volume %v: %w", path.VolumeIdentifier, err)
+			} else {
+				held = d.budget.resize(held, imageSize(image))
+				images[i] = path.WithImage(image)
+			}
+			p.Add(1)
+			return nil
+		})
+	}
 
-	coverImages, eg := pathsToImages(coverPaths, ctx, cancel)
+	eg.Wait() //nolint:errcheck
+	if acquireErr != nil {
+		return nil, acquireErr
+	} else if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
-	results := make(md.ImageList, len(covers))
-	for coverImage := range coverImages {
-		p.Add(1)
-		results = append(results, coverImage)
+	results := make(md.ImageList, 0, len(covers))
+	for i, image := range images {
+		if errs[i] == nil {
+			results = append(results, image)
+		}
 	}
 
-	if err := eg.Wait(); err != nil {
+	return results, errors.Join(errs...)
+}
+
+func (d *Downloader) mangadexCoverList(ctx context.Context, mangaID string) (md.PathList, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if covers, ok := d.coverPaths[mangaID]; ok {
+		return covers, nil
+	}
+
+	covers, err := d.mangadexClient.FetchCovers(ctx, mangaID)
+	if err != nil {
 		return nil, err
-	} else {
-		return results, nil
 	}
+	d.coverPaths[mangaID] = covers
+
+	return covers, nil
+}
+
+func (d *Downloader) MangadexCoverPaths(ctx context.Context, mangaID string, locales ...language.Tag) (md.PathList, error) {
+	return d.mangadexClient.FetchCovers(ctx, mangaID, locales...)
+}
+
+// DownloadFile saves the unmodified contents at the given URL, which
+// avoids re-encoding images that should be kept at full quality.  The
+// file only appears once it has been downloaded completely.  As the
+// contents are streamed to the file, they do not count towards the
+// memory budget.
+func (d *Downloader) DownloadFile(ctx context.Context, url, pathname string, p formats.Progress) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return fmt.Errorf("prepare: %w", err)
+	}
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("do: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return &md.StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	f, err := formats.CreateAtomic(pathname)
+	if err != nil {
+		return fmt.Errorf("create: %w", err)
+	}
+	if _, err := io.Copy(f, p.NewProxyReader(resp.Body)); err != nil {
+		f.Abort()
+		return fmt.Errorf("write: %w", err)
+	} else if err := f.Commit(); err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+
+	return nil
 }
 
-func MangadexPages(chapterList md.ChapterList, p formats.Progress) (md.ImageList, error) {
-	ctx, cancel := context.WithCancel(context.TODO())
+// MangadexPages downloads the pages of all given chapters.  Chapters
+// that fail do not stop the download of other chapters, instead only
+// the pages of complete chapters are returned, together with an error
+// that joins a *md.ChapterUnavailableError for every failed chapter.
+func (d *Downloader) MangadexPages(ctx context.Context, chapterList md.ChapterList, p formats.Progress) (md.ImageList, error) {
+	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	eg, ctx := errgroup.WithContext(ctx)
+	eg, groupCtx := errgroup.WithContext(ctx)
+	failed := newFailures()
 
 	chapters := make(chan md.Chapter)
 	go func() {
@@ -91,34 +355,179 @@ func MangadexPages(chapterList md.ChapterList, p formats.Progress) (md.ImageList
 		close(chapters)
 	}()
 
-	paths, childEg := chaptersToPaths(chapters, ctx, cancel, p)
+	expected := newPageCounts()
+	paths, childEg := d.chaptersToPaths(chapters, groupCtx, p, expected.set, func(chapter md.Chapter, err error) {
+		failed.add(chapter.Info.Identifier, fmt.Errorf("paths: %w", err))
+	})
 	eg.Go(childEg.Wait)
 
-	images, childEg := pathsToImages(paths, ctx, cancel)
+	images, childEg := d.pathsToImages(paths, groupCtx, p, func(path md.Path, err error) {
+		failed.add(path.ChapterIdentifier, fmt.Errorf("image %v: %w", path.ImageIdentifier, err))
+	})
 	eg.Go(childEg.Wait)
 
 	results := make(md.ImageList, 0)
+	received := newPageCounts()
 	for image := range images {
 		p.Add(1)
+		events.Publish(events.PageFetched{
+			Volume:  image.VolumeIdentifier,
+			Chapter: image.ChapterIdentifier,
+			Page:    image.ImageIdentifier,
+		})
 		results = append(results, image)
+		received.add(image.ChapterIdentifier)
 	}
 
 	if err := eg.Wait(); err != nil {
 		return nil, err
-	} else {
-		return results, nil
+	} else if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	for chapter, count := range expected.counts {
+		if n := received.counts[chapter]; n < count && !failed.has(chapter) {
+			failed.add(chapter, fmt.Errorf("%w: received %v of %v pages", md.ErrPagesMissing, n, count))
+		}
+	}
+
+	complete := make(md.ImageList, 0)
+	for _, image := range results {
+		if !failed.has(image.ChapterIdentifier) {
+			complete = append(complete, image)
+		}
+	}
+
+	return complete, failed.err()
+}
+
+// StreamPages yields the pages of the given chapters in order, one page
+// at a time, like md.Client.StreamImages.  Unlike that method, pages
+// are fetched the same way as by MangadexPages, so that failed
+// MangaDex@Home nodes are failed over, corrupted pages are fetched
+// again and the cache is used.  The stream still stops after the first
+// chapter that fails, yielding a *md.ChapterUnavailableError.
+func (d *Downloader) StreamPages(ctx context.Context, chapters md.ChapterList, p formats.Progress) md.ImageSeq {
+	return func(yield func(md.Image, error) bool) {
+		for i := range chapters {
+			paths, err := d.fetchPaths(ctx, &chapters[i])
+			if err != nil {
+				err = fmt.Errorf("paths: %w", err)
+				yield(md.Image{}, &md.ChapterUnavailableError{Chapter: chapters[i].Info.Identifier, Err: err})
+				return
+			}
+			p.Increase(len(paths))
+			for _, path := range paths {
+				img, err := d.getImage(ctx, path, p)
+				if err != nil {
+					err = fmt.Errorf("image %v: %w", path.ImageIdentifier, err)
+					yield(md.Image{}, &md.ChapterUnavailableError{Chapter: path.ChapterIdentifier, Err: err})
+					return
+				}
+				p.Add(1)
+				if !yield(path.WithImage(img), nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// failures records the first error of every failed chapter.
+type failures struct {
+	mutex    sync.Mutex
+	chapters map[md.Identifier]error
+}
+
+func newFailures() *failures {
+	return &failures{chapters: make(map[md.Identifier]error)}
+}
+
+func (f *failures) add(chapter md.Identifier, err error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if _, ok := f.chapters[chapter]; !ok {
+		f.chapters[chapter] = err
+	}
+}
+
+func (f *failures) has(chapter md.Identifier) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	_, ok := f.chapters[chapter]
+	return ok
+}
+
+func (f *failures) err() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	identifiers := make([]md.Identifier, 0)
+	for identifier := range f.chapters {
+		identifiers = append(identifiers, identifier)
+	}
+	sort.Slice(identifiers, func(i, j int) bool {
+		return identifiers[i].Less(identifiers[j])
+	})
+
+	errs := make([]error, 0)
+	for _, identifier := range identifiers {
+		errs = append(errs, &md.ChapterUnavailableError{Chapter: identifier, Err: f.chapters[identifier]})
+	}
+
+	return errors.Join(errs...)
+}
+
+// pageCounts counts the pages of every chapter.
+type pageCounts struct {
+	mutex  sync.Mutex
+	counts map[md.Identifier]int
+}
+
+func newPageCounts() *pageCounts {
+	return &pageCounts{counts: make(map[md.Identifier]int)}
+}
+
+func (c *pageCounts) set(chapter md.Identifier, count int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.counts[chapter] = count
+}
+
+func (c *pageCounts) add(chapter md.Identifier) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.counts[chapter]++
+}
+
+// fetchPaths returns the pages of a chapter listed by MangaDex@Home.
+// Lists with fewer pages than the chapter should have are fetched once
+// more before the chapter is given up on.
+func (d *Downloader) fetchPaths(ctx context.Context, chapter *md.Chapter) (md.PathList, error) {
+	paths, err := d.mangadexClient.FetchPaths(ctx, chapter)
+	if err != nil || len(paths) >= chapter.Info.Pages {
+		return paths, err
+	}
+
+	formats.Debug("Refetching incomplete pages", "chapter", chapter.Info.Identifier, "pages", len(paths), "expected", chapter.Info.Pages)
+	if paths, err = d.mangadexClient.FetchPaths(ctx, chapter); err != nil {
+		return nil, err
+	} else if len(paths) < chapter.Info.Pages {
+		return nil, fmt.Errorf("%w: MangaDex@Home lists %v of %v pages", md.ErrPagesMissing, len(paths), chapter.Info.Pages)
 	}
+
+	return paths, nil
 }
 
-func chaptersToPaths(
+func (d *Downloader) chaptersToPaths(
 	chapters <-chan md.Chapter,
 	ctx context.Context,
-	cancel context.CancelFunc,
 	p formats.Progress,
+	found func(md.Identifier, int),
+	fail func(md.Chapter, error),
 ) (<-chan md.Path, *errgroup.Group) {
 	ch := make(chan md.Path)
 	eg, ctx := errgroup.WithContext(ctx)
-	eg.SetLimit(maxJobsChapter + 1)
+	eg.SetLimit(d.options.ChapterJobs + 1)
 
 	eg.Go(func() error {
 		for {
@@ -130,11 +539,15 @@ func chaptersToPaths(
 					return nil
 				}
 				eg.Go(func() error {
-					paths, err := mangadexClient.FetchPaths(ctx, &chapter)
+					events.Publish(events.ChapterStarted{Chapter: chapter.Info})
+					paths, err := d.fetchPaths(ctx, &chapter)
 					if err != nil {
-						defer cancel()
-						return fmt.Errorf("chapter %v: paths: %w", chapter.Info.Identifier, err)
+						fail(chapter, err)
+						p.Add(1)
+						return nil
 					} else {
+						formats.Debug("Found pages", "chapter", chapter.Info.Identifier, "pages", len(paths))
+						found(chapter.Info.Identifier, len(paths))
 						p.Add(1)
 						for _, path := range paths {
 							select {
@@ -159,14 +572,15 @@ func chaptersToPaths(
 	return ch, eg
 }
 
-func pathsToImages(
+func (d *Downloader) pathsToImages(
 	paths <-chan md.Path,
 	ctx context.Context,
-	cancel context.CancelFunc,
+	p formats.Progress,
+	fail func(md.Path, error),
 ) (<-chan md.Image, *errgroup.Group) {
 	ch := make(chan md.Image)
 	eg, ctx := errgroup.WithContext(ctx)
-	eg.SetLimit(maxJobsImage + 1)
+	eg.SetLimit(d.options.ImageJobs + 1)
 
 	eg.Go(func() error {
 		for {
@@ -177,12 +591,19 @@ func pathsToImages(
 				if !ok {
 					return nil
 				}
+				if err := d.budget.acquire(ctx, pageEstimate); err != nil {
+					return err
+				}
 				eg.Go(func() error {
-					image, err := getImage(httpClient, ctx, path.URL, 0)
+					held := int64(pageEstimate)
+					defer func() { d.budget.release(held) }()
+					image, err := d.getImage(ctx, path, p)
 					if err != nil {
-						defer cancel()
-						return fmt.Errorf("chapter %v: image %v: %w", path.ChapterIdentifier, path.ImageIdentifier, err)
+						fail(path, err)
+						p.Add(1)
+						return nil
 					} else {
+						held = d.budget.resize(held, imageSize(image))
 						select {
 						case <-ctx.Done():
 							return fmt.Errorf("canceled")
@@ -203,7 +624,174 @@ func pathsToImages(
 	return ch, eg
 }
 
-func getImage(client *http.Client, ctx context.Context, url string, try uint) (image.Image, error) {
+// getImage returns the image at the given path, using the cache if one
+// has been configured.  Cached images that fail validation are fetched
+// again.
+func (d *Downloader) getImage(ctx context.Context, path md.Path, p formats.Progress) (image.Image, error) {
+	if d.cache == nil {
+		return d.fetchImage(ctx, path, p)
+	}
+
+	key := cacheKey(path.URL)
+	if data, err := d.cache.Get(ctx, key); err == nil {
+		if img, err := validateImage(path.URL, data); err == nil {
+			p.AddBytes(int64(len(data)))
+			return img, nil
+		}
+	} else if !errors.Is(err, cache.ErrNotFound) {
+		formats.Debug("Reading cache failed", "key", key, "error", err)
+	}
+
+	img, err := d.fetchImage(ctx, path, p)
+	if err != nil {
+		return nil, err
+	}
+	if encoded, ok := codec.Original(img); ok {
+		err := d.cache.Put(ctx, key, encoded.Data)
+		runtime.KeepAlive(encoded)
+		if err != nil {
+			formats.Debug("Writing cache failed", "key", key, "error", err)
+		}
+	}
+
+	return img, nil
+}
+
+// fetchImage downloads an image, fetching it again if the received data
+// is corrupted.  Pages on MangaDex@Home nodes are fetched from another
+// node after any failure, up to NodeFailovers times with a growing wait
+// in between, as the HTTP client does not retry them on the same node.
+// Other errors are not retried here, as failed requests are
+// already retried by the HTTP client.
+func (d *Downloader) fetchImage(ctx context.Context, path md.Path, p formats.Progress) (image.Image, error) {
+	url, failover := path.URL, path.ChapterID != ""
+	if failover {
+		url = d.nodeURL(path.ChapterID, url)
+	}
+	img, err := d.fetchNode(ctx, url, failover, p)
+	budget := retryBudget{refetches: d.pageRetries, failovers: NodeFailovers}
+	attempt := 1
+	for ; budget.next(ctx, url, failover, err); attempt++ {
+		if _, node := atHomeNode(url); node && failover {
+			if next, ferr := d.failover(ctx, path.ChapterID, url, err); ferr != nil {
+				formats.Debug("Requesting MangaDex@Home node failed", "chapter", path.ChapterID, "error", ferr)
+			} else {
+				url = next
+			}
+		}
+		formats.Debug("Refetching image", "url", url, "attempt", attempt+1, "error", err)
+		img, err = d.fetchNode(ctx, url, failover, p)
+	}
+	if err != nil && attempt > 1 {
+		return nil, fmt.Errorf("%w after %v attempts", err, attempt)
+	}
+
+	return img, err
+}
+
+// retryBudget counts the remaining attempts for a page.  Corrupted data
+// and failed MangaDex@Home nodes have separate budgets, so that
+// disabling page retries does not disable failover.
+type retryBudget struct {
+	refetches int
+	failovers int
+	wait      time.Duration
+}
+
+// next reports whether fetching the image again may succeed and is
+// within budget, waiting before every failover so that a briefly
+// unreachable network can recover.  Images that are verified but cannot
+// be decoded are never retried.
+func (b *retryBudget) next(ctx context.Context, url string, failover bool, err error) bool {
+	var decodeErr *md.DecodeError
+	_, node := atHomeNode(url)
+	switch {
+	case err == nil || ctx.Err() != nil:
+		return false
+	case errors.Is(err, md.ErrCorrupted):
+		b.refetches--
+		return b.refetches >= 0
+	case !node || !failover || errors.As(err, &decodeErr) || b.failovers <= 0:
+		return false
+	}
+	b.failovers--
+	if b.wait == 0 {
+		b.wait = nodeFailoverWait
+	} else {
+		b.wait *= 2
+	}
+	timer := time.NewTimer(b.wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// fetchNode downloads an image and reports the outcome if it was served
+// by a MangaDex@Home node.  Requests for pages that can fail over to
+// another node are not retried by the HTTP client.
+func (d *Downloader) fetchNode(ctx context.Context, url string, failover bool, p formats.Progress) (image.Image, error) {
+	_, node := atHomeNode(url)
+	if node && failover {
+		ctx = withoutRetry(ctx)
+	}
+	report := nodeReport{URL: url}
+	start := time.Now()
+	img, err := getImage(d.httpClient, ctx, url, p, &report)
+	if node && ctx.Err() == nil {
+		report.Success = err == nil
+		report.Duration = time.Since(start).Milliseconds()
+		d.report(report)
+	}
+
+	return img, err
+}
+
+// pageFilename matches the names of MangaDex pages, which contain the
+// SHA-256 hash of their content.
+var pageFilename = regexp.MustCompile(`^[^-]*-([0-9a-f]{64})\.[a-z]+$`)
+
+// pageChecksum returns the SHA-256 checksum contained in the filename of
+// a MangaDex page.
+func pageChecksum(rawURL string) (string, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", false
+	}
+	m := pageFilename.FindStringSubmatch(u.Path[strings.LastIndex(u.Path, "/")+1:])
+	if m == nil {
+		return "", false
+	}
+
+	return m[1], true
+}
+
+// cacheKey identifies images independently of the MangaDex@Home server
+// they were downloaded from.  Pages are identified by their content, so
+// that identical pages are shared between chapters and series.
+func cacheKey(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	if sum, ok := pageChecksum(rawURL); ok {
+		return "pages/sha256/" + sum
+	} else if page, ok := pagePath(u); ok {
+		return "pages" + page
+	}
+
+	return u.Host + u.Path
+}
+
+// getImage downloads and decodes an image, which keeps the downloaded
+// data so that it can be written without encoding it again.  Data that
+// is incomplete or does not match the checksum in its filename returns
+// an error wrapping md.ErrCorrupted.  The report receives the size of
+// the data and whether it was served from the cache of the server.
+func getImage(client *http.Client, ctx context.Context, url string, p formats.Progress, report *nodeReport) (image.Image, error) {
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("prepare: %w", err)
@@ -215,16 +803,45 @@ func getImage(client *http.Client, ctx context.Context, url string, try uint) (i
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("status: %v", resp.Status)
+		return nil, &md.StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
 	}
 
-	img, _, err := image.Decode(resp.Body)
-	// Hack to fix broken images.
-	if img == nil && try <= 10 {
-		return getImage(client, ctx, url, try+1)
+	data, err := io.ReadAll(p.NewProxyReader(resp.Body))
+	report.Bytes = len(data)
+	report.Cached = strings.HasPrefix(resp.Header.Get("X-Cache"), "HIT")
+	switch {
+	case ctx.Err() != nil:
+		return nil, ctx.Err()
+	case err != nil:
+		return nil, fmt.Errorf("%w: read: %v", md.ErrCorrupted, err)
+	case resp.ContentLength >= 0 && int64(len(data)) != resp.ContentLength:
+		return nil, fmt.Errorf("%w: received %v of %v bytes", md.ErrCorrupted, len(data), resp.ContentLength)
 	}
-	if err != nil {
-		return nil, fmt.Errorf("decode: %w", err)
+
+	return validateImage(url, data)
+}
+
+// validateImage decodes the image data after comparing it against the
+// checksum contained in the names of MangaDex pages.  Images in formats
+// that cannot be decoded are only considered corrupted if there is no
+// checksum, as retrying cannot change the format of verified data.
+func validateImage(rawURL string, data []byte) (image.Image, error) {
+	sum, verified := pageChecksum(rawURL)
+	if verified {
+		if actual := sha256.Sum256(data); hex.EncodeToString(actual[:]) != sum {
+			return nil, fmt.Errorf("%w: checksum mismatch", md.ErrCorrupted)
+		}
 	}
+
+	img, err := codec.DecodeBytes(data)
+	switch {
+	case errors.Is(err, image.ErrFormat) && verified:
+		return nil, &md.DecodeError{Err: fmt.Errorf("%w: %v", err, codec.ContentType(data))}
+	case errors.Is(err, image.ErrFormat):
+		return nil, fmt.Errorf("%w: %w", md.ErrCorrupted, &md.DecodeError{Err: fmt.Errorf("%w: %v", err, codec.ContentType(data))})
+	case err != nil:
+		return nil, fmt.Errorf("%w: %w", md.ErrCorrupted, &md.DecodeError{Err: err})
+	}
+
 	return img, nil
 }
@@ -0,0 +1,47 @@
+package download
+
+import (
+	"crypto/tls"
+	"net/http"
+	"time"
+)
+
+// TransportOptions tune the connections to MangaDex and its image
+// servers.  The defaults of the http package only keep two idle
+// connections per server, so most connections for concurrent image
+// downloads would be closed after every page.
+type TransportOptions struct {
+	// MaxConnsPerHost limits the connections to each server, all of
+	// which are kept open for reuse.  Zero means no limit.
+	MaxConnsPerHost int
+	// IdleConnTimeout is how long unused connections are kept open.
+	IdleConnTimeout time.Duration
+	// DisableHTTP2 forces HTTP/1.1, for servers and proxies that do
+	// not handle HTTP/2 well.
+	DisableHTTP2 bool
+}
+
+func DefaultTransportOptions() TransportOptions {
+	return TransportOptions{
+		MaxConnsPerHost: DefaultImageJobs,
+		IdleConnTimeout: 90 * time.Second,
+	}
+}
+
+// NewTransport returns a transport based on the default transport of
+// the http package, with the given options applied.
+func NewTransport(options TransportOptions) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxConnsPerHost = options.MaxConnsPerHost
+	transport.MaxIdleConnsPerHost = options.MaxConnsPerHost
+	if options.MaxConnsPerHost <= 0 {
+		transport.MaxIdleConnsPerHost = DefaultImageJobs
+	}
+	transport.IdleConnTimeout = options.IdleConnTimeout
+	if options.DisableHTTP2 {
+		transport.ForceAttemptHTTP2 = false
+		transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	}
+
+	return transport
+}
@@ -0,0 +1,86 @@
+package epub
+
+import "text/template"
+
+const containerXML = `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`
+
+var packageTemplate = template.Must(template.New("package").Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="uid" prefix="rendition: http://www.idpf.org/vocab/rendition/#">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="uid">{{ html .Identifier }}</dc:identifier>
+    <dc:title>{{ html .Title }}</dc:title>
+    <dc:language>{{ html .Language }}</dc:language>
+{{- range .Authors }}
+    <dc:creator>{{ html . }}</dc:creator>
+{{- end }}
+{{- if .Publisher }}
+    <dc:publisher>{{ html .Publisher }}</dc:publisher>
+{{- end }}
+{{- if .Description }}
+    <dc:description>{{ html .Description }}</dc:description>
+{{- end }}
+    <meta property="dcterms:modified">{{ .Modified }}</meta>
+    <meta property="belongs-to-collection" id="series">{{ html .Series }}</meta>
+    <meta refines="#series" property="collection-type">series</meta>
+{{- if .Position }}
+    <meta refines="#series" property="group-position">{{ .Position }}</meta>
+{{- end }}
+    <meta property="rendition:layout">pre-paginated</meta>
+    <meta property="rendition:orientation">auto</meta>
+    <meta property="rendition:spread">landscape</meta>
+{{- if .Cover }}
+    <meta name="cover" content="{{ .Cover }}"/>
+{{- end }}
+  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+{{- range .Pages }}
+    <item id="{{ .ID }}" href="{{ .Href }}" media-type="application/xhtml+xml"/>
+    <item id="{{ .ImageID }}" href="{{ .ImageHref }}" media-type="{{ .MediaType }}"{{ if .Cover }} properties="cover-image"{{ end }}/>
+{{- end }}
+  </manifest>
+  <spine page-progression-direction="{{ .Direction }}">
+{{- range .Pages }}
+    <itemref idref="{{ .ID }}" properties="{{ .Spread }}"/>
+{{- end }}
+  </spine>
+</package>
+`))
+
+var navTemplate = template.Must(template.New("nav").Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="{{ html .Language }}">
+<head>
+  <title>{{ html .Title }}</title>
+</head>
+<body>
+  <nav epub:type="toc">
+    <ol>
+{{- range .Chapters }}
+      <li><a href="{{ .Href }}">{{ html .Title }}</a></li>
+{{- end }}
+    </ol>
+  </nav>
+</body>
+</html>
+`))
+
+var pageTemplate = template.Must(template.New("page").Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml">
+<head>
+  <title>{{ html .Title }}</title>
+  <meta name="viewport" content="width={{ .Width }}, height={{ .Height }}"/>
+  <style>html, body { margin: 0; padding: 0; } img { display: block; width: {{ .Width }}px; height: {{ .Height }}px; }</style>
+</head>
+<body>
+  <img src="{{ .Image }}" alt=""/>
+</body>
+</html>
+`))
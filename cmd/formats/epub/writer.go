@@ -0,0 +1,397 @@
+package epub
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"image"
+	"io"
+	"runtime"
+	"sort"
+	"text/template"
+	"time"
+
+	"github.com/leotaku/kojirou/cmd/formats"
+	"github.com/leotaku/kojirou/codec"
+	md "github.com/leotaku/kojirou/mangadex"
+	"golang.org/x/text/language"
+)
+
+// mediaTypes are the media types of formats whose data is copied into
+// books unmodified, all of which are core media types of EPUB.  Images
+// in other formats, and images that have been modified, are encoded
+// using the encoder of the options.
+var mediaTypes = map[string]string{
+	codec.JPEG.Name: "image/jpeg",
+	codec.PNG.Name:  "image/png",
+	codec.GIF.Name:  "image/gif",
+}
+
+// webpMediaType is the media type of images encoded as WebP, which is
+// only a core media type since EPUB 3.3.  Original WebP images are
+// encoded anyway, as many readers cannot show them.
+const webpMediaType = "image/webp"
+
+var extensions = map[string]string{
+	"image/jpeg":  ".jpg",
+	"image/png":   ".png",
+	"image/gif":   ".gif",
+	webpMediaType: ".webp",
+}
+
+// EPUBOptions customizes generated e-books.
+type EPUBOptions struct {
+	LeftToRight      bool
+	FillVolumeNumber int
+	// Language overrides the language of the chapters, unless it is
+	// undetermined.
+	Language language.Tag
+	// Encoder encodes images that are not copied, which defaults to
+	// codec.DefaultEncoder.  If Reencode is set, all images are
+	// encoded.
+	Encoder  codec.Encoder
+	Reencode bool
+}
+
+// EPUBWriter generates fixed-layout EPUB 3 e-books for readers other
+// than Kindle devices, such as Kobo devices and Apple Books.  Every
+// page is a separate document that displays a single image.  Images
+// are written to a spool as they are added, while the documents that
+// put them in reading order are written by Finish.
+type EPUBWriter struct {
+	options  EPUBOptions
+	skeleton md.Manga
+	volume   md.Identifier
+	chapters md.ChapterList
+	spool    *formats.Spool
+	zip      *zip.Writer
+	pages    []pageItem
+	modified time.Time
+}
+
+func NewEPUBWriter(options EPUBOptions) *EPUBWriter {
+	return &EPUBWriter{options: options}
+}
+
+func (w *EPUBWriter) Extension() string {
+	return ".epub"
+}
+
+// Begin starts the container with the mimetype file, which must be the
+// first entry and must not be compressed, followed by the cover.
+func (w *EPUBWriter) Begin(manga md.Manga, volume md.Identifier) error {
+	w.Abort()
+	spool, err := formats.CreateSpool()
+	if err != nil {
+		return err
+	}
+	w.skeleton = manga
+	w.volume = volume
+	w.chapters = make(md.ChapterList, 0)
+	w.spool = spool
+	w.zip = zip.NewWriter(spool)
+	w.pages = make([]pageItem, 0)
+	w.modified = formats.ZipTimestamp(manga.Volumes[volume].Sorted())
+
+	mimetype := &zip.FileHeader{Name: "mimetype", Method: zip.Store}
+	mimetype.ModifiedDate, mimetype.ModifiedTime = msdosTime(w.modified)
+	f, err := w.zip.CreateHeader(mimetype)
+	if err != nil {
+		return err
+	} else if _, err := io.WriteString(f, "application/epub+zip"); err != nil {
+		return err
+	}
+
+	if cover := manga.Volumes[volume].Cover; cover != nil {
+		return w.addImage(cover, pageItem{Cover: true})
+	}
+
+	return nil
+}
+
+func (w *EPUBWriter) AddChapter(chapter md.ChapterInfo) error {
+	w.chapters = append(w.chapters, md.Chapter{
+		Info:  chapter,
+		Pages: make(map[int]image.Image),
+	})
+
+	return nil
+}
+
+// AddPage writes the image of the page to the spool.  Pages of chapters
+// that are not part of the volume are ignored.
+func (w *EPUBWriter) AddPage(page md.Image) error {
+	if _, ok := w.skeleton.Volumes[w.volume].Chapters[page.ChapterIdentifier]; !ok {
+		return nil
+	}
+
+	return w.addImage(page.Image, pageItem{chapter: page.ChapterIdentifier, index: page.ImageIdentifier})
+}
+
+// addImage writes the image and remembers it as a page.  Pages are
+// named in the order they are added, which is their reading order
+// unless pages are added out of order.
+func (w *EPUBWriter) addImage(img image.Image, item pageItem) error {
+	data, mediaType, err := w.imageData(img)
+	if err != nil {
+		return fmt.Errorf("image %v: %w", len(w.pages)+1, err)
+	}
+	name := fmt.Sprintf("%04d", len(w.pages)+1)
+	bounds := img.Bounds()
+	item.ID = "page-" + name
+	item.Href = "pages/" + name + ".xhtml"
+	item.ImageID = "image-" + name
+	item.ImageHref = "images/" + name + extensions[mediaType]
+	item.MediaType = mediaType
+	item.Width, item.Height = bounds.Dx(), bounds.Dy()
+
+	f, err := w.zip.CreateHeader(&zip.FileHeader{Name: "OEBPS/" + item.ImageHref, Method: zip.Store, Modified: w.modified})
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	runtime.KeepAlive(img)
+	if err != nil {
+		return err
+	}
+	w.pages = append(w.pages, item)
+
+	return nil
+}
+
+type pageItem struct {
+	ID        string
+	Href      string
+	ImageID   string
+	ImageHref string
+	MediaType string
+	Spread    string
+	Cover     bool
+	Width     int
+	Height    int
+	chapter   md.Identifier
+	index     int
+}
+
+type chapterItem struct {
+	Title string
+	Href  string
+}
+
+// Finish sorts the written pages into reading order, adds the package,
+// navigation and page documents and copies the e-book to the output.
+func (w *EPUBWriter) Finish(out io.Writer) error {
+	defer w.Abort()
+	volume := w.skeleton.WithChapters(w.chapters).Volumes[w.volume]
+	order := formats.ChapterOrder(volume)
+	pages := make([]pageItem, 0, len(w.pages))
+	for _, page := range w.pages {
+		if _, ok := order[page.chapter]; ok || page.Cover {
+			pages = append(pages, page)
+		}
+	}
+	sort.SliceStable(pages, func(i, j int) bool {
+		a, b := pages[i], pages[j]
+		if a.Cover || b.Cover {
+			return a.Cover && !b.Cover
+		} else if order[a.chapter] != order[b.chapter] {
+			return order[a.chapter] < order[b.chapter]
+		}
+		return a.index < b.index
+	})
+	if len(pages) == 0 {
+		return fmt.Errorf("no pages")
+	}
+	w.assignSpreads(pages)
+
+	chapters := make([]chapterItem, 0)
+	for i, page := range pages {
+		if page.Cover || (i > 0 && pages[i-1].chapter == page.chapter && !pages[i-1].Cover) {
+			continue
+		}
+		info := volume.Chapters[page.chapter].Info
+		title := info.Identifier.String()
+		if info.Title != "" {
+			title = fmt.Sprintf("%v: %v", info.Identifier, info.Title)
+		}
+		chapters = append(chapters, chapterItem{Title: title, Href: page.Href})
+	}
+
+	data := w.packageData(pages, w.modified)
+	files := []struct {
+		name     string
+		template *template.Template
+		data     interface{}
+	}{
+		{"OEBPS/content.opf", packageTemplate, data},
+		{"OEBPS/nav.xhtml", navTemplate, map[string]interface{}{
+			"Title": data["Title"], "Language": data["Language"], "Chapters": chapters,
+		}},
+	}
+	if err := writeFile(w.zip, "META-INF/container.xml", []byte(containerXML), w.modified); err != nil {
+		return err
+	}
+	for _, file := range files {
+		buf := new(bytes.Buffer)
+		if err := file.template.Execute(buf, file.data); err != nil {
+			return fmt.Errorf("%v: %w", file.name, err)
+		} else if err := writeFile(w.zip, file.name, buf.Bytes(), w.modified); err != nil {
+			return err
+		}
+	}
+
+	for i, page := range pages {
+		buf := new(bytes.Buffer)
+		err := pageTemplate.Execute(buf, map[string]interface{}{
+			"Title":  fmt.Sprintf("Page %v", i+1),
+			"Width":  page.Width,
+			"Height": page.Height,
+			"Image":  "../" + page.ImageHref,
+		})
+		if err != nil {
+			return fmt.Errorf("page %v: %w", i+1, err)
+		} else if err := writeFile(w.zip, "OEBPS/"+page.Href, buf.Bytes(), w.modified); err != nil {
+			return err
+		}
+	}
+	if err := w.zip.Close(); err != nil {
+		return err
+	}
+
+	return w.spool.CopyTo(out)
+}
+
+// Abort removes the spool of an unfinished e-book.
+func (w *EPUBWriter) Abort() {
+	w.spool.Remove()
+}
+
+// assignSpreads places pages on alternating sides of spreads, starting
+// on the side where spreads begin in the reading direction.  The cover
+// is shown on its own.
+func (w *EPUBWriter) assignSpreads(pages []pageItem) {
+	first, second := "page-spread-right", "page-spread-left"
+	if w.options.LeftToRight {
+		first, second = second, first
+	}
+
+	index := 0
+	for i := range pages {
+		switch {
+		case pages[i].Cover:
+			pages[i].Spread = "rendition:page-spread-center"
+		case index%2 == 0:
+			pages[i].Spread = first
+			index++
+		default:
+			pages[i].Spread = second
+			index++
+		}
+	}
+}
+
+func (w *EPUBWriter) packageData(pages []pageItem, modified time.Time) map[string]interface{} {
+	info := w.skeleton.Info
+	cover := ""
+	for _, page := range pages {
+		if page.Cover {
+			cover = page.ImageID
+		}
+	}
+	direction := "rtl"
+	if w.options.LeftToRight {
+		direction = "ltr"
+	}
+	position := ""
+	if !w.volume.IsSpecial() {
+		position = w.volume.String()
+	}
+
+	return map[string]interface{}{
+		"Identifier":  w.identifier(),
+		"Title":       fmt.Sprintf("%v: %v", info.Title, w.volume.StringFilled(w.options.FillVolumeNumber, 0, false)),
+		"Language":    w.language().String(),
+		"Authors":     []string(info.Authors),
+		"Publisher":   info.Publisher,
+		"Description": info.Description,
+		"Series":      info.SeriesName(),
+		"Position":    position,
+		"Modified":    modified.UTC().Format(time.RFC3339),
+		"Cover":       cover,
+		"Direction":   direction,
+		"Pages":       pages,
+	}
+}
+
+// identifier returns a name-based UUID for the volume, which stays the
+// same when the volume is generated again, so that readers recognize
+// updated books.
+func (w *EPUBWriter) identifier() string {
+	sum := sha1.Sum([]byte(w.skeleton.Info.ID + "/" + w.skeleton.Info.Title + "/" + w.volume.String()))
+	sum[6] = sum[6]&0x0f | 0x50
+	sum[8] = sum[8]&0x3f | 0x80
+
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+// Timestamp returns the stable modification date of the e-book, see
+// formats.Timestamp.
+func (w *EPUBWriter) Timestamp() time.Time {
+	return formats.ZipTimestamp(w.chapters)
+}
+
+func (w *EPUBWriter) language() language.Tag {
+	if w.options.Language != language.Und {
+		return w.options.Language
+	}
+	for _, chapter := range w.chapters {
+		if chapter.Info.Language != language.Und {
+			return chapter.Info.Language
+		}
+	}
+
+	return language.Und
+}
+
+// imageData returns the data of the image and its media type, copying
+// the original data if the image is unmodified.  The image must be kept
+// alive while the data is used.
+func (w *EPUBWriter) imageData(img image.Image) ([]byte, string, error) {
+	if encoded, ok := codec.Original(img); ok && mediaTypes[encoded.Format] != "" && !w.options.Reencode {
+		return encoded.Data, mediaTypes[encoded.Format], nil
+	}
+
+	data, format, err := codec.Encode(w.options.Encoder, img)
+	if err != nil {
+		return nil, "", err
+	} else if format == codec.WebP.Name {
+		return data, webpMediaType, nil
+	}
+
+	return data, mediaTypes[format], nil
+}
+
+func writeFile(zw *zip.Writer, name string, data []byte, modified time.Time) error {
+	f, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+
+	return err
+}
+
+// msdosTime returns the date and time of the legacy MS-DOS fields of zip
+// headers, which cannot represent dates before 1980.  Setting these
+// fields instead of Modified avoids the extra field that zip.Writer adds
+// for Modified, which is not allowed for the mimetype entry.
+func msdosTime(t time.Time) (uint16, uint16) {
+	if t.Year() < 1980 {
+		t = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	date := uint16(t.Day() + int(t.Month())<<5 + (t.Year()-1980)<<9)
+	clock := uint16(t.Second()/2 + t.Minute()<<5 + t.Hour()<<11)
+
+	return date, clock
+}
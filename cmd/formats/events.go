@@ -0,0 +1,118 @@
+package formats
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const eventInterval = 250 * time.Millisecond
+
+var (
+	eventsEnabled bool
+	eventsMutex   sync.Mutex
+	eventsEncoder = json.NewEncoder(os.Stderr)
+)
+
+// Event describes a change in the state of a single progress bar and
+// is emitted as one line of JSON when machine-readable progress output
+// has been requested.
+type Event struct {
+	Phase    string
+	Stage    string `json:",omitempty"`
+	Event    string
+	Current  int64
+	Total    int64
+	Bytes    int64
+	Failed   int64   `json:",omitempty"`
+	Duration float64 `json:",omitempty"`
+	Message  string  `json:",omitempty"`
+	Manga    string  `json:",omitempty"`
+	Volume   string  `json:",omitempty"`
+	Chapter  string  `json:",omitempty"`
+	Page     int
+}
+
+func SetProgressMode(mode string) error {
+	switch mode {
+	case "bar":
+		eventsEnabled = false
+	case "json":
+		eventsEnabled = true
+	default:
+		return fmt.Errorf(`not a valid progress mode: "%v"`, mode)
+	}
+
+	return nil
+}
+
+// EventsEnabled reports whether progress is reported as JSON events
+// instead of progress bars.
+func EventsEnabled() bool {
+	return eventsEnabled
+}
+
+// PrintErrorEvent reports an error that caused the program to exit.
+func PrintErrorEvent(err error) {
+	if eventsEnabled {
+		emit(Event{Event: "error", Message: err.Error()})
+	}
+}
+
+// PrintEvent reports an event that does not belong to a progress bar,
+// such as a chapter being started or a volume being written.
+func PrintEvent(e Event) {
+	if eventsEnabled {
+		emit(e)
+	}
+}
+
+type eventStream struct {
+	mutex sync.Mutex
+	phase string
+	stage string
+	last  time.Time
+}
+
+func (s *eventStream) update(p CliProgress, event, message string) {
+	if s == nil {
+		return
+	}
+
+	s.mutex.Lock()
+	now := time.Now()
+	if event == "update" && now.Sub(s.last) < eventInterval {
+		s.mutex.Unlock()
+		return
+	}
+	s.last = now
+	if event == "stage" {
+		s.stage = message
+	}
+	stage := s.stage
+	s.mutex.Unlock()
+
+	e := Event{
+		Phase:   s.phase,
+		Stage:   stage,
+		Event:   event,
+		Current: p.bar.Current(),
+		Total:   p.bar.Total(),
+		Bytes:   atomic.LoadInt64(p.bytes),
+		Failed:  atomic.LoadInt64(p.failed),
+		Message: message,
+	}
+	if event == "done" || event == "cancel" {
+		e.Duration = p.Elapsed().Seconds()
+	}
+	emit(e)
+}
+
+func emit(e Event) {
+	eventsMutex.Lock()
+	defer eventsMutex.Unlock()
+	eventsEncoder.Encode(e) //nolint:errcheck
+}
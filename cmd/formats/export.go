@@ -0,0 +1,69 @@
+package formats
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExportChapters writes the chapters to a CSV or JSON file, depending on
+// the extension of the filename.
+func ExportChapters(filename string, chapters []ChapterSummary) error {
+	var write func(io.Writer, []ChapterSummary) error
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		write = writeChaptersCSV
+	case ".json":
+		write = writeChaptersJSON
+	default:
+		return fmt.Errorf("unsupported format: '%v', use .csv or .json", filepath.Ext(filename))
+	}
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := write(f, chapters); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+func writeChaptersCSV(w io.Writer, chapters []ChapterSummary) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"ID", "Volume", "Chapter", "Title", "Language", "Groups", "Pages", "Published"}) //nolint:errcheck
+	for _, chapter := range chapters {
+		published := ""
+		if !chapter.Published.IsZero() {
+			published = chapter.Published.Format(time.RFC3339)
+		}
+		cw.Write([]string{ //nolint:errcheck
+			chapter.ID,
+			chapter.Volume,
+			chapter.Chapter,
+			chapter.Title,
+			chapter.Language,
+			strings.Join(chapter.Groups, "; "),
+			strconv.Itoa(chapter.Pages),
+			published,
+		})
+	}
+	cw.Flush()
+
+	return cw.Error()
+}
+
+func writeChaptersJSON(w io.Writer, chapters []ChapterSummary) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(chapters)
+}
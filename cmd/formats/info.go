@@ -0,0 +1,99 @@
+package formats
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	md "github.com/leotaku/kojirou/mangadex"
+)
+
+type Info struct {
+	Title       string
+	AltTitles   []string
+	Authors     []string
+	Artists     []string
+	Description string
+	Tags        []string
+	Status      string
+	Year        int `json:",omitempty"`
+	Languages   []LanguageInfo
+}
+
+type LanguageInfo struct {
+	Language string
+	Volumes  int
+	Chapters int
+}
+
+// Describe collects metadata about the manga together with the number
+// of volumes and chapters available in every translated language.
+func Describe(manga *md.Manga, chapters md.ChapterList) Info {
+	volumeSets := make(map[string]map[md.Identifier]bool)
+	chapterSets := make(map[string]map[md.Identifier]bool)
+	for _, lang := range manga.Info.Languages {
+		volumeSets[lang] = make(map[md.Identifier]bool)
+		chapterSets[lang] = make(map[md.Identifier]bool)
+	}
+	for _, chapter := range chapters {
+		lang := chapter.Info.Language.String()
+		if _, ok := volumeSets[lang]; !ok {
+			volumeSets[lang] = make(map[md.Identifier]bool)
+			chapterSets[lang] = make(map[md.Identifier]bool)
+		}
+		volumeSets[lang][chapter.Info.VolumeIdentifier] = true
+		chapterSets[lang][chapter.Info.Identifier] = true
+	}
+
+	languages := make([]LanguageInfo, 0)
+	for lang := range volumeSets {
+		languages = append(languages, LanguageInfo{
+			Language: lang,
+			Volumes:  len(volumeSets[lang]),
+			Chapters: len(chapterSets[lang]),
+		})
+	}
+	sort.Slice(languages, func(i, j int) bool {
+		if languages[i].Chapters == languages[j].Chapters {
+			return languages[i].Language < languages[j].Language
+		}
+		return languages[i].Chapters > languages[j].Chapters
+	})
+
+	return Info{
+		Title:       manga.Info.Title,
+		AltTitles:   manga.Info.AltTitles,
+		Authors:     manga.Info.Authors,
+		Artists:     manga.Info.Artists,
+		Description: manga.Info.Description,
+		Tags:        manga.Info.Tags,
+		Status:      manga.Info.Status,
+		Year:        manga.Info.Year,
+		Languages:   languages,
+	}
+}
+
+func PrintInfo(info Info) {
+	PrintValue("Title", info.Title)
+	if len(info.AltTitles) > 0 {
+		PrintValue("Alternative titles", strings.Join(info.AltTitles, ", "))
+	}
+	PrintValue("Author", strings.Join(info.Authors, ", "))
+	PrintValue("Artist", strings.Join(info.Artists, ", "))
+	PrintValue("Status", info.Status)
+	if info.Year > 0 {
+		PrintValue("Year", info.Year)
+	}
+	if len(info.Tags) > 0 {
+		PrintValue("Tags", strings.Join(info.Tags, ", "))
+	}
+	if info.Description != "" {
+		PrintValue("Description", info.Description)
+	}
+
+	languages := make([]string, 0)
+	for _, lang := range info.Languages {
+		languages = append(languages, fmt.Sprintf("%v (%v volumes, %v chapters)", lang.Language, lang.Volumes, lang.Chapters))
+	}
+	PrintValue("Languages", strings.Join(languages, ", "))
+}
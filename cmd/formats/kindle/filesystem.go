@@ -1,40 +1,60 @@
 package kindle
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
-	"image/jpeg"
+	"image"
+	"io"
 	"io/fs"
 	"os"
-	"path"
-	"runtime"
+	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/leotaku/kojirou/cmd/formats"
 	md "github.com/leotaku/kojirou/mangadex"
-	"github.com/leotaku/mobi"
+	"golang.org/x/text/unicode/norm"
 )
 
+const ChecksumFilename = "sha256sums.txt"
+
 type NormalizedDirectory struct {
 	bookDirectory      string
 	thumbnailDirectory string
 }
 
-func NewNormalizedDirectory(target, title string, kindleFolder bool) NormalizedDirectory {
+// NewNormalizedDirectory returns the output directory for the title.
+func NewNormalizedDirectory(target, title string, kindleFolder bool, s Sanitizer) NormalizedDirectory {
+	n := newNormalizedDirectory(target, title, kindleFolder, s)
+	if n.bookDirectory != "" {
+		n.bookDirectory = formats.LongPath(n.bookDirectory)
+	}
+	if n.thumbnailDirectory != "" {
+		n.thumbnailDirectory = formats.LongPath(n.thumbnailDirectory)
+	}
+
+	return n
+}
+
+func newNormalizedDirectory(target, title string, kindleFolder bool, s Sanitizer) NormalizedDirectory {
 	switch {
 	case kindleFolder && target == "":
 		return NormalizedDirectory{
-			bookDirectory:      path.Join("kindle", "documents", pathnameFromTitle(title)),
-			thumbnailDirectory: path.Join("kindle", "system", "thumbnails"),
+			bookDirectory:      filepath.Join("kindle", "documents", s.Pathname(title)),
+			thumbnailDirectory: filepath.Join("kindle", "system", "thumbnails"),
 		}
 	case kindleFolder:
 		return NormalizedDirectory{
-			bookDirectory:      path.Join(target, "documents", pathnameFromTitle(title)),
-			thumbnailDirectory: path.Join(target, "system", "thumbnails"),
+			bookDirectory:      filepath.Join(target, "documents", s.Pathname(title)),
+			thumbnailDirectory: filepath.Join(target, "system", "thumbnails"),
 		}
 	case target == "":
 		return NormalizedDirectory{
-			bookDirectory: pathnameFromTitle(title),
+			bookDirectory: s.Pathname(title),
 		}
 	default:
 		return NormalizedDirectory{
@@ -43,59 +63,210 @@ func NewNormalizedDirectory(target, title string, kindleFolder bool) NormalizedD
 	}
 }
 
-func (n *NormalizedDirectory) Has(identifier md.Identifier) bool {
-	filename := identifier.StringFilled(4, 2, false) + ".azw3"
-	return exists(path.Join(n.bookDirectory, filename))
+// ExistingDirectory returns the output directory for a series that was
+// written to the given directory before.  Series in the Kindle folder
+// structure keep writing thumbnails to the structure they are part of.
+func ExistingDirectory(directory string, kindleFolder bool) NormalizedDirectory {
+	n := NormalizedDirectory{bookDirectory: formats.LongPath(directory)}
+	if kindleFolder {
+		n.thumbnailDirectory = formats.LongPath(thumbnailDirectory(directory))
+	}
+
+	return n
+}
+
+// InFolderStructure reports whether the directory is the directory of a
+// series in an existing Kindle folder structure.
+func InFolderStructure(directory string) bool {
+	documents := filepath.Dir(filepath.Clean(directory))
+	return filepath.Base(documents) == "documents" && exists(thumbnailDirectory(directory))
+}
+
+// thumbnailDirectory returns the thumbnail directory of the Kindle
+// folder structure that contains the series directory.
+func thumbnailDirectory(directory string) string {
+	root := filepath.Dir(filepath.Dir(filepath.Clean(directory)))
+	return filepath.Join(root, "system", "thumbnails")
+}
+
+func (n *NormalizedDirectory) Directory() string {
+	return n.bookDirectory
+}
+
+// AvoidCollisions renames the book directory if an existing directory
+// has the same name except for case or Unicode normalization, as both
+// would be the same directory on case-insensitive filesystems.  The
+// existing directory is used instead if same returns true for it, and a
+// number is appended to the name otherwise.
+func (n *NormalizedDirectory) AvoidCollisions(same func(directory string) bool) {
+	for i := 1; ; i++ {
+		candidate := n.bookDirectory
+		if i > 1 {
+			candidate = fmt.Sprintf("%v (%v)", n.bookDirectory, i)
+		}
+		existing, ok := foldedEntry(candidate)
+		if !ok {
+			n.bookDirectory = candidate
+			return
+		} else if same(existing) {
+			n.bookDirectory = existing
+			return
+		}
+	}
+}
+
+// foldedEntry returns an existing entry whose name only differs from
+// the given pathname in case or Unicode normalization.  Exact matches
+// are not reported.
+func foldedEntry(pathname string) (string, bool) {
+	parent, name := filepath.Split(pathname)
+	directory := parent
+	if directory == "" {
+		directory = "."
+	}
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		return "", false
+	}
+
+	folded := ""
+	for _, entry := range entries {
+		switch {
+		case entry.Name() == name:
+			return "", false
+		case folded == "" && strings.EqualFold(norm.NFC.String(entry.Name()), norm.NFC.String(name)):
+			folded = filepath.Join(parent, entry.Name())
+		}
+	}
+
+	return folded, folded != ""
+}
+
+func (n *NormalizedDirectory) Filename(identifier md.Identifier, extension string) string {
+	return identifier.StringFilled(4, 2, false) + extension
+}
+
+func (n *NormalizedDirectory) Has(identifier md.Identifier, extension string) bool {
+	return exists(filepath.Join(n.bookDirectory, n.Filename(identifier, extension)))
+}
+
+// timestamper is implemented by writers whose output has a stable
+// timestamp, which is used as the modification time of written files,
+// so that tools like rsync do not copy unchanged files again.
+type timestamper interface {
+	Timestamp() time.Time
 }
 
-func (n *NormalizedDirectory) Write(identifier md.Identifier, mobi mobi.Book, p formats.Progress) error {
+// thumbnailer is implemented by writers that provide a cover thumbnail
+// for the Kindle folder structure.
+type thumbnailer interface {
+	Thumbnail() (string, image.Image)
+}
+
+func (n *NormalizedDirectory) Write(identifier md.Identifier, w formats.FormatWriter, p formats.Progress) error {
 	if n.bookDirectory == "" {
 		return fmt.Errorf("unsupported configuration: no book output")
 	}
-	filename := identifier.StringFilled(4, 2, false) + ".azw3"
+	filename := n.Filename(identifier, w.Extension())
 
-	f, err := create(path.Join(n.bookDirectory, filename))
+	f, err := formats.CreateAtomic(filepath.Join(n.bookDirectory, filename))
 	if err != nil {
 		return fmt.Errorf("create: %w", err)
 	}
-	if err := mobi.Realize().Write(p.NewProxyWriter(f)); err != nil {
-		f.Close()
+	hash := sha256.New()
+	if err := w.Finish(p.NewProxyWriter(io.MultiWriter(f, hash))); err != nil {
+		f.Abort()
+		return fmt.Errorf("write: %w", err)
+	} else if err := f.Commit(); err != nil {
 		return fmt.Errorf("write: %w", err)
+	} else if err := setTimestamp(filepath.Join(n.bookDirectory, filename), w); err != nil {
+		return fmt.Errorf("timestamp: %w", err)
+	}
+
+	if err := updateChecksum(n.bookDirectory, filename, hash.Sum(nil)); err != nil {
+		return fmt.Errorf("checksum: %w", err)
 	}
-	f.Close()
 
-	if n.thumbnailDirectory != "" && mobi.CoverImage != nil {
-		f, err := create(path.Join(n.thumbnailDirectory, mobi.GetThumbFilename()))
+	t, ok := w.(thumbnailer)
+	if !ok || n.thumbnailDirectory == "" {
+		return nil
+	}
+	if thumbFilename, cover := t.Thumbnail(); cover != nil {
+		f, err := formats.CreateAtomic(filepath.Join(n.thumbnailDirectory, thumbFilename))
 		if err != nil {
 			return fmt.Errorf("create: %w", err)
 		}
-		if err := jpeg.Encode(p.NewProxyWriter(f), mobi.CoverImage, nil); err != nil {
-			f.Close()
+		if err := writeJPEG(p.NewProxyWriter(f), cover); err != nil {
+			f.Abort()
+			return fmt.Errorf("write: %w", err)
+		} else if err := f.Commit(); err != nil {
 			return fmt.Errorf("write: %w", err)
+		} else if err := setTimestamp(filepath.Join(n.thumbnailDirectory, thumbFilename), w); err != nil {
+			return fmt.Errorf("timestamp: %w", err)
 		}
-		f.Close()
 	}
 
 	return nil
 }
 
-func pathnameFromTitle(filename string) string {
-	switch runtime.GOOS {
-	case "windows":
-		filename = strings.ReplaceAll(filename, "\"", "＂")
-		filename = strings.ReplaceAll(filename, "\\", "＼")
-		filename = strings.ReplaceAll(filename, "<", "＜")
-		filename = strings.ReplaceAll(filename, ">", "＞")
-		filename = strings.ReplaceAll(filename, ":", "：")
-		filename = strings.ReplaceAll(filename, "|", "｜")
-		filename = strings.ReplaceAll(filename, "?", "？")
-		filename = strings.ReplaceAll(filename, "*", "＊")
-		filename = strings.TrimRight(filename, ". ")
-	case "darwin":
-		filename = strings.ReplaceAll(filename, ":", "：")
+func setTimestamp(pathname string, w formats.FormatWriter) error {
+	if t, ok := w.(timestamper); ok {
+		return os.Chtimes(pathname, t.Timestamp(), t.Timestamp())
+	}
+
+	return nil
+}
+
+// ReadChecksums parses the checksum file in the given directory, which
+// uses the same format as the sha256sum utility.
+func ReadChecksums(directory string) (map[string]string, error) {
+	result := make(map[string]string)
+	data, err := os.ReadFile(filepath.Join(directory, ChecksumFilename))
+	if errors.Is(err, fs.ErrNotExist) {
+		return result, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if split := strings.SplitN(line, "  ", 2); len(split) == 2 {
+			result[split[1]] = split[0]
+		}
+	}
+
+	return result, nil
+}
+
+// checksumMutex serializes updates, since volumes may be written
+// concurrently.
+var checksumMutex sync.Mutex
+
+func updateChecksum(directory, filename string, sum []byte) error {
+	checksumMutex.Lock()
+	defer checksumMutex.Unlock()
+	sums, err := ReadChecksums(directory)
+	if err != nil {
+		return err
 	}
+	sums[filename] = hex.EncodeToString(sum)
 
-	return strings.ReplaceAll(filename, "/", "／")
+	return WriteChecksums(directory, sums)
+}
+
+// WriteChecksums replaces the checksum file in the given directory.
+func WriteChecksums(directory string, sums map[string]string) error {
+	filenames := make([]string, 0)
+	for filename := range sums {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+
+	buf := new(strings.Builder)
+	for _, filename := range filenames {
+		fmt.Fprintf(buf, "%v  %v\n", sums[filename], filename)
+	}
+
+	return formats.WriteFileAtomic(filepath.Join(directory, ChecksumFilename), []byte(buf.String()))
 }
 
 func exists(pathname string) bool {
@@ -110,14 +281,3 @@ func exists(pathname string) bool {
 		return true
 	}
 }
-
-func create(pathname string) (*os.File, error) {
-	if err := os.MkdirAll(path.Dir(pathname), os.ModePerm); err != nil {
-		return nil, fmt.Errorf("directory: %w", err)
-	}
-	if f, err := os.Create(pathname); err != nil {
-		return nil, fmt.Errorf("file: %w", err)
-	} else {
-		return f, nil
-	}
-}
@@ -0,0 +1,143 @@
+package kindle
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"runtime"
+
+	"github.com/leotaku/kojirou/codec"
+	"github.com/leotaku/mobi"
+	"github.com/leotaku/mobi/pdb"
+	"github.com/leotaku/mobi/records"
+)
+
+// jfifHeader replaces the start of copied JPEG images, as the MOBI
+// library does for the images it encodes.
+var jfifHeader = []byte{
+	0xFF, 0xD8, // SOI
+	0xFF, 0xE0, 0x00, 0x10, // APP0
+	'J', 'F', 'I', 'F', 0x00,
+	0x01, 0x02, // version
+	0x00, 0x00, 0x01, 0x00, 0x01, // density
+	0x00, 0x00, // thumbnail
+}
+
+// realize generates the e-book, copying unmodified JPEG images instead
+// of encoding them again.  Such images are never decoded.  All other
+// images are encoded using the encoder of the options, instead of by
+// the MOBI library, so that the records of all images are replaced.
+func realize(book mobi.Book, options MOBIOptions) (pdb.Database, error) {
+	sources := append([]image.Image{}, book.Images...)
+	for _, img := range []image.Image{book.CoverImage, book.ThumbImage} {
+		if img != nil {
+			sources = append(sources, img)
+		}
+	}
+
+	// Image records are written in the same order as above.
+	db := book.Realize()
+	index := 0
+	for i, record := range db.Records {
+		if _, ok := record.(records.ImageRecord); !ok {
+			continue
+		} else if index >= len(sources) {
+			break
+		}
+		data, err := imageRecord(sources[index], options)
+		if err != nil && index < len(book.Images) {
+			return pdb.Database{}, fmt.Errorf("image %v: %w", index+1, err)
+		} else if err != nil {
+			return pdb.Database{}, fmt.Errorf("cover: %w", err)
+		}
+		db.ReplaceRecord(i, pdb.RawRecord(data))
+		index++
+	}
+	runtime.KeepAlive(sources)
+
+	return db, nil
+}
+
+// imageRecord returns the data of the record for the image.  JPEG
+// images use the same header as copied images.
+func imageRecord(img image.Image, options MOBIOptions) ([]byte, error) {
+	if data, ok := originalJPEG(img); ok && !options.Reencode {
+		data = withJFIFHeader(data)
+		runtime.KeepAlive(img)
+		return data, nil
+	}
+
+	data, format, err := codec.Encode(options.Encoder, img)
+	if err != nil {
+		return nil, err
+	} else if format == codec.JPEG.Name {
+		return withJFIFHeader(data), nil
+	}
+
+	return data, nil
+}
+
+// writeJPEG writes the image as JPEG, copying the original data if the
+// image is unmodified.
+func writeJPEG(w io.Writer, img image.Image) error {
+	if data, ok := originalJPEG(img); ok {
+		_, err := w.Write(data)
+		runtime.KeepAlive(img)
+		return err
+	}
+
+	decoded, err := codec.Decoded(img)
+	if err != nil {
+		return err
+	}
+
+	return jpeg.Encode(w, decoded, nil)
+}
+
+// originalJPEG returns the data of unmodified JPEG images that Kindle
+// devices can display, which excludes progressive and CMYK images.  The
+// data belongs to the image, which must be kept alive while the data is
+// used.
+func originalJPEG(img image.Image) ([]byte, bool) {
+	encoded, ok := codec.Original(img)
+	if !ok || encoded.Format != codec.JPEG.Name {
+		return nil, false
+	}
+
+	data := encoded.Data
+	for i := 2; i+4 <= len(data) && data[i] == 0xFF; {
+		marker := data[i+1]
+		switch {
+		case marker == 0xFF:
+			i++
+			continue
+		case marker == 0x01 || marker >= 0xD0 && marker <= 0xD7:
+			i += 2
+			continue
+		case marker == 0xC0 || marker == 0xC1:
+			return data, i+9 < len(data) && (data[i+9] == 1 || data[i+9] == 3)
+		case marker >= 0xC2 && marker <= 0xCF && marker != 0xC4 && marker != 0xC8 && marker != 0xCC:
+			return nil, false
+		case marker == 0xDA:
+			return nil, false
+		}
+		i += 2 + int(binary.BigEndian.Uint16(data[i+2:]))
+	}
+
+	return nil, false
+}
+
+// withJFIFHeader replaces the start of JPEG data, including any leading
+// JFIF segment, with the header used for encoded images.
+func withJFIFHeader(data []byte) []byte {
+	body := data[2:]
+	if len(body) >= 4 && body[0] == 0xFF && body[1] == 0xE0 {
+		if n := 2 + int(binary.BigEndian.Uint16(body[2:])); n <= len(body) {
+			body = body[n:]
+		}
+	}
+
+	return append(append(make([]byte, 0, len(jfifHeader)+len(body)), jfifHeader...), body...)
+}
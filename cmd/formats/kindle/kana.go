@@ -0,0 +1,73 @@
+package kindle
+
+import "strings"
+
+var kanaTable = map[rune]string{
+	'あ': "a", 'い': "i", 'う': "u", 'え': "e", 'お': "o",
+	'か': "ka", 'き': "ki", 'く': "ku", 'け': "ke", 'こ': "ko",
+	'が': "ga", 'ぎ': "gi", 'ぐ': "gu", 'げ': "ge", 'ご': "go",
+	'さ': "sa", 'し': "shi", 'す': "su", 'せ': "se", 'そ': "so",
+	'ざ': "za", 'じ': "ji", 'ず': "zu", 'ぜ': "ze", 'ぞ': "zo",
+	'た': "ta", 'ち': "chi", 'つ': "tsu", 'て': "te", 'と': "to",
+	'だ': "da", 'ぢ': "ji", 'づ': "zu", 'で': "de", 'ど': "do",
+	'な': "na", 'に': "ni", 'ぬ': "nu", 'ね': "ne", 'の': "no",
+	'は': "ha", 'ひ': "hi", 'ふ': "fu", 'へ': "he", 'ほ': "ho",
+	'ば': "ba", 'び': "bi", 'ぶ': "bu", 'べ': "be", 'ぼ': "bo",
+	'ぱ': "pa", 'ぴ': "pi", 'ぷ': "pu", 'ぺ': "pe", 'ぽ': "po",
+	'ま': "ma", 'み': "mi", 'む': "mu", 'め': "me", 'も': "mo",
+	'や': "ya", 'ゆ': "yu", 'よ': "yo",
+	'ら': "ra", 'り': "ri", 'る': "ru", 'れ': "re", 'ろ': "ro",
+	'わ': "wa", 'ゐ': "i", 'ゑ': "e", 'を': "o", 'ん': "n", 'ゔ': "vu",
+	'ぁ': "a", 'ぃ': "i", 'ぅ': "u", 'ぇ': "e", 'ぉ': "o",
+	'ゃ': "ya", 'ゅ': "yu", 'ょ': "yo", 'ゎ': "wa", 'ゕ': "ka", 'ゖ': "ke",
+}
+
+func isKana(r rune) bool {
+	return (r >= 'ぁ' && r <= 'ゖ') || (r >= 'ァ' && r <= 'ヺ') || r == 'ー'
+}
+
+// hiragana maps katakana to the corresponding hiragana.
+func hiragana(r rune) rune {
+	if r >= 'ァ' && r <= 'ヶ' {
+		return r - ('ァ' - 'ぁ')
+	}
+
+	return r
+}
+
+// writeKana writes the Hepburn romanization of the kana at the start
+// of the given runes and returns the number of runes consumed.
+func writeKana(buf *strings.Builder, runes []rune) int {
+	r := hiragana(runes[0])
+	next := rune(0)
+	if len(runes) > 1 {
+		next = hiragana(runes[1])
+	}
+
+	switch romaji, ok := kanaTable[r]; {
+	case r == 'ー':
+	case r == 'っ':
+		if following, ok := kanaTable[next]; ok && following != "n" {
+			if strings.HasPrefix(following, "ch") {
+				buf.WriteByte('t')
+			} else {
+				buf.WriteByte(following[0])
+			}
+		}
+	case ok && (next == 'ゃ' || next == 'ゅ' || next == 'ょ') && strings.HasSuffix(romaji, "i") && len(romaji) > 1:
+		stem := romaji[:len(romaji)-1]
+		if stem == "sh" || stem == "ch" || stem == "j" {
+			buf.WriteString(stem + kanaTable[next][1:])
+		} else {
+			buf.WriteString(stem + kanaTable[next])
+		}
+		return 2
+	case ok && strings.ContainsRune("ぁぃぅぇぉ", next) && len(romaji) > 1:
+		buf.WriteString(romaji[:len(romaji)-1] + kanaTable[next])
+		return 2
+	case ok:
+		buf.WriteString(romaji)
+	}
+
+	return 1
+}
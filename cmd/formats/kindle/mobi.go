@@ -60,6 +60,7 @@ func GenerateMOBI(manga mangadex.Manga) mobi.Book {
 		Title:        mangaToTitle(manga),
 		Authors:      manga.Info.Authors,
 		Contributors: groupNames,
+		Publisher:    manga.Info.Publisher,
 		CreatedDate:  time.Unix(0, 0),
 		Language:     mangaToLanguage(manga),
 		FixedLayout:  true,
@@ -96,16 +97,23 @@ func mangaToCover(manga mangadex.Manga) image.Image {
 	return manga.Sorted()[0].Cover
 }
 
+// mangaToLanguage returns the translation language used by most
+// chapters.  It is not matched against the locales supported by MOBI
+// here, so the exact language is preserved in the metadata.
 func mangaToLanguage(manga mangadex.Manga) language.Tag {
-	chaps := manga.Chapters()
-	if len(chaps) == 0 {
-		return language.Und
-	} else {
-		matcher := language.NewMatcher(mobi.SupportedLocales)
-		// multiple languages are not supported
-		_, i, _ := matcher.Match(chaps[0].Info.Language)
-		return mobi.SupportedLocales[i]
+	counts := make(map[language.Tag]int)
+	for _, chap := range manga.Chapters() {
+		counts[chap.Info.Language]++
 	}
+
+	result, count := language.Und, 0
+	for lang, n := range counts {
+		if n > count || (n == count && lang.String() < result.String()) {
+			result, count = lang, n
+		}
+	}
+
+	return result
 }
 
 func deduplicate(slice []string) []string {
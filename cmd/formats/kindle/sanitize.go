@@ -0,0 +1,193 @@
+package kindle
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Sanitizer controls how titles are converted to pathnames.
+type Sanitizer struct {
+	// Reserved selects the set of reserved characters, which is one of
+	// "auto", "windows", "darwin", "posix" or "portable".  Portable
+	// pathnames are valid on all platforms and FAT-formatted devices.
+	Reserved string
+	// Replacement is used instead of reserved characters.  If empty,
+	// similar looking fullwidth characters are used.
+	Replacement string
+	// MaxLength limits the length of pathnames in bytes.  If zero, the
+	// limit of most filesystems is used.
+	MaxLength int
+	// ASCII transliterates pathnames to ASCII.
+	ASCII bool
+}
+
+var DefaultSanitizer = Sanitizer{Reserved: "auto"}
+
+// maxNameLength is the longest name allowed by most filesystems.  FAT
+// and NTFS count UTF-16 code units, which never exceed the bytes.
+const maxNameLength = 255
+
+var fullwidth = map[rune]rune{
+	'"':  '＂',
+	'\\': '＼',
+	'<':  '＜',
+	'>':  '＞',
+	':':  '：',
+	'|':  '｜',
+	'?':  '？',
+	'*':  '＊',
+	'/':  '／',
+}
+
+func (s Sanitizer) Validate() error {
+	switch s.Reserved {
+	case "auto", "windows", "darwin", "posix", "portable":
+	default:
+		return fmt.Errorf(`not a valid set of reserved characters: "%v"`, s.Reserved)
+	}
+	if s.MaxLength < 0 {
+		return fmt.Errorf("not a valid maximum length: %v", s.MaxLength)
+	}
+
+	return nil
+}
+
+// Pathname converts the title to a name that is valid on the selected
+// platform.  Titles are normalized to NFC and control characters are
+// removed on all platforms.
+func (s Sanitizer) Pathname(title string) string {
+	title = strings.TrimSpace(norm.NFC.String(title))
+	replacement := s.Replacement
+	if ascii, ok := transliterate(title); s.ASCII && ok {
+		title = ascii
+		if replacement == "" {
+			replacement = "_"
+		}
+	}
+
+	reserved := s.reserved()
+	buf := new(strings.Builder)
+	for _, r := range title {
+		switch {
+		case unicode.IsControl(r) && unicode.IsSpace(r):
+			buf.WriteRune(' ')
+		case unicode.IsControl(r):
+			continue
+		case !strings.ContainsRune(reserved, r):
+			buf.WriteRune(r)
+		case replacement != "":
+			buf.WriteString(replacement)
+		default:
+			buf.WriteRune(fullwidth[r])
+		}
+	}
+
+	result := buf.String()
+	if s.windows() {
+		result = strings.TrimRight(result, ". ")
+		result = escapeDeviceName(result)
+	}
+	maxLength := s.MaxLength
+	if maxLength == 0 {
+		maxLength = maxNameLength
+	}
+	if len(result) > maxLength {
+		result = truncate(result, maxLength)
+	}
+	if result == "" {
+		result = "_"
+	}
+
+	return result
+}
+
+func (s Sanitizer) platform() string {
+	if s.Reserved == "auto" || s.Reserved == "" {
+		return runtime.GOOS
+	}
+
+	return s.Reserved
+}
+
+// windows reports whether Windows naming rules apply, which are also
+// used by FAT-formatted devices.
+func (s Sanitizer) windows() bool {
+	return s.platform() == "windows" || s.platform() == "portable"
+}
+
+func (s Sanitizer) reserved() string {
+	switch s.platform() {
+	case "windows", "portable":
+		return "\"\\<>:|?*/"
+	case "darwin":
+		return ":/"
+	default:
+		return "/"
+	}
+}
+
+// escapeDeviceName appends an underscore to names that refer to devices
+// on Windows, which are reserved regardless of their extension.
+func escapeDeviceName(name string) string {
+	base, extension, hasExtension := strings.Cut(name, ".")
+	switch strings.ToUpper(strings.TrimRight(base, " ")) {
+	case "CON", "PRN", "AUX", "NUL",
+		"COM1", "COM2", "COM3", "COM4", "COM5", "COM6", "COM7", "COM8", "COM9",
+		"LPT1", "LPT2", "LPT3", "LPT4", "LPT5", "LPT6", "LPT7", "LPT8", "LPT9":
+	default:
+		return name
+	}
+
+	if hasExtension {
+		return base + "_." + extension
+	}
+
+	return base + "_"
+}
+
+func truncate(s string, length int) string {
+	for length > 0 && !utf8.RuneStart(s[length]) {
+		length--
+	}
+
+	return strings.TrimRight(s[:length], ". ")
+}
+
+// transliterate converts the title to ASCII by removing diacritics and
+// romanizing kana.  Titles containing letters without a transliteration,
+// such as kanji, cannot be converted.
+func transliterate(title string) (string, bool) {
+	buf := new(strings.Builder)
+	runes := []rune(norm.NFC.String(title))
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r < utf8.RuneSelf:
+			buf.WriteRune(r)
+		case isKana(r):
+			i += writeKana(buf, runes[i:]) - 1
+		case unicode.IsSpace(r):
+			buf.WriteRune(' ')
+		case unicode.IsPunct(r):
+			buf.WriteRune('-')
+		default:
+			converted := false
+			for _, d := range norm.NFKD.String(string(r)) {
+				if d < utf8.RuneSelf {
+					buf.WriteRune(d)
+					converted = true
+				}
+			}
+			if !converted && unicode.IsLetter(r) {
+				return "", false
+			}
+		}
+	}
+
+	return strings.Join(strings.Fields(buf.String()), " "), true
+}
@@ -0,0 +1,161 @@
+package kindle
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+)
+
+type Status string
+
+const (
+	StatusOK        Status = "ok"
+	StatusMissing   Status = "missing"
+	StatusMismatch  Status = "mismatch"
+	StatusCorrupt   Status = "corrupt"
+	StatusUntracked Status = "untracked"
+)
+
+type VerifyResult struct {
+	Directory string
+	Filename  string
+	Status    Status
+	Detail    string `json:",omitempty"`
+}
+
+// Verify checks all e-books in the given directories against the
+// recorded checksums and ensures that they can still be opened.  Files
+// of all directories are hashed concurrently, as reading large e-books
+// is mostly limited by the disk.
+func Verify(directories ...string) ([]VerifyResult, error) {
+	results := make([]VerifyResult, 0)
+	expected := make([]string, 0)
+	for _, directory := range directories {
+		sums, err := verifiedFiles(directory)
+		if err != nil {
+			return nil, fmt.Errorf("verify '%v': %w", directory, err)
+		}
+		filenames := make([]string, 0)
+		for filename := range sums {
+			filenames = append(filenames, filename)
+		}
+		sort.Strings(filenames)
+		for _, filename := range filenames {
+			results = append(results, VerifyResult{Directory: directory, Filename: filename})
+			expected = append(expected, sums[filename])
+		}
+	}
+
+	eg := new(errgroup.Group)
+	eg.SetLimit(runtime.NumCPU())
+	for i := range results {
+		result, sum := &results[i], expected[i]
+		eg.Go(func() error {
+			result.Status, result.Detail = verifyFile(filepath.Join(result.Directory, result.Filename), sum)
+			return nil
+		})
+	}
+
+	return results, eg.Wait()
+}
+
+// verifiedFiles returns the recorded checksums of the directory, with
+// empty checksums for untracked e-books.
+func verifiedFiles(directory string) (map[string]string, error) {
+	sums, err := ReadChecksums(directory)
+	if err != nil {
+		return nil, fmt.Errorf("checksums: %w", err)
+	}
+
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		return nil, fmt.Errorf("list '%v': %w", directory, err)
+	}
+	for _, entry := range entries {
+		if _, ok := sums[entry.Name()]; !ok && strings.HasSuffix(entry.Name(), ".azw3") {
+			sums[entry.Name()] = ""
+		}
+	}
+
+	return sums, nil
+}
+
+func verifyFile(pathname, expected string) (Status, string) {
+	f, err := os.Open(pathname)
+	if errors.Is(err, fs.ErrNotExist) {
+		return StatusMissing, ""
+	} else if err != nil {
+		return StatusCorrupt, err.Error()
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return StatusCorrupt, err.Error()
+	}
+	if err := checkPDB(f, info.Size()); err != nil {
+		return StatusCorrupt, err.Error()
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, io.NewSectionReader(f, 0, info.Size())); err != nil {
+		return StatusCorrupt, err.Error()
+	}
+	switch actual := hex.EncodeToString(hash.Sum(nil)); {
+	case expected == "":
+		return StatusUntracked, ""
+	case actual != expected:
+		return StatusMismatch, fmt.Sprintf("expected %v, got %v", expected, actual)
+	default:
+		return StatusOK, ""
+	}
+}
+
+// checkPDB performs a structural check of the Palm database container
+// used by KF8 books, which catches truncated and overwritten files.
+func checkPDB(r io.ReaderAt, size int64) error {
+	header := make([]byte, 78)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return fmt.Errorf("header: %w", err)
+	}
+	if string(header[60:68]) != "BOOKMOBI" {
+		return fmt.Errorf("header: not a MOBI book")
+	}
+
+	count := int(binary.BigEndian.Uint16(header[76:78]))
+	if count == 0 {
+		return fmt.Errorf("header: no records")
+	}
+	records := make([]byte, count*8)
+	if _, err := r.ReadAt(records, 78); err != nil {
+		return fmt.Errorf("records: %w", err)
+	}
+
+	last := uint32(0)
+	for i := 0; i < count; i++ {
+		offset := binary.BigEndian.Uint32(records[i*8:])
+		if offset < last || int64(offset) >= size {
+			return fmt.Errorf("record %v: invalid offset %v", i, offset)
+		}
+		last = offset
+	}
+
+	magic := make([]byte, 4)
+	first := binary.BigEndian.Uint32(records)
+	if _, err := r.ReadAt(magic, int64(first)+16); err != nil || string(magic) != "MOBI" {
+		return fmt.Errorf("record 0: missing MOBI header")
+	}
+
+	return nil
+}
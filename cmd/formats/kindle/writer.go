@@ -0,0 +1,106 @@
+package kindle
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"time"
+
+	"github.com/leotaku/kojirou/cmd/formats"
+	"github.com/leotaku/kojirou/codec"
+	md "github.com/leotaku/kojirou/mangadex"
+	"github.com/leotaku/mobi"
+	"golang.org/x/text/language"
+)
+
+// MOBIOptions customizes generated KF8 e-books.
+type MOBIOptions struct {
+	LeftToRight      bool
+	FillVolumeNumber int
+	// Language overrides the language of the chapters, unless it is
+	// undetermined.
+	Language language.Tag
+	// Encoder encodes images that are not copied, which defaults to
+	// codec.DefaultEncoder.  If Reencode is set, all images are
+	// encoded.
+	Encoder  codec.Encoder
+	Reencode bool
+}
+
+// MOBIWriter generates KF8 e-books, which use the AZW3 file extension.
+// Unlike the other writers, it keeps every page of the volume until
+// Finish, as the mobi library needs the whole book to lay out its
+// records.
+type MOBIWriter struct {
+	options  MOBIOptions
+	skeleton md.Manga
+	volume   md.Identifier
+	chapters md.ChapterList
+	pages    md.ImageList
+	book     mobi.Book
+}
+
+func NewMOBIWriter(options MOBIOptions) *MOBIWriter {
+	return &MOBIWriter{options: options}
+}
+
+func (w *MOBIWriter) Extension() string {
+	return ".azw3"
+}
+
+func (w *MOBIWriter) Begin(manga md.Manga, volume md.Identifier) error {
+	w.skeleton = manga
+	w.volume = volume
+	w.chapters = make(md.ChapterList, 0)
+	w.pages = make(md.ImageList, 0)
+
+	return nil
+}
+
+func (w *MOBIWriter) AddChapter(chapter md.ChapterInfo) error {
+	w.chapters = append(w.chapters, md.Chapter{
+		Info:  chapter,
+		Pages: make(map[int]image.Image),
+	})
+
+	return nil
+}
+
+func (w *MOBIWriter) AddPage(page md.Image) error {
+	w.pages = append(w.pages, page)
+
+	return nil
+}
+
+func (w *MOBIWriter) Finish(out io.Writer) error {
+	w.book = GenerateMOBI(w.skeleton.WithChapters(w.chapters).WithPages(w.pages))
+	w.book.RightToLeft = !w.options.LeftToRight
+	if w.options.Language != language.Und {
+		w.book.Language = w.options.Language
+	}
+	w.book.Title = fmt.Sprintf("%v: %v",
+		w.skeleton.Info.Title,
+		w.volume.StringFilled(w.options.FillVolumeNumber, 0, false),
+	)
+
+	w.book.CreatedDate = w.Timestamp()
+
+	db, err := realize(w.book, w.options)
+	if err != nil {
+		return err
+	}
+
+	return db.Write(out)
+}
+
+// Thumbnail returns the cover thumbnail used by Kindle devices, which
+// is only available once the e-book has been written.
+func (w *MOBIWriter) Thumbnail() (string, image.Image) {
+	return w.book.GetThumbFilename(), w.book.CoverImage
+}
+
+// Timestamp returns the stable creation date of the e-book, see
+// formats.Timestamp.
+func (w *MOBIWriter) Timestamp() time.Time {
+	return formats.Timestamp(w.chapters)
+}
@@ -0,0 +1,118 @@
+package formats
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+type Level int
+
+const (
+	LevelError Level = iota
+	LevelWarning
+	LevelInfo
+	LevelDebug
+)
+
+var levelNames = map[string]Level{
+	"error":   LevelError,
+	"warning": LevelWarning,
+	"info":    LevelInfo,
+	"debug":   LevelDebug,
+}
+
+var (
+	level   = LevelInfo
+	logger  *slog.Logger
+	logFile *os.File
+)
+
+func ParseLevel(name string) (Level, error) {
+	if l, ok := levelNames[name]; ok {
+		return l, nil
+	}
+
+	return 0, fmt.Errorf(`not a valid log level: "%v"`, name)
+}
+
+func SetLevel(l Level) {
+	level = l
+}
+
+func Enabled(l Level) bool {
+	return l <= level
+}
+
+// OpenLogFile appends all messages to the given file, or stderr if the
+// filename is "-", independent of the log level used for terminal
+// output.  The format is either "text" or "json".
+func OpenLogFile(filename, format string) error {
+	var w io.Writer = os.Stderr
+	if filename != "-" {
+		f, err := os.OpenFile(filename, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return err
+		}
+		logFile = f
+		w = f
+	}
+
+	options := &slog.HandlerOptions{Level: slog.LevelDebug}
+	switch format {
+	case "text":
+		logger = slog.New(slog.NewTextHandler(w, options))
+	case "json":
+		logger = slog.New(slog.NewJSONHandler(w, options))
+	default:
+		CloseLogFile()
+		return fmt.Errorf(`not a valid log format: "%v"`, format)
+	}
+
+	return nil
+}
+
+func CloseLogFile() {
+	if logFile != nil {
+		logFile.Close()
+	}
+	logFile = nil
+	logger = nil
+}
+
+func writeLog(l slog.Level, message string, args ...interface{}) {
+	if logger != nil {
+		logger.Log(context.Background(), l, message, args...)
+	}
+}
+
+// Debug prints details about the progress of the pipeline, which are
+// only shown when verbose output has been requested.  The arguments are
+// alternating keys and values that describe the context, such as the
+// chapter or volume.
+func Debug(message string, args ...interface{}) {
+	writeLog(slog.LevelDebug, message, args...)
+	if Enabled(LevelDebug) {
+		faint := color.New(color.Faint)
+		fmt.Fprintln(os.Stderr, faint.Sprint(message+formatArgs(args)))
+	}
+}
+
+func formatArgs(args []interface{}) string {
+	var b strings.Builder
+	for i := 0; i+1 < len(args); i += 2 {
+		fmt.Fprintf(&b, " %v=%v", args[i], args[i+1])
+	}
+
+	return b.String()
+}
+
+// LogError records the error that caused the program to exit.
+func LogError(err error) {
+	writeLog(slog.LevelError, err.Error())
+}
@@ -0,0 +1,9 @@
+//go:build !windows
+
+package formats
+
+// LongPath returns the pathname unchanged, as only Windows limits the
+// length of pathnames.
+func LongPath(pathname string) string {
+	return pathname
+}
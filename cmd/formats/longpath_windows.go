@@ -0,0 +1,15 @@
+package formats
+
+import "path/filepath"
+
+// LongPath returns the absolute form of the pathname, for which the os
+// package uses extended-length paths, so that deeply nested output is
+// not limited to MAX_PATH.
+func LongPath(pathname string) string {
+	abs, err := filepath.Abs(filepath.FromSlash(pathname))
+	if err != nil {
+		return pathname
+	}
+
+	return filepath.ToSlash(abs)
+}
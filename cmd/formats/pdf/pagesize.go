@@ -0,0 +1,63 @@
+package pdf
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// pointsPerInch is the number of PDF user space units in an inch.
+const pointsPerInch = 72
+
+// PageSize is the size of all pages of a document in points.  The zero
+// value fits every page to its image instead, see PDFOptions.DPI.
+type PageSize struct {
+	Width  float64
+	Height float64
+}
+
+// pageSizes are the named page sizes accepted by ParsePageSize.
+var pageSizes = map[string]PageSize{
+	"fit":    {},
+	"a4":     {Width: 595.28, Height: 841.89},
+	"a5":     {Width: 419.53, Height: 595.28},
+	"b5":     {Width: 498.9, Height: 708.66},
+	"letter": {Width: 612, Height: 792},
+}
+
+// PageSizeNames returns the names of all named page sizes.
+func PageSizeNames() []string {
+	return []string{"fit", "a4", "a5", "b5", "letter"}
+}
+
+// ParsePageSize parses a named page size, such as "a5", or a custom
+// size in millimeters or inches, such as "150x200mm" or "6x8in".
+func ParsePageSize(s string) (PageSize, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if size, ok := pageSizes[s]; ok {
+		return size, nil
+	}
+
+	unit := 0.0
+	switch {
+	case strings.HasSuffix(s, "mm"):
+		unit = pointsPerInch / 25.4
+	case strings.HasSuffix(s, "in"):
+		unit = pointsPerInch
+	default:
+		return PageSize{}, fmt.Errorf(`not a valid page size: "%v"`, s)
+	}
+	width, height, ok := strings.Cut(s[:len(s)-2], "x")
+	w, werr := strconv.ParseFloat(width, 64)
+	h, herr := strconv.ParseFloat(height, 64)
+	if !ok || werr != nil || herr != nil || w <= 0 || h <= 0 {
+		return PageSize{}, fmt.Errorf(`not a valid page size: "%v"`, s)
+	}
+
+	return PageSize{Width: w * unit, Height: h * unit}, nil
+}
+
+// fits reports whether pages are sized to fit their images.
+func (s PageSize) fits() bool {
+	return s.Width == 0 || s.Height == 0
+}
@@ -0,0 +1,492 @@
+package pdf
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"math"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/leotaku/kojirou/cmd/formats"
+	"github.com/leotaku/kojirou/codec"
+	md "github.com/leotaku/kojirou/mangadex"
+	"golang.org/x/text/language"
+)
+
+// DefaultDPI is the resolution of pages that fit their images, at
+// which typical manga pages are about the size of a tablet screen.
+const DefaultDPI = 150
+
+// PDFOptions customizes generated documents.
+type PDFOptions struct {
+	PageSize PageSize
+	// DPI is the resolution of images on pages that fit them, which
+	// determines the physical size of such pages.
+	DPI              int
+	LeftToRight      bool
+	FillVolumeNumber int
+	// Language overrides the language of the chapters, unless it is
+	// undetermined.
+	Language language.Tag
+	// Encoder encodes images that are not copied, which defaults to
+	// codec.DefaultEncoder.  If Reencode is set, all images are
+	// encoded.
+	Encoder  codec.Encoder
+	Reencode bool
+}
+
+// PDFWriter generates PDF documents with one image per page, which
+// most tablets and computers can display.  Chapters are listed in the
+// outline of the document.  Pages are written to a spool as they are
+// added, while the page tree that puts them in reading order is written
+// by Finish.
+type PDFWriter struct {
+	options  PDFOptions
+	skeleton md.Manga
+	volume   md.Identifier
+	chapters md.ChapterList
+	spool    *formats.Spool
+	document *document
+	pages    []pageItem
+}
+
+func NewPDFWriter(options PDFOptions) *PDFWriter {
+	return &PDFWriter{options: options}
+}
+
+func (w *PDFWriter) Extension() string {
+	return ".pdf"
+}
+
+// Begin reserves the first objects for the catalog, the page tree, the
+// document information and the outline, which are written by Finish,
+// and writes the cover.
+func (w *PDFWriter) Begin(manga md.Manga, volume md.Identifier) error {
+	w.Abort()
+	spool, err := formats.CreateSpool()
+	if err != nil {
+		return err
+	}
+	w.skeleton = manga
+	w.volume = volume
+	w.chapters = make(md.ChapterList, 0)
+	w.spool = spool
+	w.document = newDocument(spool, 4)
+	w.pages = make([]pageItem, 0)
+
+	if cover := manga.Volumes[volume].Cover; cover != nil {
+		if err := w.writePage(cover, pageItem{cover: true}); err != nil {
+			return fmt.Errorf("cover: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (w *PDFWriter) AddChapter(chapter md.ChapterInfo) error {
+	w.chapters = append(w.chapters, md.Chapter{
+		Info:  chapter,
+		Pages: make(map[int]image.Image),
+	})
+
+	return nil
+}
+
+// AddPage writes the page to the spool.  Pages of chapters that are not
+// part of the volume are ignored.
+func (w *PDFWriter) AddPage(page md.Image) error {
+	if _, ok := w.skeleton.Volumes[w.volume].Chapters[page.ChapterIdentifier]; !ok {
+		return nil
+	}
+	if err := w.writePage(page.Image, pageItem{chapter: page.ChapterIdentifier, index: page.ImageIdentifier}); err != nil {
+		return fmt.Errorf("page %v: %w", len(w.pages)+1, err)
+	}
+
+	return nil
+}
+
+// pageItem is a written page, whose page object is referenced by the
+// page tree.
+type pageItem struct {
+	object  int
+	cover   bool
+	chapter md.Identifier
+	index   int
+}
+
+// outlineItem is a chapter in the outline, which starts at the page
+// with the given object number.
+type outlineItem struct {
+	title  string
+	object int
+}
+
+// Finish sorts the written pages into reading order and writes the
+// objects reserved by Begin, followed by the outline items, before
+// copying the document to the output.
+func (w *PDFWriter) Finish(out io.Writer) error {
+	defer w.Abort()
+	volume := w.skeleton.WithChapters(w.chapters).Volumes[w.volume]
+	order := formats.ChapterOrder(volume)
+	pages := make([]pageItem, 0, len(w.pages))
+	for _, page := range w.pages {
+		if _, ok := order[page.chapter]; ok || page.cover {
+			pages = append(pages, page)
+		}
+	}
+	sort.SliceStable(pages, func(i, j int) bool {
+		a, b := pages[i], pages[j]
+		if a.cover || b.cover {
+			return a.cover && !b.cover
+		} else if order[a.chapter] != order[b.chapter] {
+			return order[a.chapter] < order[b.chapter]
+		}
+		return a.index < b.index
+	})
+	if len(pages) == 0 {
+		return fmt.Errorf("no pages")
+	}
+
+	items := make([]outlineItem, 0)
+	for i, page := range pages {
+		if page.cover || (i > 0 && pages[i-1].chapter == page.chapter && !pages[i-1].cover) {
+			continue
+		}
+		info := volume.Chapters[page.chapter].Info
+		title := info.Identifier.String()
+		if info.Title != "" {
+			title = fmt.Sprintf("%v: %v", info.Identifier, info.Title)
+		}
+		items = append(items, outlineItem{title: title, object: page.object})
+	}
+
+	d := w.document
+	firstItem := d.reserve(len(items))
+	itemObject := func(i int) int { return firstItem + i }
+
+	catalog := "<< /Type /Catalog /Pages 2 0 R /Outlines 4 0 R"
+	if len(items) > 0 {
+		catalog += " /PageMode /UseOutlines"
+	}
+	if !w.options.LeftToRight {
+		catalog += " /ViewerPreferences << /Direction /R2L >>"
+	}
+	if lang := w.language(); lang != language.Und {
+		catalog += " /Lang " + textString(lang.String())
+	}
+	d.object(1, catalog+" >>")
+
+	kids := make([]string, 0, len(pages))
+	for _, page := range pages {
+		kids = append(kids, fmt.Sprintf("%v 0 R", page.object))
+	}
+	d.object(2, fmt.Sprintf("<< /Type /Pages /Kids [%v] /Count %v >>", strings.Join(kids, " "), len(pages)))
+
+	date := textString("D:" + w.Timestamp().UTC().Format("20060102150405") + "Z")
+	d.object(3, fmt.Sprintf("<< /Title %v /Author %v /Creator (Kojirou) /CreationDate %v /ModDate %v >>",
+		textString(w.title()), textString(strings.Join(w.skeleton.Info.Authors, ", ")), date, date))
+
+	if len(items) > 0 {
+		d.object(4, fmt.Sprintf("<< /Type /Outlines /First %v 0 R /Last %v 0 R /Count %v >>",
+			itemObject(0), itemObject(len(items)-1), len(items)))
+	} else {
+		d.object(4, "<< /Type /Outlines /Count 0 >>")
+	}
+
+	for i, item := range items {
+		links := ""
+		if i > 0 {
+			links += fmt.Sprintf(" /Prev %v 0 R", itemObject(i-1))
+		}
+		if i < len(items)-1 {
+			links += fmt.Sprintf(" /Next %v 0 R", itemObject(i+1))
+		}
+		d.object(itemObject(i), fmt.Sprintf("<< /Title %v /Parent 4 0 R%v /Dest [%v 0 R /Fit] >>",
+			textString(item.title), links, item.object))
+	}
+
+	if err := d.finish(1, 3); err != nil {
+		return err
+	}
+
+	return w.spool.CopyTo(out)
+}
+
+// Abort removes the spool of an unfinished document.
+func (w *PDFWriter) Abort() {
+	w.spool.Remove()
+}
+
+// writePage writes the image, content and page objects of a page and
+// remembers the page object.
+func (w *PDFWriter) writePage(img image.Image, item pageItem) error {
+	data, config, filter, err := w.imageStream(img)
+	if err != nil {
+		return err
+	}
+	colorSpace := "/DeviceRGB"
+	if config.ColorModel == color.GrayModel {
+		colorSpace = "/DeviceGray"
+	}
+	d := w.document
+	object := d.reserve(3)
+	d.stream(object, fmt.Sprintf("/Type /XObject /Subtype /Image /Width %v /Height %v /ColorSpace %v /BitsPerComponent 8 /Filter %v",
+		config.Width, config.Height, colorSpace, filter), data)
+	runtime.KeepAlive(img)
+
+	width, height, box := w.layout(config.Width, config.Height)
+	content := fmt.Sprintf("q %v 0 0 %v %v %v cm /Im0 Do Q", number(box.W), number(box.H), number(box.X), number(box.Y))
+	d.stream(object+1, "", []byte(content))
+	d.object(object+2, fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %v %v] /Resources << /XObject << /Im0 %v 0 R >> >> /Contents %v 0 R >>",
+		number(width), number(height), object, object+1))
+	if d.err != nil {
+		return d.err
+	}
+	item.object = object + 2
+	w.pages = append(w.pages, item)
+
+	return nil
+}
+
+// placement is the rectangle an image is drawn in, in points.
+type placement struct {
+	X, Y, W, H float64
+}
+
+// layout returns the size of the page for an image of the given size
+// in pixels and where the image is drawn on it.  Images are scaled to
+// fit pages of a fixed size and centered on them.
+func (w *PDFWriter) layout(width, height int) (float64, float64, placement) {
+	if w.options.PageSize.fits() {
+		dpi := w.options.DPI
+		if dpi <= 0 {
+			dpi = DefaultDPI
+		}
+		scale := float64(pointsPerInch) / float64(dpi)
+		box := placement{W: float64(width) * scale, H: float64(height) * scale}
+		return box.W, box.H, box
+	}
+
+	pw, ph := w.options.PageSize.Width, w.options.PageSize.Height
+	scale := min(pw/float64(width), ph/float64(height))
+	box := placement{W: float64(width) * scale, H: float64(height) * scale}
+	box.X, box.Y = (pw-box.W)/2, (ph-box.H)/2
+
+	return pw, ph, box
+}
+
+// Timestamp returns the stable creation date of the document, see
+// formats.Timestamp.
+func (w *PDFWriter) Timestamp() time.Time {
+	return formats.Timestamp(w.chapters)
+}
+
+func (w *PDFWriter) title() string {
+	return fmt.Sprintf("%v: %v",
+		w.skeleton.Info.Title,
+		w.volume.StringFilled(w.options.FillVolumeNumber, 0, false),
+	)
+}
+
+func (w *PDFWriter) language() language.Tag {
+	if w.options.Language != language.Und {
+		return w.options.Language
+	}
+	for _, chapter := range w.chapters {
+		if chapter.Info.Language != language.Und {
+			return chapter.Info.Language
+		}
+	}
+
+	return language.Und
+}
+
+// imageStream returns the data of the image stream, its configuration
+// and the filter that decodes it.  PDF documents cannot embed PNG
+// images, so encoders other than JPEG store compressed pixels instead,
+// which are lossless as well.
+func (w *PDFWriter) imageStream(img image.Image) ([]byte, image.Config, string, error) {
+	encoder := w.options.Encoder
+	if encoder == nil {
+		encoder = codec.DefaultEncoder
+	}
+	if encoder.Format() != codec.JPEG.Name {
+		data, config, err := flateData(img)
+		return data, config, "/FlateDecode", err
+	}
+	data, config, err := jpegData(img, encoder, w.options.Reencode)
+
+	return data, config, "/DCTDecode", err
+}
+
+// jpegData returns the image as JPEG data, which PDF documents embed
+// without decoding it.  Unmodified JPEG images are copied unless
+// reencode is set, except for CMYK images, which are often stored
+// inverted.
+func jpegData(img image.Image, encoder codec.Encoder, reencode bool) ([]byte, image.Config, error) {
+	if encoded, ok := codec.Original(img); ok && encoded.Format == codec.JPEG.Name && !reencode {
+		config, err := jpeg.DecodeConfig(bytes.NewReader(encoded.Data))
+		if err == nil && config.ColorModel != color.CMYKModel {
+			return encoded.Data, config, nil
+		}
+	}
+
+	data, _, err := codec.Encode(encoder, img)
+	if err != nil {
+		return nil, image.Config{}, err
+	}
+	config, err := jpeg.DecodeConfig(bytes.NewReader(data))
+
+	return data, config, err
+}
+
+// flateData returns the pixels of the image as compressed 8-bit gray
+// or RGB samples.  Transparency is ignored, as for JPEG images.
+func flateData(img image.Image) ([]byte, image.Config, error) {
+	decoded, err := codec.Decoded(img)
+	if err != nil {
+		return nil, image.Config{}, err
+	}
+	bounds := decoded.Bounds()
+	config := image.Config{ColorModel: color.RGBAModel, Width: bounds.Dx(), Height: bounds.Dy()}
+
+	buf := new(bytes.Buffer)
+	zw, err := zlib.NewWriterLevel(buf, zlib.BestCompression)
+	if err != nil {
+		return nil, image.Config{}, err
+	}
+	if gray, ok := decoded.(*image.Gray); ok {
+		config.ColorModel = color.GrayModel
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			i := gray.PixOffset(bounds.Min.X, y)
+			zw.Write(gray.Pix[i : i+bounds.Dx()]) //nolint:errcheck
+		}
+	} else {
+		row := make([]byte, 0, 3*bounds.Dx())
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			row = row[:0]
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				r, g, b, _ := decoded.At(x, y).RGBA()
+				row = append(row, uint8(r>>8), uint8(g>>8), uint8(b>>8))
+			}
+			zw.Write(row) //nolint:errcheck
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, image.Config{}, err
+	}
+
+	return buf.Bytes(), config, nil
+}
+
+// document writes the objects of a PDF file and remembers their
+// offsets for the cross-reference table.  Objects may be written in any
+// order once their numbers are reserved.  The first error is kept and
+// stops all further writes.
+type document struct {
+	w       *bufio.Writer
+	offset  int
+	offsets []int
+	err     error
+}
+
+// newDocument writes the header of the file and reserves the given
+// number of objects.
+func newDocument(out io.Writer, objects int) *document {
+	d := &document{w: bufio.NewWriter(out), offsets: make([]int, objects+1)}
+	d.printf("%%PDF-1.4\n%%\xe2\xe3\xcf\xd3\n")
+
+	return d
+}
+
+func (d *document) printf(format string, args ...interface{}) {
+	if d.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(d.w, format, args...)
+	d.offset += n
+	d.err = err
+}
+
+func (d *document) write(data []byte) {
+	if d.err != nil {
+		return
+	}
+	n, err := d.w.Write(data)
+	d.offset += n
+	d.err = err
+}
+
+// reserve returns the first of the given number of new object numbers.
+func (d *document) reserve(n int) int {
+	first := len(d.offsets)
+	d.offsets = append(d.offsets, make([]int, n)...)
+
+	return first
+}
+
+func (d *document) object(id int, body string) {
+	d.offsets[id] = d.offset
+	d.printf("%v 0 obj\n%v\nendobj\n", id, body)
+}
+
+func (d *document) stream(id int, dict string, data []byte) {
+	d.offsets[id] = d.offset
+	d.printf("%v 0 obj\n<< %v /Length %v >>\nstream\n", id, strings.TrimSpace(dict), len(data))
+	d.write(data)
+	d.printf("\nendstream\nendobj\n")
+}
+
+// finish writes the cross-reference table and trailer.
+func (d *document) finish(root, info int) error {
+	start := d.offset
+	d.printf("xref\n0 %v\n0000000000 65535 f \n", len(d.offsets))
+	for _, offset := range d.offsets[1:] {
+		d.printf("%010d 00000 n \n", offset)
+	}
+	d.printf("trailer\n<< /Size %v /Root %v 0 R /Info %v 0 R >>\nstartxref\n%v\n%%%%EOF\n", len(d.offsets), root, info, start)
+	if d.err != nil {
+		return d.err
+	}
+
+	return d.w.Flush()
+}
+
+// textString encodes text as a PDF string, using UTF-16 for text that
+// is not printable ASCII.
+func textString(s string) string {
+	ascii := true
+	for _, r := range s {
+		if r < 0x20 || r > 0x7e {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		return "(" + strings.NewReplacer(`\`, `\\`, "(", `\(`, ")", `\)`).Replace(s) + ")"
+	}
+
+	var b strings.Builder
+	b.WriteString("<FEFF")
+	for _, unit := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&b, "%04X", unit)
+	}
+	b.WriteString(">")
+
+	return b.String()
+}
+
+// number formats a length in points with at most two decimals.
+func number(f float64) string {
+	return strconv.FormatFloat(math.Round(f*100)/100, 'f', -1, 64)
+}
@@ -1,7 +1,11 @@
 package formats
 
 import (
+	"fmt"
 	"io"
+	"log/slog"
+	"sync/atomic"
+	"time"
 
 	"github.com/cheggaaa/pb/v3"
 )
@@ -13,56 +17,189 @@ const (
 		`{{ if string . "message" }}` +
 		`{{   string . "message" | printf "%-15v" }}` +
 		`{{ else }}` +
-		`{{   counters . | printf "%-15v" }}` +
-		`{{ end }}` + `{{ " |" }}`
+		`{{   printf "%v%v" (counters .) (failures .) | printf "%-15v" }}` +
+		`{{ end }}` + `{{ " " }}` +
+		`{{ with string . "stage" }}{{ printf "%-10v" . }}{{ end }}` +
+		`{{ throughput . | printf "%-11v" }}` +
+		`{{ rtime . "ETA %s" "%s" "" | printf "%-10v" }}` + `{{ " |" }}`
+	throughputKey = "throughput"
+	failuresKey   = "failures"
+	stageKey      = "stage"
 )
 
+func init() {
+	pb.RegisterElement("throughput", pb.ElementFunc(throughput), false)
+	pb.RegisterElement("failures", pb.ElementFunc(failures), false)
+}
+
+// throughput renders the number of bytes per second that have been
+// read or written since the progress bar was started.
+func throughput(state *pb.State, args ...string) string {
+	bytes, ok := state.Get(throughputKey).(*int64)
+	elapsed := state.Time().Sub(state.StartTime()).Seconds()
+	if !ok || elapsed <= 0 || atomic.LoadInt64(bytes) == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("%.1f MB/s", float64(atomic.LoadInt64(bytes))/elapsed/1e6)
+}
+
+// failures renders the number of items that could not be processed.
+func failures(state *pb.State, args ...string) string {
+	failed, ok := state.Get(failuresKey).(*int64)
+	if !ok || atomic.LoadInt64(failed) == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf(" (%v failed)", atomic.LoadInt64(failed))
+}
+
+// Progress reports the progress of a single phase of the pipeline,
+// such as fetching the pages of a volume.
 type Progress interface {
+	// Increase adds items to the total number of items.
 	Increase(int)
+	// Add marks items as completed.
 	Add(int)
+	// AddBytes counts bytes that have been transferred without using
+	// one of the proxies.
+	AddBytes(int64)
+	// SetStage names the step of the phase that is currently running,
+	// such as the source that pages are fetched from.
+	SetStage(string)
+	// Fail marks an item as completed without success.  The error is
+	// reported, but does not stop the phase.
+	Fail(item string, err error)
+	NewProxyReader(io.Reader) io.Reader
 	NewProxyWriter(io.Writer) io.Writer
 }
 
 type CliProgress struct {
 	bar       *pb.ProgressBar
+	bytes     *int64
+	failed    *int64
+	start     time.Time
+	events    *eventStream
+	title     string
 	firstCall bool
 }
 
 func (p CliProgress) Increase(n int) {
 	p.bar.AddTotal(int64(n))
+	p.events.update(p, "update", "")
 }
 
 func (p CliProgress) Add(n int) {
 	p.bar.Add(n)
+	p.events.update(p, "update", "")
+}
+
+func (p CliProgress) AddBytes(n int64) {
+	atomic.AddInt64(p.bytes, n)
+}
+
+func (p CliProgress) SetStage(stage string) {
+	p.bar.Set(stageKey, stage)
+	p.events.update(p, "stage", stage)
+	writeLog(slog.LevelInfo, p.title, "stage", stage)
+}
+
+func (p CliProgress) Fail(item string, err error) {
+	atomic.AddInt64(p.failed, 1)
+	p.bar.Add(1)
+	p.events.update(p, "failure", fmt.Sprintf("%v: %v", item, err))
+	Report(p.title, "%v: %v", item, err)
+}
+
+// Elapsed returns the time since the progress bar was started.
+func (p CliProgress) Elapsed() time.Duration {
+	return time.Since(p.start)
+}
+
+// Failed returns the number of items that could not be processed.
+func (p CliProgress) Failed() int {
+	return int(atomic.LoadInt64(p.failed))
+}
+
+// NewProxyReader counts the bytes read for throughput calculation,
+// without changing the number of completed items.
+func (p CliProgress) NewProxyReader(r io.Reader) io.Reader {
+	return &countingReader{r, p.bytes}
 }
 
 func (p CliProgress) NewProxyWriter(w io.Writer) io.Writer {
-	return p.bar.NewProxyWriter(w)
+	return p.bar.NewProxyWriter(&countingWriter{w, p.bytes})
 }
 
 func (p CliProgress) Done() {
 	p.bar.Finish()
+	p.events.update(p, "done", "")
+	writeLog(slog.LevelInfo, p.title, "status", "done", "current", p.bar.Current(), "total", p.bar.Total(),
+		"failed", p.Failed(), "duration", p.Elapsed())
 }
 
 func (p *CliProgress) Cancel(message string) {
 	p.bar.Set("message", message)
 	p.bar.SetTotal(1).SetCurrent(1)
-	p.Done()
+	p.bar.Finish()
+	p.events.update(*p, "cancel", message)
+	writeLog(slog.LevelInfo, p.title, "status", message, "duration", p.Elapsed())
 }
 
 func TitledProgress(title string) CliProgress {
-	bar := pb.New(0).SetTemplate(progressTemplate)
-	bar.Set("prefix", title)
-	bar.Start()
-
-	return CliProgress{bar, true}
+	return newProgress(title, false, false)
 }
 
 func VanishingProgress(title string) CliProgress {
+	return newProgress(title, true, false)
+}
+
+// BackgroundProgress is never drawn, for phases that run alongside
+// another phase whose progress is drawn.  It is still logged and
+// reported as events.
+func BackgroundProgress(title string) CliProgress {
+	return newProgress(title, true, true)
+}
+
+func newProgress(title string, vanishing, hidden bool) CliProgress {
+	bytes, failed := new(int64), new(int64)
 	bar := pb.New(0).SetTemplate(progressTemplate)
 	bar.Set("prefix", title)
-	bar.Set(pb.CleanOnFinish, true)
+	bar.Set(throughputKey, bytes)
+	bar.Set(failuresKey, failed)
+	bar.Set(pb.CleanOnFinish, vanishing)
+	if !Enabled(LevelInfo) || eventsEnabled || hidden {
+		bar.SetWriter(io.Discard)
+	}
 	bar.Start()
 
-	return CliProgress{bar, true}
+	p := CliProgress{bar: bar, bytes: bytes, failed: failed, start: time.Now(), title: title, firstCall: true}
+	if eventsEnabled {
+		p.events = &eventStream{phase: title}
+		p.events.update(p, "start", "")
+	}
+
+	return p
+}
+
+type countingReader struct {
+	r     io.Reader
+	bytes *int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	atomic.AddInt64(c.bytes, int64(n))
+	return n, err
+}
+
+type countingWriter struct {
+	w     io.Writer
+	bytes *int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	atomic.AddInt64(c.bytes, int64(n))
+	return n, err
 }
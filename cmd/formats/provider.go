@@ -0,0 +1,30 @@
+package formats
+
+import (
+	"context"
+
+	md "github.com/leotaku/kojirou/mangadex"
+)
+
+// Provider is a source of manga, such as MangaDex or a directory on
+// disk.  Alternative sources only need to implement this interface to
+// be usable by the download pipeline.  Providers should stop work and
+// return an error once the given context is canceled.
+type Provider interface {
+	// Name is used in progress output and error messages, and is
+	// stored as the source of all chapters fetched by the provider.
+	Name() string
+	FetchSeries(ctx context.Context) (*md.Manga, error)
+	FetchChapters(ctx context.Context, manga md.Manga, p Progress) (md.ChapterList, error)
+	FetchCovers(ctx context.Context, manga md.Manga, p Progress) (md.ImageList, error)
+	FetchPages(ctx context.Context, cl md.ChapterList, p Progress) (md.ImageList, error)
+}
+
+// WithSource marks all chapters as fetched by the given provider.
+func WithSource(cl md.ChapterList, provider Provider) md.ChapterList {
+	for i := range cl {
+		cl[i].Info.Source = provider.Name()
+	}
+
+	return cl
+}
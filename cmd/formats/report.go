@@ -0,0 +1,68 @@
+package formats
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+
+	"github.com/fatih/color"
+)
+
+var (
+	reportMutex   sync.Mutex
+	reportEntries = make([]ReportEntry, 0)
+)
+
+// ReportEntry describes a problem that did not abort the run.
+type ReportEntry struct {
+	Category string
+	Message  string
+}
+
+// Report records a non-fatal problem, which is printed together with
+// all other problems once the run has finished.
+func Report(category, format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	writeLog(slog.LevelWarn, message, "category", category)
+
+	reportMutex.Lock()
+	defer reportMutex.Unlock()
+	reportEntries = append(reportEntries, ReportEntry{category, message})
+}
+
+func PrintReport() {
+	reportMutex.Lock()
+	defer reportMutex.Unlock()
+	if len(reportEntries) == 0 {
+		return
+	}
+
+	if eventsEnabled {
+		for _, entry := range reportEntries {
+			emit(Event{Phase: entry.Category, Event: "report", Message: entry.Message})
+		}
+		return
+	}
+	if !Enabled(LevelWarning) {
+		return
+	}
+
+	categories := make([]string, 0)
+	grouped := make(map[string][]string)
+	for _, entry := range reportEntries {
+		if _, ok := grouped[entry.Category]; !ok {
+			categories = append(categories, entry.Category)
+		}
+		grouped[entry.Category] = append(grouped[entry.Category], entry.Message)
+	}
+
+	warning := color.New(color.FgYellow, color.Bold)
+	fmt.Fprintf(os.Stderr, "%v: %v issues\n", warning.Sprint("Report"), len(reportEntries))
+	for _, category := range categories {
+		fmt.Fprintf(os.Stderr, "  %v:\n", color.New(color.Underline).Sprint(category))
+		for _, message := range grouped[category] {
+			fmt.Fprintf(os.Stderr, "    - %v\n", message)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package formats
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/fatih/color"
+)
+
+const (
+	VolumeNew     = "new"
+	VolumeRebuilt = "rebuilt"
+	VolumeSkipped = "skipped"
+	VolumeFailed  = "failed"
+)
+
+// VolumeResult describes the e-book that was built for a volume.
+type VolumeResult struct {
+	Filename string
+	Volume   string
+	Chapters int
+	Pages    int
+	Size     int64
+	Status   string
+	// Error describes why a failed volume could not be built.
+	Error string
+}
+
+var resultLine bool
+
+// EnableResultLine replaces the table of results by a single line of
+// key-value pairs, which is easy to parse in scripts.
+func EnableResultLine() {
+	resultLine = true
+}
+
+var statusColors = map[string]*color.Color{
+	VolumeNew:     color.New(color.FgGreen),
+	VolumeRebuilt: color.New(color.FgCyan),
+	VolumeSkipped: color.New(color.FgYellow),
+	VolumeFailed:  color.New(color.FgRed),
+}
+
+func PrintResults(identifier, directory string, results []VolumeResult) {
+	if resultLine {
+		printResultLine(identifier, directory, results)
+		return
+	} else if !Enabled(LevelInfo) || len(results) == 0 {
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "File\tVolume\tChapters\tPages\tSize\tStatus")
+	var pages int
+	var size int64
+	for _, result := range results {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\n",
+			result.Filename, result.Volume, result.Chapters, result.Pages,
+			formatSize(result.Size), statusColors[result.Status].Sprint(result.Status))
+		pages += result.Pages
+		size += result.Size
+	}
+	fmt.Fprintf(w, "Total\t\t\t%v\t%v\t\n", pages, formatSize(size))
+	w.Flush()
+}
+
+// PrintResultError prints the result line for a failed run.  The
+// identifier is omitted if empty.
+func PrintResultError(identifier string, err error) {
+	switch {
+	case !resultLine:
+	case identifier == "":
+		fmt.Printf("status=error error=%q\n", err.Error())
+	default:
+		fmt.Printf("status=error identifier=%q error=%q\n", identifier, err.Error())
+	}
+}
+
+func printResultLine(identifier, directory string, results []VolumeResult) {
+	counts := make(map[string]int)
+	var pages int
+	var size int64
+	for _, result := range results {
+		counts[result.Status]++
+		pages += result.Pages
+		size += result.Size
+	}
+
+	status := "ok"
+	if counts[VolumeFailed] > 0 {
+		status = "partial"
+	}
+
+	fmt.Printf("status=%v identifier=%q directory=%q new=%v rebuilt=%v skipped=%v failed=%v pages=%v bytes=%v\n",
+		status, identifier, directory, counts[VolumeNew], counts[VolumeRebuilt], counts[VolumeSkipped], counts[VolumeFailed], pages, size)
+}
+
+func formatSize(size int64) string {
+	switch {
+	case size >= 1e9:
+		return fmt.Sprintf("%.1f GB", float64(size)/1e9)
+	case size >= 1e6:
+		return fmt.Sprintf("%.1f MB", float64(size)/1e6)
+	case size >= 1e3:
+		return fmt.Sprintf("%.1f kB", float64(size)/1e3)
+	default:
+		return fmt.Sprintf("%v B", size)
+	}
+}
@@ -0,0 +1,52 @@
+package formats
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// Spool is a temporary file that writers stream a volume into while its
+// pages are added, as the output is only passed to Finish.  Pages are
+// therefore never kept in memory for the whole volume, regardless of
+// the order they are added in.
+type Spool struct {
+	file *os.File
+}
+
+func CreateSpool() (*Spool, error) {
+	f, err := os.CreateTemp("", "kojirou-*.spool")
+	if err != nil {
+		return nil, fmt.Errorf("spool: %w", err)
+	}
+
+	return &Spool{file: f}, nil
+}
+
+func (s *Spool) Write(data []byte) (int, error) {
+	return s.file.Write(data)
+}
+
+// CopyTo copies everything written to the spool to the output and
+// removes the spool.
+func (s *Spool) CopyTo(out io.Writer) error {
+	defer s.Remove()
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("spool: %w", err)
+	} else if _, err := io.Copy(out, s.file); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Remove closes and deletes the temporary file.  It may be called on a
+// spool that is nil or already removed.
+func (s *Spool) Remove() {
+	if s == nil || s.file == nil {
+		return
+	}
+	s.file.Close()
+	os.Remove(s.file.Name())
+	s.file = nil
+}
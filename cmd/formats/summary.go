@@ -1,8 +1,13 @@
 package formats
 
 import (
+	"encoding/json"
 	"fmt"
+	"log/slog"
+	"os"
 	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/fatih/color"
 	md "github.com/leotaku/kojirou/mangadex"
@@ -24,6 +29,10 @@ var groupColors = []*color.Color{
 }
 
 func PrintSummary(manga *md.Manga) {
+	if !Enabled(LevelInfo) {
+		return
+	}
+
 	sorted := manga.Chapters().SortBy(func(a md.ChapterInfo, b md.ChapterInfo) bool {
 		if a.VolumeIdentifier.Equal(b.VolumeIdentifier) {
 			return a.Identifier.Less(b.Identifier)
@@ -34,15 +43,88 @@ func PrintSummary(manga *md.Manga) {
 	groups, numbers := formatChapterMapping(sorted)
 	discontinuities := formatDiscontinuities(sorted)
 
-	printValue("Title", manga.Info.Title)
-	printValue("Author", manga.Info.Authors)
+	PrintValue("Title", manga.Info.Title)
+	PrintValue("Author", manga.Info.Authors)
+	if manga.Info.Publisher != "" {
+		PrintValue("Publisher", manga.Info.Publisher)
+	}
 	if len(numbers) > 0 {
-		printValue("Groups", strings.Join(groups, ", "))
-		printValue("Chapters", strings.Join(numbers, ", "))
+		PrintValue("Groups", strings.Join(groups, ", "))
+		PrintValue("Chapters", strings.Join(numbers, ", "))
 	}
 	if len(discontinuities) > 0 {
-		printValue("Discontinuities", strings.Join(discontinuities, ", "))
+		PrintValue("Discontinuities", strings.Join(discontinuities, ", "))
+	}
+}
+
+type Summary struct {
+	Title     string
+	Authors   []string
+	Artists   []string
+	Publisher string `json:",omitempty"`
+	Chapters  []ChapterSummary
+}
+
+type ChapterSummary struct {
+	ID        string `json:",omitempty"`
+	Volume    string
+	Chapter   string
+	Title     string
+	Language  string
+	Groups    []string
+	Pages     int
+	Published time.Time
+}
+
+func Summarize(manga *md.Manga) Summary {
+	return Summary{
+		Title:     manga.Info.Title,
+		Authors:   manga.Info.Authors,
+		Artists:   manga.Info.Artists,
+		Publisher: manga.Info.Publisher,
+		Chapters:  SummarizeChapters(manga.Chapters()),
+	}
+}
+
+func SummarizeChapters(cl md.ChapterList) []ChapterSummary {
+	sorted := cl.SortBy(func(a md.ChapterInfo, b md.ChapterInfo) bool {
+		if a.VolumeIdentifier.Equal(b.VolumeIdentifier) {
+			return a.Identifier.Less(b.Identifier)
+		} else {
+			return a.VolumeIdentifier.Less(b.VolumeIdentifier)
+		}
+	})
+
+	chapters := make([]ChapterSummary, 0)
+	for _, chapter := range sorted {
+		chapters = append(chapters, ChapterSummary{
+			ID:        chapter.Info.ID,
+			Volume:    chapter.Info.VolumeIdentifier.String(),
+			Chapter:   chapter.Info.Identifier.String(),
+			Title:     chapter.Info.Title,
+			Language:  chapter.Info.Language.String(),
+			Groups:    chapter.Info.GroupNames,
+			Pages:     chapter.Info.Pages,
+			Published: chapter.Info.Published,
+		})
+	}
+
+	return chapters
+}
+
+func PrintChapters(chapters []ChapterSummary) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Volume\tChapter\tTitle\tLanguage\tGroups\tPages\tUploaded")
+	for _, chapter := range chapters {
+		published := ""
+		if !chapter.Published.IsZero() {
+			published = chapter.Published.Format("2006-01-02")
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%v\n",
+			chapter.Volume, chapter.Chapter, chapter.Title, chapter.Language,
+			strings.Join(chapter.Groups, ", "), chapter.Pages, published)
 	}
+	w.Flush()
 }
 
 func formatChapterMapping(chapters md.ChapterList) (groups, numbers []string) {
@@ -89,7 +171,24 @@ func formatDiscontinuities(chapters md.ChapterList) (discontinuities []string) {
 	return discontinuities
 }
 
-func printValue(name, value interface{}) {
+func PrintWarning(format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	writeLog(slog.LevelWarn, message)
+	if !Enabled(LevelWarning) {
+		return
+	}
+	warning := color.New(color.FgYellow, color.Bold)
+	fmt.Fprintf(os.Stderr, "%v: %v\n", warning.Sprint("Warning"), message)
+}
+
+func PrintJSON(value interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(value)
+}
+
+func PrintValue(name, value interface{}) {
 	underlined := color.New(color.Underline)
 	fmt.Printf("%v: %v\n", underlined.Sprint(name), value)
 }
@@ -0,0 +1,44 @@
+package formats
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	md "github.com/leotaku/kojirou/mangadex"
+)
+
+// Timestamp returns the time used for the metadata and modification
+// time of a volume containing the given chapters, which is the latest
+// publish date of the chapters.  Output therefore only changes when its
+// content changes, which keeps repeated runs byte-identical.  The
+// SOURCE_DATE_EPOCH environment variable overrides the publish dates.
+func Timestamp(chapters md.ChapterList) time.Time {
+	if epoch, err := strconv.ParseInt(os.Getenv("SOURCE_DATE_EPOCH"), 10, 64); err == nil {
+		return time.Unix(epoch, 0).UTC()
+	}
+
+	result := time.Unix(0, 0).UTC()
+	for _, chapter := range chapters {
+		if chapter.Info.Published.After(result) {
+			result = chapter.Info.Published.UTC()
+		}
+	}
+
+	return result
+}
+
+// zipEpoch is the earliest time that can be stored in zip archives,
+// which use MS-DOS dates.
+var zipEpoch = time.Date(1980, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// ZipTimestamp returns the same time as Timestamp, but no earlier than
+// the start of 1980, for formats that are zip archives.  Earlier times
+// are stored as invalid dates, which some readers reject.
+func ZipTimestamp(chapters md.ChapterList) time.Time {
+	if result := Timestamp(chapters); result.After(zipEpoch) {
+		return result
+	}
+
+	return zipEpoch
+}
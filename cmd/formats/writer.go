@@ -0,0 +1,53 @@
+package formats
+
+import (
+	"io"
+
+	md "github.com/leotaku/kojirou/mangadex"
+)
+
+// FormatWriter assembles a single volume into an output file, such as
+// an e-book or comic archive.  Writers first receive the metadata of
+// the volume, followed by every chapter and its pages in reading order.
+// Pages are numbered from zero within their chapter.
+//
+// Writers that support it write every page as soon as it is added, see
+// Spool, so that they do not keep the pages of the volume in memory.
+// Such writers restore the reading order from the page numbers, so
+// pages may also be added out of order.
+type FormatWriter interface {
+	// Extension is the file extension of the output, e.g. ".azw3".
+	Extension() string
+	// Begin receives a manga that only contains the written volume,
+	// including its cover and the metadata of its chapters.
+	Begin(manga md.Manga, volume md.Identifier) error
+	AddChapter(chapter md.ChapterInfo) error
+	AddPage(page md.Image) error
+	Finish(w io.Writer) error
+}
+
+// Aborter is implemented by writers that keep temporary files from
+// Begin until Finish, which Abort removes if the volume is not
+// finished.
+type Aborter interface {
+	Abort()
+}
+
+// Abort releases the temporary files of the writer, if any.
+func Abort(w FormatWriter) {
+	if a, ok := w.(Aborter); ok {
+		a.Abort()
+	}
+}
+
+// ChapterOrder returns the position of every chapter of the volume in
+// reading order, which streaming writers use to sort the pages they
+// have written once the volume is finished.
+func ChapterOrder(volume md.Volume) map[md.Identifier]int {
+	result := make(map[md.Identifier]int)
+	for i, chapter := range volume.Sorted() {
+		result[chapter.Info.Identifier] = i
+	}
+
+	return result
+}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/leotaku/kojirou/cmd/filter"
+	"github.com/leotaku/kojirou/cmd/formats"
+	md "github.com/leotaku/kojirou/mangadex"
+	"golang.org/x/text/language"
+)
+
+var fallbackLanguageArg string
+
+// chapterLanguages returns the languages of --language, from the most
+// to the least preferred.
+func chapterLanguages() []language.Tag {
+	return parseLanguages(languageArg)
+}
+
+// primaryLanguage returns the most preferred language of --language,
+// which is assumed for chapters loaded from disk.
+func primaryLanguage() language.Tag {
+	if langs := chapterLanguages(); len(langs) > 0 {
+		return langs[0]
+	}
+
+	return language.Und
+}
+
+func fallbackLanguages() []language.Tag {
+	return parseLanguages(fallbackLanguageArg)
+}
+
+// parseLanguages parses a comma-separated list of languages, ignoring
+// empty entries.
+func parseLanguages(s string) []language.Tag {
+	langs := make([]language.Tag, 0)
+	for _, lang := range strings.Split(s, ",") {
+		if lang = strings.TrimSpace(lang); lang != "" {
+			langs = append(langs, language.Make(lang))
+		}
+	}
+
+	return langs
+}
+
+func validateLanguages(flag, s string) error {
+	for _, lang := range strings.Split(s, ",") {
+		if lang = strings.TrimSpace(lang); lang == "" {
+			continue
+		}
+		if _, err := language.Parse(lang); err != nil {
+			return fmt.Errorf("%v: %w", flag, err)
+		}
+	}
+
+	return nil
+}
+
+// reportGaps reports the gaps that fall between the first and the last
+// selected chapter.  Gaps outside of the selection are expected when
+// only some chapters are downloaded.
+func reportGaps(selected md.ChapterList, gaps []filter.Gap) {
+	ids := make([]md.Identifier, 0)
+	for _, chapter := range selected {
+		if id := chapter.Info.Identifier; !id.IsSpecial() {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i].Less(ids[j])
+	})
+
+	missing := make([]string, 0)
+	first, last := ids[0], ids[len(ids)-1]
+	for _, gap := range gaps {
+		if first.Whole() < gap.From && gap.To <= last.Whole() {
+			missing = append(missing, gap.String())
+		}
+	}
+	if len(missing) == 0 {
+		return
+	}
+	message := fmt.Sprintf("chapters %v are missing", strings.Join(missing, ", "))
+	if fallbackLanguageArg == "" {
+		message += ", use --fallback-language to fill them from other languages"
+	}
+	formats.Report("Chapters", "%v", message)
+}
+
+// reportFallback reports which chapters are filled from fallback
+// languages, so that they do not come as a surprise.
+func reportFallback(fallback md.ChapterList) {
+	langs := make([]language.Tag, 0)
+	grouped := make(map[language.Tag]map[md.Identifier]bool)
+	for _, chapter := range fallback {
+		lang := chapter.Info.Language
+		if _, ok := grouped[lang]; !ok {
+			langs = append(langs, lang)
+			grouped[lang] = make(map[md.Identifier]bool)
+		}
+		grouped[lang][chapter.Info.Identifier] = true
+	}
+
+	for _, lang := range langs {
+		ids := make([]md.Identifier, 0)
+		for id := range grouped[lang] {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool {
+			return ids[i].Less(ids[j])
+		})
+		names := make([]string, 0)
+		for _, id := range ids {
+			names = append(names, id.String())
+		}
+		formats.Report("Chapters", "using %v for missing chapters %v", lang, strings.Join(names, ", "))
+	}
+}
@@ -13,14 +13,26 @@ import (
 	"golang.org/x/text/language"
 )
 
+const filterAnnotation = "kojirou_filter"
+
+func markFilters(flags *pflag.FlagSet, names ...string) {
+	for _, name := range names {
+		flags.SetAnnotation(name, filterAnnotation, []string{"true"}) //nolint:errcheck
+	}
+}
+
 func writeHelp(cmd *cobra.Command, w io.Writer) {
 	groups := make(map[string][]pflag.Flag)
+	width := 20
 	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		if !f.Hidden && len(f.Name)+2 > width {
+			width = len(f.Name) + 2
+		}
 		switch {
 		case f.Hidden:
 		case strings.HasPrefix(f.Name, "help") || f.Name == "version":
 			groups["3Flags"] = append(groups["3Flags"], *f)
-		case strings.HasSuffix(f.Name, "s"):
+		case len(f.Annotations[filterAnnotation]) > 0:
 			groups["2Filters"] = append(groups["2Filters"], *f)
 		default:
 			groups["1Options"] = append(groups["1Options"], *f)
@@ -47,7 +59,16 @@ func writeHelp(cmd *cobra.Command, w io.Writer) {
 			if len(f.Shorthand) > 0 {
 				shorthand = "-" + f.Shorthand + ", "
 			}
-			fmt.Fprintf(w, "  %4v--%-20v%v\n", shorthand, f.Name, toSentenceCase(f.Usage))
+			fmt.Fprintf(w, "  %4v--%-*v%v\n", shorthand, width, f.Name, toSentenceCase(f.Usage))
+		}
+	}
+
+	if cmd.HasAvailableSubCommands() {
+		fmt.Fprintf(w, "\nCommands:\n")
+		for _, sub := range cmd.Commands() {
+			if sub.IsAvailableCommand() {
+				fmt.Fprintf(w, "  %-*v%v\n", width+6, sub.Name(), sub.Short)
+			}
 		}
 	}
 }
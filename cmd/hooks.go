@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/leotaku/kojirou/cmd/formats"
+	"github.com/leotaku/kojirou/cmd/hooks"
+	md "github.com/leotaku/kojirou/mangadex"
+	"github.com/spf13/pflag"
+)
+
+// Hooks are run in addition to the commands given on the command
+// line.  Programs that embed Kojirou may register their own steps
+// here before calling Execute.
+var Hooks hooks.Hooks
+
+var (
+	preVolumeHookArg   string
+	postVolumeHookArg  string
+	preChapterHookArg  string
+	postChapterHookArg string
+	preImageHookArg    string
+	postImageHookArg   string
+)
+
+func addHookFlags(flags *pflag.FlagSet) {
+	flags.StringVarP(&preVolumeHookArg, "pre-volume-hook", "", "", "run this command before processing a volume")
+	flags.StringVarP(&postVolumeHookArg, "post-volume-hook", "", "", "run this command with the files of a written volume")
+	flags.StringVarP(&preChapterHookArg, "pre-chapter-hook", "", "", "run this command before processing a chapter")
+	flags.StringVarP(&postChapterHookArg, "post-chapter-hook", "", "", "run this command after processing a chapter")
+	flags.StringVarP(&preImageHookArg, "pre-image-hook", "", "", "run this command on every page before cropping")
+	flags.StringVarP(&postImageHookArg, "post-image-hook", "", "", "run this command on every page after cropping")
+}
+
+func activeHooks() hooks.Hooks {
+	commands := hooks.Hooks{}
+	if preVolumeHookArg != "" {
+		commands.PreVolume = append(commands.PreVolume, hooks.VolumeCommand(preVolumeHookArg))
+	}
+	if postVolumeHookArg != "" {
+		commands.PostVolume = append(commands.PostVolume, hooks.VolumeCommand(postVolumeHookArg))
+	}
+	if preChapterHookArg != "" {
+		commands.PreChapter = append(commands.PreChapter, hooks.ChapterCommand(preChapterHookArg))
+	}
+	if postChapterHookArg != "" {
+		commands.PostChapter = append(commands.PostChapter, hooks.ChapterCommand(postChapterHookArg))
+	}
+	if preImageHookArg != "" {
+		commands.PreImage = append(commands.PreImage, hooks.ImageCommand(preImageHookArg))
+	}
+	if postImageHookArg != "" {
+		commands.PostImage = append(commands.PostImage, hooks.ImageCommand(postImageHookArg))
+	}
+
+	return Hooks.Merge(commands).Merge(pluginHooks())
+}
+
+// runPageHooks runs the chapter and image hooks for every chapter of
+// the volume.  Chapter hooks run before image hooks in the pre stage
+// and after them in the post stage.
+func runPageHooks(ctx context.Context, t hooks.Target, volume md.Volume, pages md.ImageList, chapterFns []hooks.ChapterFunc, imageFns []hooks.ImageFunc, post bool) error {
+	if len(chapterFns) == 0 && len(imageFns) == 0 {
+		return nil
+	}
+
+	p := formats.VanishingProgress("Hooks..")
+	p.Increase(len(pages))
+	for _, chapter := range volume.Sorted() {
+		indices := make([]int, 0)
+		for i, page := range pages {
+			if page.ChapterIdentifier == chapter.Info.Identifier {
+				indices = append(indices, i)
+			}
+		}
+		chapterPages := func() md.ImageList {
+			result := make(md.ImageList, 0)
+			for _, i := range indices {
+				result = append(result, pages[i])
+			}
+			return result
+		}
+
+		if !post {
+			if err := hooks.RunChapter(ctx, chapterFns, t, chapter.Info, chapterPages()); err != nil {
+				p.Cancel("Error")
+				return fmt.Errorf("chapter %v: %w", chapter.Info.Identifier, err)
+			}
+		}
+		for _, i := range indices {
+			if err := hooks.RunImage(ctx, imageFns, t, &pages[i]); err != nil {
+				p.Cancel("Error")
+				return fmt.Errorf("chapter %v: page %v: %w", chapter.Info.Identifier, pages[i].ImageIdentifier, err)
+			}
+			p.Add(1)
+		}
+		if post {
+			if err := hooks.RunChapter(ctx, chapterFns, t, chapter.Info, chapterPages()); err != nil {
+				p.Cancel("Error")
+				return fmt.Errorf("chapter %v: %w", chapter.Info.Identifier, err)
+			}
+		}
+	}
+	p.Done()
+
+	return nil
+}
@@ -0,0 +1,180 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/leotaku/kojirou/codec"
+	md "github.com/leotaku/kojirou/mangadex"
+)
+
+// VolumeCommand runs a shell command with the written files as its
+// positional arguments.
+func VolumeCommand(command string) VolumeFunc {
+	return func(ctx context.Context, t Target, files []string) error {
+		return runCommand(ctx, command, environment(t), files...)
+	}
+}
+
+// ChapterCommand runs a shell command for every chapter.  The chapter
+// is described by the environment of the command.
+func ChapterCommand(command string) ChapterFunc {
+	return func(ctx context.Context, t Target, chapter md.ChapterInfo, pages md.ImageList) error {
+		env := append(environment(t),
+			"KOJIROU_HOOK_CHAPTER="+chapter.Identifier.String(),
+			"KOJIROU_HOOK_CHAPTER_TITLE="+chapter.Title,
+			"KOJIROU_HOOK_PAGES="+strconv.Itoa(len(pages)),
+		)
+		return runCommand(ctx, command, env)
+	}
+}
+
+// ImageCommand runs a shell command for every page.  The page is
+// passed as the path to a PNG file, which the command may modify in
+// place to replace the page.
+func ImageCommand(command string) ImageFunc {
+	return func(ctx context.Context, t Target, img *md.Image) error {
+		f, err := os.CreateTemp("", "kojirou-*.png")
+		if err != nil {
+			return err
+		}
+		filename := f.Name()
+		defer os.Remove(filename)
+		if err := f.Close(); err != nil {
+			return err
+		}
+
+		decoded, err := codec.Decoded(img.Image)
+		if err != nil {
+			return fmt.Errorf("decode: %w", err)
+		}
+		if err := writePNG(filename, decoded); err != nil {
+			return fmt.Errorf("encode: %w", err)
+		}
+		env := append(environment(t),
+			"KOJIROU_HOOK_CHAPTER="+img.ChapterIdentifier.String(),
+			"KOJIROU_HOOK_PAGE="+strconv.Itoa(img.ImageIdentifier),
+		)
+		if err := runCommand(ctx, command, env, filename); err != nil {
+			return err
+		}
+
+		replaced, err := readImage(filename)
+		if err != nil {
+			return fmt.Errorf("decode: %w", err)
+		}
+		img.Image = replaced
+
+		return nil
+	}
+}
+
+// UpscaleCommand runs a shell command, such as waifu2x or realcugan,
+// for every page that is smaller than the target size.  The page is
+// passed as the path to a PNG file and the command writes the upscaled
+// page to the path passed as the second argument.  The smallest whole
+// factor that reaches the target size is passed in KOJIROU_SCALE.
+func UpscaleCommand(command string, target image.Point) ImageFunc {
+	return func(ctx context.Context, t Target, img *md.Image) error {
+		size := img.Image.Bounds().Size()
+		factor := math.Min(float64(target.X)/float64(size.X), float64(target.Y)/float64(size.Y))
+		if factor <= 1 {
+			return nil
+		}
+
+		dir, err := os.MkdirTemp("", "kojirou-*")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+		input, output := filepath.Join(dir, "input.png"), filepath.Join(dir, "output.png")
+
+		decoded, err := codec.Decoded(img.Image)
+		if err != nil {
+			return fmt.Errorf("decode: %w", err)
+		}
+		if err := writePNG(input, decoded); err != nil {
+			return fmt.Errorf("encode: %w", err)
+		}
+		env := append(environment(t),
+			"KOJIROU_HOOK_CHAPTER="+img.ChapterIdentifier.String(),
+			"KOJIROU_HOOK_PAGE="+strconv.Itoa(img.ImageIdentifier),
+			"KOJIROU_SCALE="+strconv.Itoa(int(math.Ceil(factor))),
+		)
+		if err := runCommand(ctx, command, env, input, output); err != nil {
+			return err
+		}
+
+		upscaled, err := readImage(output)
+		if err != nil {
+			return fmt.Errorf("decode: %w", err)
+		}
+		img.Image = upscaled
+
+		return nil
+	}
+}
+
+func writePNG(filename string, img image.Image) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+func readImage(filename string) (image.Image, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := codec.Decode(f)
+
+	return img, err
+}
+
+func environment(t Target) []string {
+	return append(os.Environ(),
+		"KOJIROU_HOOK_TITLE="+t.Manga.Title,
+		"KOJIROU_HOOK_MANGA="+t.Manga.ID,
+		"KOJIROU_HOOK_VOLUME="+t.Volume.String(),
+		"KOJIROU_HOOK_DIRECTORY="+t.Directory,
+	)
+}
+
+func runCommand(ctx context.Context, command string, env []string, args ...string) error {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", append([]string{"/C", command}, args...)...)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", append([]string{"-c", command, "kojirou"}, args...)...)
+	}
+	cmd.Env = env
+
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Run(); err != nil && output.Len() > 0 {
+		return fmt.Errorf("%v: %w: %v", command, err, strings.TrimSpace(output.String()))
+	} else if err != nil {
+		return fmt.Errorf("%v: %w", command, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,80 @@
+package hooks
+
+import (
+	"context"
+
+	md "github.com/leotaku/kojirou/mangadex"
+)
+
+// Target describes the volume that is being built when a hook runs.
+type Target struct {
+	Manga     md.MangaInfo
+	Volume    md.Identifier
+	Directory string
+}
+
+// VolumeFunc receives the files written for a volume, which is empty
+// before the volume has been written.
+type VolumeFunc func(ctx context.Context, t Target, files []string) error
+
+// ChapterFunc receives a chapter and its pages in reading order.
+type ChapterFunc func(ctx context.Context, t Target, chapter md.ChapterInfo, pages md.ImageList) error
+
+// ImageFunc may modify or replace the given page.
+type ImageFunc func(ctx context.Context, t Target, image *md.Image) error
+
+// Hooks are custom steps that run while building a volume.  Pre hooks
+// run before pages are cropped, post hooks run after pages have been
+// cropped and, for volumes, after all output files have been written.
+// A hook that returns an error aborts the volume.
+type Hooks struct {
+	PreVolume   []VolumeFunc
+	PostVolume  []VolumeFunc
+	PreChapter  []ChapterFunc
+	PostChapter []ChapterFunc
+	PreImage    []ImageFunc
+	PostImage   []ImageFunc
+}
+
+// Merge returns hooks that run the hooks of h followed by those of
+// other.
+func (h Hooks) Merge(other Hooks) Hooks {
+	return Hooks{
+		PreVolume:   append(append([]VolumeFunc{}, h.PreVolume...), other.PreVolume...),
+		PostVolume:  append(append([]VolumeFunc{}, h.PostVolume...), other.PostVolume...),
+		PreChapter:  append(append([]ChapterFunc{}, h.PreChapter...), other.PreChapter...),
+		PostChapter: append(append([]ChapterFunc{}, h.PostChapter...), other.PostChapter...),
+		PreImage:    append(append([]ImageFunc{}, h.PreImage...), other.PreImage...),
+		PostImage:   append(append([]ImageFunc{}, h.PostImage...), other.PostImage...),
+	}
+}
+
+func RunVolume(ctx context.Context, fns []VolumeFunc, t Target, files []string) error {
+	for _, fn := range fns {
+		if err := fn(ctx, t, files); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func RunChapter(ctx context.Context, fns []ChapterFunc, t Target, chapter md.ChapterInfo, pages md.ImageList) error {
+	for _, fn := range fns {
+		if err := fn(ctx, t, chapter, pages); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func RunImage(ctx context.Context, fns []ImageFunc, t Target, image *md.Image) error {
+	for _, fn := range fns {
+		if err := fn(ctx, t, image); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
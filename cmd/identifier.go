@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/leotaku/kojirou/cmd/tracker"
+)
+
+var (
+	mangadexURLPattern = regexp.MustCompile(`^https?://(?:www\.)?mangadex\.org/title/([0-9a-f-]{36})`)
+	mangadexIDPattern  = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+)
+
+func resolveIdentifier(ctx context.Context, identifier string) (string, error) {
+	if m := mangadexURLPattern.FindStringSubmatch(identifier); m != nil {
+		return m[1], nil
+	}
+
+	if name, id, ok := tracker.Parse(identifier); ok {
+		t, err := tracker.Lookup(ctx, name)
+		if err != nil {
+			return "", fmt.Errorf("%v: %w", name, err)
+		}
+		titles, err := t.Titles(ctx, id)
+		if err != nil {
+			return "", fmt.Errorf("%v: %w", name, err)
+		}
+		mangaID, err := getDownloader().MangadexLinked(ctx, t.Site(), id, titles...)
+		if err != nil {
+			return "", fmt.Errorf("mangadex: %w", err)
+		}
+
+		return mangaID, nil
+	}
+
+	return identifier, nil
+}
+
+// mangadexID returns the MangaDex ID of identifiers that can be resolved
+// without making any requests.
+func mangadexID(identifier string) (string, bool) {
+	if m := mangadexURLPattern.FindStringSubmatch(identifier); m != nil {
+		return m[1], true
+	}
+
+	return identifier, mangadexIDPattern.MatchString(identifier)
+}
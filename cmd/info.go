@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/leotaku/kojirou/cmd/formats"
+	"github.com/spf13/cobra"
+)
+
+var infoCmd = &cobra.Command{
+	Use:   "info [flags..] <identifier>",
+	Short: "Print metadata and available languages of a manga",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return info(cmd.Context(), args[0])
+	},
+}
+
+func info(ctx context.Context, identifier string) error {
+	mangaID, err := resolveIdentifier(ctx, identifier)
+	if err != nil {
+		return fmt.Errorf("identifier: %w", err)
+	}
+
+	p := formats.VanishingProgress("Metadata")
+	manga, err := getDownloader().MangadexSkeleton(ctx, mangaID)
+	if err != nil {
+		p.Cancel("Error")
+		return fmt.Errorf("skeleton: %w", err)
+	}
+	chapters, err := getDownloader().MangadexChapters(ctx, mangaID)
+	if err != nil {
+		p.Cancel("Error")
+		return fmt.Errorf("chapters: %w", err)
+	}
+	p.Done()
+
+	if jsonArg {
+		return formats.PrintJSON(formats.Describe(manga, chapters))
+	}
+	formats.PrintInfo(formats.Describe(manga, chapters))
+
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(infoCmd)
+}
@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+	md "github.com/leotaku/kojirou/mangadex"
+)
+
+var interactiveArg bool
+
+var errSelectionAborted = errors.New("selection aborted")
+
+type selection struct {
+	volumes  []md.Volume
+	chapters [][]md.Chapter
+	selected [][]bool
+}
+
+func newSelection(manga md.Manga) *selection {
+	s := &selection{volumes: manga.Sorted()}
+	for _, volume := range s.volumes {
+		chapters := make([]md.Chapter, 0)
+		selected := make([]bool, 0)
+		for _, chapter := range volume.Sorted() {
+			chapters = append(chapters, chapter)
+			selected = append(selected, true)
+		}
+		s.chapters = append(s.chapters, chapters)
+		s.selected = append(s.selected, selected)
+	}
+
+	return s
+}
+
+// selectChapters lets the user toggle volumes and chapters before any
+// pages are downloaded.  Volumes are addressed by their position in the
+// list and chapters by the position of the volume and chapter, e.g. 2.3.
+func selectChapters(manga md.Manga, in io.Reader, out io.Writer) (md.ChapterList, error) {
+	s := newSelection(manga)
+	r := bufio.NewReader(in)
+	for {
+		s.print(out)
+		fmt.Fprint(out, "Toggle (e.g. 1,3-4,2.1), [a]ll, [n]one, [q]uit or Enter to continue: ")
+		line, err := r.ReadString('\n')
+		if err != nil && !(errors.Is(err, io.EOF) && line != "") {
+			return nil, fmt.Errorf("read: %w", err)
+		}
+
+		switch line = strings.TrimSpace(line); line {
+		case "":
+			return s.result(), nil
+		case "q":
+			return nil, errSelectionAborted
+		case "a", "n":
+			s.setAll(line == "a")
+		default:
+			if err := s.toggle(line); err != nil {
+				fmt.Fprintf(out, "%v: %v\n", color.New(color.FgRed).Sprint("Error"), err)
+			}
+		}
+	}
+}
+
+func (s *selection) print(out io.Writer) {
+	for i, volume := range s.volumes {
+		count := 0
+		for _, ok := range s.selected[i] {
+			if ok {
+				count++
+			}
+		}
+		fmt.Fprintf(out, "%v %-5v Volume %v (%v/%v chapters)\n",
+			checkbox(count > 0), i+1, volume.Info.Identifier, count, len(s.chapters[i]))
+		for j, chapter := range s.chapters[i] {
+			fmt.Fprintf(out, "    %v %-5v Chapter %v: %v [%v] %v\n",
+				checkbox(s.selected[i][j]), fmt.Sprintf("%v.%v", i+1, j+1),
+				chapter.Info.Identifier, chapter.Info.Title, chapter.Info.Language, chapter.Info.GroupNames)
+		}
+	}
+}
+
+func (s *selection) toggle(line string) error {
+	for _, token := range strings.FieldsFunc(line, func(r rune) bool { return r == ',' || r == ' ' }) {
+		if split := strings.SplitN(token, ".", 2); len(split) == 2 {
+			i, err := s.index(split[0], len(s.volumes))
+			if err != nil {
+				return err
+			}
+			j, err := s.index(split[1], len(s.chapters[i]))
+			if err != nil {
+				return err
+			}
+			s.selected[i][j] = !s.selected[i][j]
+			continue
+		}
+
+		split := strings.SplitN(token, "-", 2)
+		from, err := s.index(split[0], len(s.volumes))
+		if err != nil {
+			return err
+		}
+		to := from
+		if len(split) == 2 {
+			if to, err = s.index(split[1], len(s.volumes)); err != nil {
+				return err
+			}
+		}
+		for i := from; i <= to; i++ {
+			enable := true
+			for _, ok := range s.selected[i] {
+				enable = enable && !ok
+			}
+			for j := range s.selected[i] {
+				s.selected[i][j] = enable
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *selection) index(token string, length int) (int, error) {
+	n, err := strconv.Atoi(token)
+	if err != nil || n < 1 || n > length {
+		return 0, fmt.Errorf("not a valid entry: %v", token)
+	}
+
+	return n - 1, nil
+}
+
+func (s *selection) setAll(value bool) {
+	for i := range s.selected {
+		for j := range s.selected[i] {
+			s.selected[i][j] = value
+		}
+	}
+}
+
+func (s *selection) result() md.ChapterList {
+	result := make(md.ChapterList, 0)
+	for i := range s.chapters {
+		for j, chapter := range s.chapters[i] {
+			if s.selected[i][j] {
+				result = append(result, chapter)
+			}
+		}
+	}
+
+	return result
+}
+
+func checkbox(checked bool) string {
+	if checked {
+		return color.New(color.FgGreen).Sprint("[x]")
+	}
+
+	return "[ ]"
+}
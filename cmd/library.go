@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/leotaku/kojirou/cmd/formats"
+	"github.com/leotaku/kojirou/cmd/formats/kindle"
+	"github.com/spf13/cobra"
+)
+
+const libraryVersion = 1
+
+var (
+	libraryOutArg   string
+	libraryForceArg bool
+)
+
+type library struct {
+	Version int
+	Series  []librarySeries
+}
+
+type librarySeries struct {
+	Directory string
+	Manifest  manifest
+	Checksums map[string]string
+}
+
+var libraryCmd = &cobra.Command{
+	Use:   "library",
+	Short: "Back up or migrate the state of a library",
+}
+
+var libraryExportCmd = &cobra.Command{
+	Use:   "export [flags..] <directory>",
+	Short: "Export manifests and checksums of a library to JSON",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return libraryExport(args[0])
+	},
+}
+
+var libraryImportCmd = &cobra.Command{
+	Use:   "import [flags..] <file> <directory>",
+	Short: "Import a previously exported library into a directory",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return libraryImport(args[0], args[1])
+	},
+}
+
+func libraryExport(root string) error {
+	lib := library{Version: libraryVersion, Series: make([]librarySeries, 0)}
+	err := filepath.WalkDir(root, func(pathname string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || d.Name() != manifestFilename {
+			return err
+		}
+
+		directory := filepath.Dir(pathname)
+		m, err := readManifest(filepath.ToSlash(directory))
+		if err != nil {
+			return fmt.Errorf("manifest '%v': %w", pathname, err)
+		}
+		sums, err := kindle.ReadChecksums(filepath.ToSlash(directory))
+		if err != nil {
+			return fmt.Errorf("checksums '%v': %w", directory, err)
+		}
+		relative, err := filepath.Rel(root, directory)
+		if err != nil {
+			return err
+		}
+		lib.Series = append(lib.Series, librarySeries{
+			Directory: filepath.ToSlash(relative),
+			Manifest:  *m,
+			Checksums: sums,
+		})
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("walk: %w", err)
+	}
+
+	data, err := json.MarshalIndent(lib, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode: %w", err)
+	}
+	if libraryOutArg == "" {
+		_, err = fmt.Println(string(data))
+		return err
+	}
+
+	return os.WriteFile(libraryOutArg, data, 0o644)
+}
+
+func libraryImport(filename, root string) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf("read: %w", err)
+	}
+	lib := library{}
+	if err := json.Unmarshal(data, &lib); err != nil {
+		return fmt.Errorf("decode: %w", err)
+	} else if lib.Version != libraryVersion {
+		return fmt.Errorf("decode: unsupported version %v", lib.Version)
+	}
+
+	for _, series := range lib.Series {
+		directory := filepath.Join(root, filepath.FromSlash(series.Directory))
+		if _, err := os.Stat(filepath.Join(directory, manifestFilename)); err == nil && !libraryForceArg {
+			formats.Report("Skipped", "%v already exists, use --force to overwrite", series.Directory)
+			continue
+		}
+		if err := importSeries(filepath.ToSlash(directory), series); err != nil {
+			return fmt.Errorf("%v: %w", series.Directory, err)
+		}
+	}
+
+	return nil
+}
+
+func importSeries(directory string, series librarySeries) error {
+	if err := os.MkdirAll(directory, os.ModePerm); err != nil {
+		return fmt.Errorf("directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(series.Manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(directory, manifestFilename), data, 0o644); err != nil {
+		return fmt.Errorf("manifest: %w", err)
+	}
+	if len(series.Checksums) > 0 {
+		if err := kindle.WriteChecksums(directory, series.Checksums); err != nil {
+			return fmt.Errorf("checksums: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func init() {
+	libraryExportCmd.Flags().StringVarP(&libraryOutArg, "out", "o", "", "write export to this file instead of stdout")
+	libraryImportCmd.Flags().BoolVarP(&libraryForceArg, "force", "f", false, "overwrite existing manifests")
+	libraryCmd.AddCommand(libraryExportCmd)
+	libraryCmd.AddCommand(libraryImportCmd)
+	rootCmd.AddCommand(libraryCmd)
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/leotaku/kojirou/cmd/formats"
+)
+
+const lockFilename = ".kojirou.lock"
+
+var waitArg bool
+
+// lockDirectory prevents concurrent runs from writing to the same
+// directory.  If waiting is enabled, it blocks until the lock held by
+// a different run has been released.  Locks left behind by runs that
+// no longer exist on this host are taken over.
+func lockDirectory(directory string) (func(), error) {
+	if err := os.MkdirAll(directory, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("directory: %w", err)
+	}
+
+	host, _ := os.Hostname()
+	pathname := filepath.Join(directory, lockFilename)
+	warned := false
+	for {
+		f, err := os.OpenFile(pathname, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		switch {
+		case err == nil:
+			fmt.Fprintf(f, "%v %v\n", os.Getpid(), host)
+			f.Close()
+			return func() { os.Remove(pathname) }, nil
+		case !errors.Is(err, fs.ErrExist):
+			return nil, err
+		case staleLock(pathname, host):
+			formats.PrintWarning("Taking over '%v' from a run that no longer exists", pathname)
+			if err := os.Remove(pathname); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return nil, err
+			}
+			continue
+		case !waitArg:
+			return nil, fmt.Errorf("'%v' is locked by another run, remove '%v' if this is not the case", directory, pathname)
+		case !warned:
+			formats.PrintWarning("Waiting for another run to finish in '%v'", directory)
+			warned = true
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// staleLock reports whether the lock was taken by a process on this host
+// that no longer exists.  Locks taken on other hosts are never stale, as
+// their processes cannot be checked.
+func staleLock(pathname, host string) bool {
+	data, err := os.ReadFile(pathname)
+	if err != nil {
+		return false
+	}
+	pid, owner := 0, ""
+	if n, _ := fmt.Sscan(string(data), &pid, &owner); n < 2 || owner != host || pid <= 0 {
+		return false
+	}
+
+	return !processExists(pid)
+}
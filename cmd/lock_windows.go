@@ -0,0 +1,15 @@
+package cmd
+
+import "os"
+
+// processExists reports whether a process with the given ID exists, as
+// finding a process fails on Windows if it does not.
+func processExists(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+
+	return true
+}
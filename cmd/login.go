@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/leotaku/kojirou/cmd/formats"
+	"github.com/leotaku/kojirou/cmd/formats/download"
+	md "github.com/leotaku/kojirou/mangadex"
+	"github.com/spf13/cobra"
+)
+
+var (
+	loginClientIDArg     string
+	loginClientSecretArg string
+	loginUsernameArg     string
+)
+
+// session is the login stored by "kojirou login".  The password is not
+// stored, instead the token is refreshed until its refresh token
+// expires.
+type session struct {
+	ClientID     string
+	ClientSecret string
+	Token        *md.Token
+}
+
+var loginCmd = &cobra.Command{
+	Use:   "login [flags..]",
+	Short: "Log in to MangaDex using a personal API client",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return login(cmd.Context())
+	},
+}
+
+var logoutCmd = &cobra.Command{
+	Use:   "logout",
+	Short: "Remove the stored MangaDex login",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		filename, err := sessionPath()
+		if err != nil {
+			return err
+		} else if err := os.Remove(filename); errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("not logged in")
+		} else {
+			return err
+		}
+	},
+}
+
+func login(ctx context.Context) error {
+	switch {
+	case loginClientIDArg == "":
+		return fmt.Errorf("client ID is required")
+	case loginClientSecretArg == "":
+		return fmt.Errorf("client secret is required")
+	case loginUsernameArg == "":
+		return fmt.Errorf("username is required")
+	}
+	password, err := readPassword("Password: ")
+	if err != nil {
+		return fmt.Errorf("read: %w", err)
+	}
+
+	token, err := getDownloader().MangadexLogin(ctx, md.Credentials{
+		ClientID:     loginClientIDArg,
+		ClientSecret: loginClientSecretArg,
+		Username:     loginUsernameArg,
+		Password:     password,
+	})
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+
+	return saveSession(session{
+		ClientID:     loginClientIDArg,
+		ClientSecret: loginClientSecretArg,
+		Token:        token,
+	})
+}
+
+// useSession logs the downloader in using the stored login, if any.
+// Refreshed tokens are stored again, so that later runs can use them.
+func useSession(d *download.Downloader) {
+	s, err := loadSession()
+	if errors.Is(err, fs.ErrNotExist) {
+		return
+	} else if err != nil {
+		formats.Debug("MangaDex login ignored", "error", err)
+		return
+	} else if s.Token.RefreshExpired() {
+		formats.PrintWarning("MangaDex login has expired, run 'kojirou login' again")
+		return
+	}
+
+	d.WithSession(s.ClientID, s.ClientSecret, s.Token, func(token *md.Token) error {
+		s.Token = token
+		return saveSession(s)
+	})
+}
+
+func loadSession() (session, error) {
+	s := session{}
+	filename, err := sessionPath()
+	if err != nil {
+		return s, err
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return s, err
+	} else if err := json.Unmarshal(data, &s); err != nil {
+		return s, fmt.Errorf("decode: %w", err)
+	} else if s.Token == nil {
+		return s, fmt.Errorf("decode: missing token")
+	}
+
+	return s, nil
+}
+
+func saveSession(s session) error {
+	filename, err := sessionPath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0o700); err != nil {
+		return fmt.Errorf("directory: %w", err)
+	}
+
+	return os.WriteFile(filename, data, 0o600)
+}
+
+func sessionPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("config: %w", err)
+	}
+
+	return filepath.Join(dir, "kojirou", "mangadex.json"), nil
+}
+
+func init() {
+	loginCmd.Flags().StringVarP(&loginClientIDArg, "client-id", "c", "", "client ID of your MangaDex personal API client")
+	loginCmd.Flags().StringVarP(&loginClientSecretArg, "client-secret", "s", "", "client secret of your MangaDex personal API client")
+	loginCmd.Flags().StringVarP(&loginUsernameArg, "username", "u", "", "username of your MangaDex account")
+	rootCmd.AddCommand(loginCmd, logoutCmd)
+}
@@ -0,0 +1,266 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/leotaku/kojirou/cmd/formats"
+	"github.com/leotaku/kojirou/cmd/formats/kindle"
+	md "github.com/leotaku/kojirou/mangadex"
+	"github.com/spf13/pflag"
+)
+
+const manifestFilename = "kojirou.json"
+
+var manifestFlags *pflag.FlagSet
+
+// manifestMutex serializes updates, since volumes may be recorded
+// concurrently.
+var manifestMutex sync.Mutex
+
+// Flags that only describe where and how output is written, which are
+// not recorded in manifests as they do not influence e-book content.
+var unrecordedFlags = map[string]bool{
+	"out":                true,
+	"force":              true,
+	"wait":               true,
+	"kindle-folder-mode": true,
+	"dry-run":            true,
+	"interactive":        true,
+	"from-file":          true,
+	"followed":           true,
+	"update":             true,
+	"cpuprofile":         true,
+	"config":             true,
+	"json":               true,
+	"verbose":            true,
+	"quiet":              true,
+	"log-level":          true,
+	"progress":           true,
+	"no-color":           true,
+	"log-file":           true,
+	"log-format":         true,
+	"metrics-listen":     true,
+	"cache":              true,
+	"no-cache":           true,
+	"max-memory":         true,
+	"pprof":              true,
+	"connections":        true,
+	"page-retries":       true,
+	"volume-jobs":        true,
+	"chapter-jobs":       true,
+	"image-jobs":         true,
+	"http1":              true,
+	"save-plan":          true,
+	"plan":               true,
+	"notify":             true,
+}
+
+type manifest struct {
+	Identifier string
+	Title      string
+	Flags      map[string]string
+	Volumes    map[string]string
+	// Chapters maps the filename of every volume to the identifiers
+	// and IDs of its chapters, so that new chapters can be detected.
+	Chapters map[string]map[string]string `json:",omitempty"`
+	// Failed maps the filename of every volume that failed in the
+	// last run to the reason, until it has been built successfully.
+	Failed map[string]string `json:",omitempty"`
+	// KindleFolder is set for series in the Kindle folder structure,
+	// so that updates keep writing thumbnails.
+	KindleFolder bool `json:",omitempty"`
+}
+
+func readManifest(directory string) (*manifest, error) {
+	m := &manifest{
+		Flags:    make(map[string]string),
+		Volumes:  make(map[string]string),
+		Chapters: make(map[string]map[string]string),
+		Failed:   make(map[string]string),
+	}
+	data, err := os.ReadFile(filepath.Join(directory, manifestFilename))
+	if errors.Is(err, fs.ErrNotExist) {
+		return m, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("decode: %w", err)
+	}
+	if m.Chapters == nil {
+		m.Chapters = make(map[string]map[string]string)
+	}
+	if m.Failed == nil {
+		m.Failed = make(map[string]string)
+	}
+
+	return m, nil
+}
+
+// manifestIdentifier returns the identifier recorded in manifests,
+// which is an absolute path for local directories.
+func manifestIdentifier() string {
+	if isLocal() {
+		abs, _ := filepath.Abs(identifierArg)
+		return abs
+	}
+
+	return identifierArg
+}
+
+// manifestDirectory is the directory of the series that is updated or
+// repaired, if any, which is used instead of a directory named after
+// the series.
+var manifestDirectory string
+
+// useManifestDirectory makes the next run write to the directory of the
+// manifest, keeping the Kindle folder structure if it was used.
+func useManifestDirectory(directory string, m *manifest) {
+	manifestDirectory = directory
+	outArg = directory
+	kindleFolderModeArg = m.KindleFolder || kindle.InFolderStructure(directory)
+}
+
+// sameIdentifier reports whether both identifiers refer to the same
+// manga, so that a MangaDex URL matches the bare ID it contains.
+func sameIdentifier(a, b string) bool {
+	if aID, ok := mangadexID(a); ok {
+		if bID, ok := mangadexID(b); ok {
+			return aID == bID
+		}
+	}
+
+	return a == b
+}
+
+// recordVolume adds the given volume and its chapters to the manifest
+// in the directory, together with the identifier and flags used to
+// generate it.
+func recordVolume(directory, title, filename string, volume md.Volume) error {
+	manifestMutex.Lock()
+	defer manifestMutex.Unlock()
+	m, err := readManifest(directory)
+	if err != nil {
+		return err
+	}
+
+	m.Identifier = manifestIdentifier()
+	m.Title = title
+	m.KindleFolder = kindleFolderModeArg
+	m.Volumes[filename] = volume.Info.Identifier.String()
+	m.Chapters[filename] = make(map[string]string)
+	for identifier, chapter := range volume.Chapters {
+		m.Chapters[filename][identifier.String()] = chapter.Info.ID
+	}
+	delete(m.Failed, filename)
+
+	// Repairs only rebuild a subset of volumes using the recorded
+	// flags, so they must not overwrite them.
+	if !repairing {
+		m.Flags = make(map[string]string)
+		manifestFlags.Visit(func(f *pflag.Flag) {
+			if !unrecordedFlags[f.Name] {
+				m.Flags[f.Name] = f.Value.String()
+			}
+		})
+	}
+
+	return writeManifest(directory, m)
+}
+
+// recordFailure records that the given volume failed to build, so that
+// scripts can find volumes that need to be retried.
+func recordFailure(directory, title, filename string, err error) error {
+	manifestMutex.Lock()
+	defer manifestMutex.Unlock()
+	m, readErr := readManifest(directory)
+	if readErr != nil {
+		return readErr
+	}
+
+	m.Identifier = manifestIdentifier()
+	m.Title = title
+	m.Failed[filename] = err.Error()
+
+	return writeManifest(directory, m)
+}
+
+func writeManifest(directory string, m *manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode: %w", err)
+	}
+
+	return formats.WriteFileAtomic(filepath.Join(directory, manifestFilename), data)
+}
+
+// hasNewChapters reports whether chapters have been added to the volume
+// since the file was recorded in the manifest.  Volumes that lack any
+// recorded chapters, for example because chapters were filtered, and
+// files recorded before chapters were recorded never have new chapters.
+func hasNewChapters(m *manifest, filename string, volume md.Volume) bool {
+	recorded, ok := m.Chapters[filename]
+	if !ok {
+		return false
+	}
+
+	current := make(map[string]bool)
+	for identifier := range volume.Chapters {
+		current[identifier.String()] = true
+	}
+	for identifier := range recorded {
+		if !current[identifier] {
+			return false
+		}
+	}
+
+	return len(current) > len(recorded)
+}
+
+// defaultFlags returns the values of all flags that were not given on
+// the command line, which are reset before every series is updated.
+func defaultFlags(flags *pflag.FlagSet) map[string]string {
+	defaults := make(map[string]string)
+	flags.VisitAll(func(f *pflag.Flag) {
+		if !f.Changed {
+			defaults[f.Name] = f.Value.String()
+		}
+	})
+
+	return defaults
+}
+
+// applyManifestFlags resets the flags of the previous series and sets
+// the flags recorded in the manifest.  Flags given on the command line
+// take precedence over recorded flags.  The resulting flags are checked
+// again and the recorded plugins are started.
+func applyManifestFlags(ctx context.Context, flags *pflag.FlagSet, defaults map[string]string, m *manifest) error {
+	for name, value := range defaults {
+		f := flags.Lookup(name)
+		if err := f.Value.Set(value); err != nil {
+			return fmt.Errorf("flag %v: %w", name, err)
+		}
+		f.Changed = false
+	}
+	for name, value := range m.Flags {
+		if _, ok := defaults[name]; !ok {
+			continue
+		} else if err := flags.Set(name, value); err != nil {
+			return fmt.Errorf("manifest: flag %v: %w", name, err)
+		}
+	}
+	if err := validateSeriesFlags(); err != nil {
+		return fmt.Errorf("manifest: %w", err)
+	} else if err := restartPlugins(ctx); err != nil {
+		return fmt.Errorf("manifest: plugin: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"fmt"
+	"runtime/debug"
+	"strconv"
+	"strings"
+)
+
+var (
+	maxMemoryArg string
+	memoryLimit  int64
+)
+
+// setMemoryLimit applies the memory budget given on the command line,
+// both as a soft limit for the garbage collector and as a limit for
+// concurrent downloads.
+func setMemoryLimit() error {
+	if maxMemoryArg == "" {
+		return nil
+	}
+
+	limit, err := parseSize(maxMemoryArg)
+	if err != nil {
+		return fmt.Errorf("max-memory: %w", err)
+	}
+	memoryLimit = limit
+	debug.SetMemoryLimit(limit)
+
+	return nil
+}
+
+// parseSize parses sizes such as "512M" or "1.5GiB", where units are
+// powers of 1024.
+func parseSize(s string) (int64, error) {
+	number := strings.TrimSuffix(strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(s)), "B"), "I")
+	factor := int64(1)
+	if n := len(number); n > 0 {
+		if i := strings.IndexByte("KMGT", number[n-1]); i >= 0 {
+			factor = 1 << (10 * (i + 1))
+			number = number[:n-1]
+		}
+	}
+
+	value, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf(`not a valid size: "%v"`, s)
+	}
+
+	return int64(value * float64(factor)), nil
+}
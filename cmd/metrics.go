@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/leotaku/kojirou/cmd/events"
+	"github.com/leotaku/kojirou/cmd/formats"
+	"github.com/leotaku/kojirou/cmd/metrics"
+)
+
+func init() {
+	events.Subscribe(func(e events.Event) {
+		switch e := e.(type) {
+		case events.PageFetched:
+			metrics.PagesDownloaded.Add(1)
+		case events.VolumeBuilt:
+			metrics.BuildDuration.Observe("", e.Duration.Seconds())
+		}
+	})
+}
+
+// serveMetrics exposes metrics on the given address until the program
+// exits, which is most useful for long-running batch downloads.
+func serveMetrics(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	go func() {
+		if err := http.Serve(listener, mux); err != nil {
+			formats.Debug("Metrics server stopped", "error", err)
+		}
+	}()
+
+	return nil
+}
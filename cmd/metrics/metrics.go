@@ -0,0 +1,165 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+var (
+	PagesDownloaded = NewCounter("kojirou_pages_downloaded_total", "Number of pages downloaded.")
+	BytesDownloaded = NewCounter("kojirou_downloaded_bytes_total", "Number of bytes received in HTTP responses.")
+	Retries         = NewCounter("kojirou_http_retries_total", "Number of retried HTTP requests.")
+	NodeFailures    = NewCounter("kojirou_node_failures_total", "Number of pages that MangaDex@Home nodes failed to serve.")
+	RequestDuration = NewHistogram("kojirou_http_request_duration_seconds", "Latency of HTTP requests.", "host",
+		[]float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30})
+	BuildDuration = NewHistogram("kojirou_volume_build_duration_seconds", "Time taken to build a volume.", "",
+		[]float64{1, 5, 10, 30, 60, 120, 300, 600})
+)
+
+var (
+	registry      []collector
+	registryMutex sync.Mutex
+)
+
+type collector interface {
+	write(w io.Writer)
+}
+
+func register(c collector) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	registry = append(registry, c)
+}
+
+// Handler serves all metrics in the Prometheus text format.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		Write(w)
+	})
+}
+
+func Write(w io.Writer) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	for _, c := range registry {
+		c.write(w)
+	}
+}
+
+type Counter struct {
+	name  string
+	help  string
+	value float64
+	mutex sync.Mutex
+}
+
+func NewCounter(name, help string) *Counter {
+	c := &Counter{name: name, help: help}
+	register(c)
+
+	return c
+}
+
+func (c *Counter) Add(v float64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.value += v
+}
+
+func (c *Counter) write(w io.Writer) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	fmt.Fprintf(w, "# HELP %v %v\n# TYPE %v counter\n", c.name, c.help, c.name)
+	fmt.Fprintf(w, "%v %v\n", c.name, formatFloat(c.value))
+}
+
+// Histogram counts observations in buckets, optionally partitioned by
+// the value of a single label.
+type Histogram struct {
+	name    string
+	help    string
+	label   string
+	buckets []float64
+	series  map[string]*histogramSeries
+	mutex   sync.Mutex
+}
+
+type histogramSeries struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func NewHistogram(name, help, label string, buckets []float64) *Histogram {
+	h := &Histogram{
+		name:    name,
+		help:    help,
+		label:   label,
+		buckets: buckets,
+		series:  make(map[string]*histogramSeries),
+	}
+	register(h)
+
+	return h
+}
+
+// Observe records a value for the given label value, which is ignored
+// if the histogram has no label.
+func (h *Histogram) Observe(labelValue string, v float64) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.label == "" {
+		labelValue = ""
+	}
+
+	s, ok := h.series[labelValue]
+	if !ok {
+		s = &histogramSeries{counts: make([]uint64, len(h.buckets))}
+		h.series[labelValue] = s
+	}
+	for i, bound := range h.buckets {
+		if v <= bound {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.sum += v
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	fmt.Fprintf(w, "# HELP %v %v\n# TYPE %v histogram\n", h.name, h.help, h.name)
+
+	values := make([]string, 0)
+	for value := range h.series {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+
+	for _, value := range values {
+		s := h.series[value]
+		labels := ""
+		if h.label != "" {
+			labels = fmt.Sprintf("%v=%q,", h.label, value)
+		}
+		for i, bound := range h.buckets {
+			fmt.Fprintf(w, "%v_bucket{%vle=\"%v\"} %v\n", h.name, labels, formatFloat(bound), s.counts[i])
+		}
+		fmt.Fprintf(w, "%v_bucket{%vle=\"+Inf\"} %v\n", h.name, labels, s.count)
+		if labels != "" {
+			labels = "{" + labels[:len(labels)-1] + "}"
+		}
+		fmt.Fprintf(w, "%v_sum%v %v\n", h.name, labels, formatFloat(s.sum))
+		fmt.Fprintf(w, "%v_count%v %v\n", h.name, labels, s.count)
+	}
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/leotaku/kojirou/cmd/events"
+	"github.com/leotaku/kojirou/cmd/formats"
+	"github.com/mattn/go-isatty"
+)
+
+var notifyArg string
+
+func init() {
+	events.Subscribe(func(e events.Event) {
+		if e, ok := e.(events.Finished); ok {
+			notify(e.Err)
+		}
+	})
+}
+
+func notifyMethods() []string {
+	methods := make([]string, 0)
+	for _, method := range strings.Split(notifyArg, ",") {
+		if method = strings.TrimSpace(method); method != "" {
+			methods = append(methods, method)
+		}
+	}
+
+	return methods
+}
+
+func validateNotify() error {
+	for _, method := range notifyMethods() {
+		switch method {
+		case "bell", "title", "desktop":
+		default:
+			return fmt.Errorf(`not a valid notification method: "%v"`, method)
+		}
+	}
+
+	return nil
+}
+
+// notify tells the user that a run has finished using the configured
+// methods.  Failures to notify are only logged.
+func notify(err error) {
+	message := "Finished"
+	if err != nil {
+		message = fmt.Sprintf("Failed: %v", err)
+	}
+	if identifierArg != "" {
+		message = fmt.Sprintf("%v: %v", identifierArg, message)
+	}
+
+	terminal := isatty.IsTerminal(os.Stderr.Fd())
+	for _, method := range notifyMethods() {
+		switch method {
+		case "bell":
+			if terminal {
+				fmt.Fprint(os.Stderr, "\a")
+			}
+		case "title":
+			if terminal {
+				fmt.Fprintf(os.Stderr, "\033]0;kojirou: %v\007", strings.ReplaceAll(message, "\007", ""))
+			}
+		case "desktop":
+			if err := notifyDesktop(message); err != nil {
+				formats.Debug("Desktop notification failed", "error", err)
+			}
+		}
+	}
+}
+
+func notifyDesktop(message string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		script := fmt.Sprintf("display notification %v with title \"Kojirou\"", strconv.Quote(message))
+		cmd = exec.Command("osascript", "-e", script)
+	case "windows":
+		return fmt.Errorf("not supported on %v", runtime.GOOS)
+	default:
+		cmd = exec.Command("notify-send", "Kojirou", message)
+	}
+
+	return cmd.Run()
+}
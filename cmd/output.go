@@ -0,0 +1,188 @@
+package cmd
+
+import (
+	"fmt"
+	"image/jpeg"
+	"sort"
+	"strings"
+
+	"github.com/leotaku/kojirou/cmd/formats"
+	"github.com/leotaku/kojirou/cmd/formats/archive"
+	"github.com/leotaku/kojirou/cmd/formats/epub"
+	"github.com/leotaku/kojirou/cmd/formats/kindle"
+	"github.com/leotaku/kojirou/cmd/formats/pdf"
+	"github.com/leotaku/kojirou/codec"
+	md "github.com/leotaku/kojirou/mangadex"
+	"github.com/spf13/pflag"
+	"golang.org/x/text/language"
+)
+
+var (
+	formatArg      string
+	pdfPageSizeArg string
+	pdfDPIArg      int
+	imageFormatArg string
+	jpegQualityArg int
+)
+
+// outputFormats maps the names accepted by --format to constructors
+// for the corresponding writers.
+var outputFormats = map[string]func() formats.FormatWriter{
+	"azw3": func() formats.FormatWriter {
+		options := kindle.MOBIOptions{
+			LeftToRight:      leftToRightArg,
+			FillVolumeNumber: fillVolumeNumberArg,
+		}
+		if setLanguageArg != "" {
+			options.Language = language.Make(setLanguageArg)
+		}
+		options.Encoder, options.Reencode = imageEncoderWithoutWebP()
+		return kindle.NewMOBIWriter(options)
+	},
+	"cbz": func() formats.FormatWriter {
+		options := archive.CBZOptions{
+			LeftToRight:      leftToRightArg,
+			FillVolumeNumber: fillVolumeNumberArg,
+		}
+		if setLanguageArg != "" {
+			options.Language = language.Make(setLanguageArg)
+		}
+		options.Encoder, options.Reencode = imageEncoder()
+		return archive.NewCBZWriter(options)
+	},
+	"epub": func() formats.FormatWriter {
+		options := epub.EPUBOptions{
+			LeftToRight:      leftToRightArg,
+			FillVolumeNumber: fillVolumeNumberArg,
+		}
+		if setLanguageArg != "" {
+			options.Language = language.Make(setLanguageArg)
+		}
+		options.Encoder, options.Reencode = imageEncoder()
+		return epub.NewEPUBWriter(options)
+	},
+	"pdf": func() formats.FormatWriter {
+		size, _ := pdf.ParsePageSize(pdfPageSizeArg)
+		options := pdf.PDFOptions{
+			PageSize:         size,
+			DPI:              pdfDPIArg,
+			LeftToRight:      leftToRightArg,
+			FillVolumeNumber: fillVolumeNumberArg,
+		}
+		if setLanguageArg != "" {
+			options.Language = language.Make(setLanguageArg)
+		}
+		options.Encoder, options.Reencode = imageEncoderWithoutWebP()
+		return pdf.NewPDFWriter(options)
+	},
+}
+
+func addPDFFlags(flags *pflag.FlagSet) {
+	flags.StringVarP(&pdfPageSizeArg, "pdf-page-size", "", "fit", "page size of PDF documents, e.g. fit, a5 or 6x8in")
+	flags.IntVarP(&pdfDPIArg, "pdf-dpi", "", pdf.DefaultDPI, "resolution of PDF pages that fit their images")
+}
+
+func validatePDFFlags() error {
+	if _, err := pdf.ParsePageSize(pdfPageSizeArg); err != nil {
+		return fmt.Errorf("pdf-page-size: %w", err)
+	} else if pdfDPIArg <= 0 {
+		return fmt.Errorf("not a valid resolution: %v", pdfDPIArg)
+	}
+
+	return nil
+}
+
+func addImageFlags(flags *pflag.FlagSet) {
+	flags.StringVarP(&imageFormatArg, "image-format", "", "original", fmt.Sprintf("encode all pages as %v, or only modified pages if original", strings.Join(codec.EncoderNames(), ", ")))
+	flags.IntVarP(&jpegQualityArg, "jpeg-quality", "", jpeg.DefaultQuality, "quality of encoded JPEG and WebP pages, from 1 to 100")
+}
+
+func validateImageFlags() error {
+	if jpegQualityArg < 1 || jpegQualityArg > 100 {
+		return fmt.Errorf("jpeg-quality: not a valid quality: %v", jpegQualityArg)
+	} else if _, err := codec.ParseEncoder(imageFormatArg, jpegQualityArg); imageFormatArg != "original" && err != nil {
+		return fmt.Errorf("image-format: %w", err)
+	}
+
+	return nil
+}
+
+// imageEncoder returns the encoder for pages and whether unmodified
+// pages are encoded as well, instead of copying their original data.
+func imageEncoder() (codec.Encoder, bool) {
+	if imageFormatArg == "original" {
+		return codec.JPEGEncoder{Quality: jpegQualityArg}, false
+	}
+	encoder, _ := codec.ParseEncoder(imageFormatArg, jpegQualityArg)
+
+	return encoder, true
+}
+
+// imageEncoderWithoutWebP returns the same as imageEncoder, but encodes
+// pages as JPEG instead of WebP for output formats that cannot contain
+// WebP images.
+func imageEncoderWithoutWebP() (codec.Encoder, bool) {
+	encoder, reencode := imageEncoder()
+	if encoder != nil && encoder.Format() == codec.WebP.Name {
+		return codec.JPEGEncoder{Quality: jpegQualityArg}, reencode
+	}
+
+	return encoder, reencode
+}
+
+// newWriters returns a new writer for every requested output format,
+// in the order they were given.
+func newWriters() ([]formats.FormatWriter, error) {
+	writers := make([]formats.FormatWriter, 0)
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(formatArg, ",") {
+		name = strings.TrimSpace(name)
+		constructor, ok := outputFormats[name]
+		if !ok {
+			return nil, fmt.Errorf(`not a valid output format: "%v", use one of %v`, name, strings.Join(formatNames(), ", "))
+		} else if !seen[name] {
+			writers = append(writers, constructor())
+			seen[name] = true
+		}
+	}
+
+	return writers, nil
+}
+
+func formatNames() []string {
+	names := make([]string, 0)
+	for name := range outputFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// feedWriter passes the volume to the writer in reading order.  Pages
+// are renumbered from zero within their chapter, as numbers of pages
+// read from disk may start anywhere or have gaps.
+func feedWriter(w formats.FormatWriter, skeleton md.Manga, volume md.Volume, pages md.ImageList) error {
+	if err := w.Begin(skeleton.WithChapters(volume.Sorted()), volume.Info.Identifier); err != nil {
+		return err
+	}
+	manga := skeleton.WithChapters(volume.Sorted()).WithPages(pages)
+	for _, chapter := range manga.Volumes[volume.Info.Identifier].Sorted() {
+		if err := w.AddChapter(chapter.Info); err != nil {
+			return err
+		}
+		for i, key := range chapter.Keys() {
+			err := w.AddPage(md.Image{
+				Image:             chapter.Pages[key],
+				ImageIdentifier:   i,
+				ChapterIdentifier: chapter.Info.Identifier,
+				VolumeIdentifier:  chapter.Info.VolumeIdentifier,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,165 @@
+package pipeline
+
+import (
+	"context"
+	"time"
+
+	"github.com/leotaku/kojirou/cmd/formats"
+	md "github.com/leotaku/kojirou/mangadex"
+)
+
+// Names of the steps that build a volume, in the order they run.
+const (
+	Fetch   = "fetch"
+	Process = "process"
+	Write   = "write"
+	Deliver = "deliver"
+)
+
+// Job is the state of a single volume as it moves through the steps
+// of a pipeline.  Steps read what earlier steps produced and add their
+// own results.  Jobs in the background run alongside other jobs, so
+// their steps should not draw progress bars.
+type Job struct {
+	Manga      md.Manga
+	Volume     md.Volume
+	Plan       VolumePlan
+	Progress   formats.CliProgress
+	Started    time.Time
+	Background bool
+
+	Pages md.ImageList
+	Files []string
+
+	// Reused holds pages that were processed by an earlier run, which
+	// are not processed again.
+	Reused md.ImageList
+}
+
+type Step interface {
+	Name() string
+	Run(ctx context.Context, job *Job) error
+}
+
+type funcStep struct {
+	name string
+	fn   func(ctx context.Context, job *Job) error
+}
+
+func (s funcStep) Name() string {
+	return s.name
+}
+
+func (s funcStep) Run(ctx context.Context, job *Job) error {
+	return s.fn(ctx, job)
+}
+
+// NewStep returns a step that runs the given function.
+func NewStep(name string, fn func(ctx context.Context, job *Job) error) Step {
+	return funcStep{name: name, fn: fn}
+}
+
+// Pipeline runs steps in order.  Methods that modify a pipeline return
+// a new pipeline, so that variants can be derived from a common base.
+type Pipeline struct {
+	steps []Step
+}
+
+func New(steps ...Step) Pipeline {
+	return Pipeline{steps: steps}
+}
+
+// Steps returns the names of all steps in order.
+func (p Pipeline) Steps() []string {
+	result := make([]string, 0, len(p.steps))
+	for _, step := range p.steps {
+		result = append(result, step.Name())
+	}
+
+	return result
+}
+
+// Without removes all steps with the given name.
+func (p Pipeline) Without(name string) Pipeline {
+	steps := make([]Step, 0, len(p.steps))
+	for _, step := range p.steps {
+		if step.Name() != name {
+			steps = append(steps, step)
+		}
+	}
+
+	return Pipeline{steps: steps}
+}
+
+// Replace substitutes all steps with the name of the given step.
+func (p Pipeline) Replace(step Step) Pipeline {
+	steps := make([]Step, 0, len(p.steps))
+	for _, existing := range p.steps {
+		if existing.Name() == step.Name() {
+			steps = append(steps, step)
+		} else {
+			steps = append(steps, existing)
+		}
+	}
+
+	return Pipeline{steps: steps}
+}
+
+// Split returns the steps up to and including the first step with the
+// given name, and the steps after it, so that they can run separately.
+func (p Pipeline) Split(name string) (Pipeline, Pipeline) {
+	for i, step := range p.steps {
+		if step.Name() == name {
+			return New(append([]Step{}, p.steps[:i+1]...)...), New(append([]Step{}, p.steps[i+1:]...)...)
+		}
+	}
+
+	return p, Pipeline{}
+}
+
+// Before inserts the given step before the first step with the given
+// name, or at the end if there is no such step.
+func (p Pipeline) Before(name string, step Step) Pipeline {
+	return p.insert(name, step, 0)
+}
+
+// After inserts the given step after the first step with the given
+// name, or at the end if there is no such step.
+func (p Pipeline) After(name string, step Step) Pipeline {
+	return p.insert(name, step, 1)
+}
+
+func (p Pipeline) insert(name string, step Step, offset int) Pipeline {
+	index := len(p.steps)
+	for i, existing := range p.steps {
+		if existing.Name() == name {
+			index = i + offset
+			break
+		}
+	}
+
+	steps := make([]Step, 0, len(p.steps)+1)
+	steps = append(steps, p.steps[:index]...)
+	steps = append(steps, step)
+	steps = append(steps, p.steps[index:]...)
+
+	return Pipeline{steps: steps}
+}
+
+// Run runs all steps on the job, stopping at the first error.  Errors
+// are returned as-is, so steps should describe their own failures.
+func (p Pipeline) Run(ctx context.Context, job *Job) error {
+	if job.Started.IsZero() {
+		job.Started = time.Now()
+	}
+	for _, step := range p.steps {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := step.Run(ctx, job); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	md "github.com/leotaku/kojirou/mangadex"
+)
+
+// Action describes what a plan does with a volume.
+type Action string
+
+const (
+	ActionBuild   Action = "build"
+	ActionRebuild Action = "rebuild"
+	ActionSkip    Action = "skip"
+)
+
+// Plan records the decisions made before any pages are downloaded.  It
+// only refers to chapters by their identifiers, so it can be stored and
+// carried out later against freshly fetched metadata.
+type Plan struct {
+	Identifier string
+	Title      string
+	Directory  string
+	Formats    []string
+	Volumes    []VolumePlan
+}
+
+type VolumePlan struct {
+	Volume   md.Identifier
+	Action   Action
+	Files    []string
+	Chapters []ChapterPlan
+}
+
+type ChapterPlan struct {
+	Chapter md.Identifier
+	ID      string
+	Groups  []string `json:",omitempty"`
+	Pages   int
+}
+
+// Chapters returns the IDs of all chapters in volumes that are not
+// skipped.
+func (p Plan) Chapters() map[string]bool {
+	result := make(map[string]bool)
+	for _, volume := range p.Volumes {
+		if volume.Action == ActionSkip {
+			continue
+		}
+		for _, chapter := range volume.Chapters {
+			result[chapter.ID] = true
+		}
+	}
+
+	return result
+}
+
+func (p Plan) Write(w io.Writer) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode: %w", err)
+	}
+	_, err = w.Write(append(data, '\n'))
+
+	return err
+}
+
+func ReadPlan(r io.Reader) (*Plan, error) {
+	plan := new(Plan)
+	if err := json.NewDecoder(r).Decode(plan); err != nil {
+		return nil, fmt.Errorf("decode: %w", err)
+	}
+
+	return plan, nil
+}
@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/leotaku/kojirou/cmd/formats"
+	"github.com/leotaku/kojirou/cmd/formats/kindle"
+	"github.com/leotaku/kojirou/cmd/pipeline"
+	md "github.com/leotaku/kojirou/mangadex"
+)
+
+var (
+	savePlanArg string
+	planArg     string
+	activePlan  *pipeline.Plan
+)
+
+// planRun decides what to do with every volume of the manga, without
+// downloading any pages.
+func planRun(manga md.Manga, dir kindle.NormalizedDirectory) (pipeline.Plan, error) {
+	writers, err := newWriters()
+	if err != nil {
+		return pipeline.Plan{}, err
+	}
+
+	plan := pipeline.Plan{
+		Identifier: identifierArg,
+		Title:      manga.Info.Title,
+		Directory:  dir.Directory(),
+		Formats:    make([]string, 0),
+		Volumes:    make([]pipeline.VolumePlan, 0),
+	}
+	for _, w := range writers {
+		plan.Formats = append(plan.Formats, w.Extension())
+	}
+	m, err := readManifest(dir.Directory())
+	if err != nil {
+		return pipeline.Plan{}, fmt.Errorf("manifest: %w", err)
+	}
+	for _, volume := range manga.Sorted() {
+		plan.Volumes = append(plan.Volumes, planVolume(volume, dir, writers, m))
+	}
+
+	return plan, nil
+}
+
+// planVolume skips existing volumes, unless they are forced to be
+// rebuilt or new chapters have been added to them.
+func planVolume(volume md.Volume, dir kindle.NormalizedDirectory, writers []formats.FormatWriter, m *manifest) pipeline.VolumePlan {
+	identifier := volume.Info.Identifier
+	result := pipeline.VolumePlan{
+		Volume:   identifier,
+		Action:   pipeline.ActionBuild,
+		Files:    make([]string, 0),
+		Chapters: make([]pipeline.ChapterPlan, 0),
+	}
+	switch {
+	case hasVolume(dir, identifier, writers) && !forceArg && !hasNewChapters(m, dir.Filename(identifier, writers[0].Extension()), volume):
+		result.Action = pipeline.ActionSkip
+	case dir.Has(identifier, writers[0].Extension()):
+		result.Action = pipeline.ActionRebuild
+	}
+	for _, w := range writers {
+		result.Files = append(result.Files, filepath.Join(dir.Directory(), dir.Filename(identifier, w.Extension())))
+	}
+	for _, chapter := range volume.Sorted() {
+		result.Chapters = append(result.Chapters, pipeline.ChapterPlan{
+			Chapter: chapter.Info.Identifier,
+			ID:      chapter.Info.ID,
+			Groups:  chapter.Info.GroupNames,
+			Pages:   chapter.Info.Pages,
+		})
+	}
+
+	return result
+}
+
+// restrictToPlan keeps only the chapters that the active plan builds,
+// so that a saved plan can be carried out or resumed later.
+func restrictToPlan(chapters md.ChapterList) md.ChapterList {
+	if activePlan == nil {
+		return chapters
+	}
+
+	planned := activePlan.Chapters()
+	result := make(md.ChapterList, 0)
+	for _, chapter := range chapters {
+		if planned[chapter.Info.ID] {
+			result = append(result, chapter)
+		}
+	}
+	if missing := len(planned) - len(result); missing > 0 {
+		formats.Report("Plan", "%v planned chapters are no longer available", missing)
+	}
+
+	return result
+}
+
+// printPlan lists the volumes of the plan and what would be done with
+// them, which is shown in dry-run mode.
+func printPlan(plan pipeline.Plan) {
+	if !formats.Enabled(formats.LevelInfo) || len(plan.Volumes) == 0 {
+		return
+	}
+
+	volumes := make([]string, 0, len(plan.Volumes))
+	for _, volume := range plan.Volumes {
+		volumes = append(volumes, fmt.Sprintf("%v (%v)", volume.Volume, volume.Action))
+	}
+	formats.PrintValue("Volumes", strings.Join(volumes, ", "))
+}
+
+func savePlan(plan pipeline.Plan, filename string) error {
+	if filename == "-" {
+		return plan.Write(os.Stdout)
+	}
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := plan.Write(f); err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+func loadPlan(filename string) (*pipeline.Plan, error) {
+	var r io.Reader = os.Stdin
+	if filename != "-" {
+		f, err := os.Open(filename)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	return pipeline.ReadPlan(r)
+}
@@ -0,0 +1,126 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// Client controls a running plugin process.
+type Client struct {
+	filename string
+	info     InfoReply
+	cmd      *exec.Cmd
+	rpc      *rpc.Client
+}
+
+// Start runs the plugin executable and checks that it speaks a
+// compatible protocol.  The plugin runs until Close is called.
+func Start(ctx context.Context, filename string) (*Client, error) {
+	cmd := exec.Command(filename)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	c := &Client{
+		filename: filename,
+		cmd:      cmd,
+		rpc:      jsonrpc.NewClient(stdio{stdout, stdin}),
+	}
+	if err := c.call(ctx, "Plugin.Info", InfoArgs{Protocol: Protocol}, &c.info); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("info: %w", err)
+	} else if c.info.Protocol != Protocol {
+		c.Close()
+		return nil, fmt.Errorf("unsupported protocol version %v, expected %v", c.info.Protocol, Protocol)
+	}
+	return c, nil
+}
+
+// Name returns the name declared by the plugin, or the name of its
+// executable if it did not declare one.
+func (c *Client) Name() string {
+	if c.info.Name == "" {
+		return filepath.Base(c.filename)
+	}
+
+	return c.info.Name
+}
+
+// Has reports whether the plugin declared the given kind.
+func (c *Client) Has(kind Kind) bool {
+	for _, k := range c.info.Kinds {
+		if k == kind {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CloseTimeout is how long Close waits for a plugin to exit after its
+// input was closed, before the plugin is killed.
+const CloseTimeout = time.Second * 5
+
+// Close stops the plugin by closing its input and waits for it to
+// exit.  Plugins that do not exit within CloseTimeout are killed.
+func (c *Client) Close() error {
+	c.rpc.Close()
+	done := make(chan error, 1)
+	go func() {
+		done <- c.cmd.Wait()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(CloseTimeout):
+		if err := c.cmd.Process.Kill(); err != nil {
+			return fmt.Errorf("kill: %w", err)
+		}
+		<-done
+		return fmt.Errorf("%v: killed after not exiting within %v", c.Name(), CloseTimeout)
+	}
+}
+
+func (c *Client) call(ctx context.Context, method string, args, reply interface{}) error {
+	call := c.rpc.Go(method, args, reply, make(chan *rpc.Call, 1))
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-call.Done:
+		if call.Error != nil {
+			return fmt.Errorf("%v: %w", c.Name(), call.Error)
+		}
+		return nil
+	}
+}
+
+type stdio struct {
+	io.ReadCloser
+	io.WriteCloser
+}
+
+func (s stdio) Close() error {
+	werr := s.WriteCloser.Close()
+	if err := s.ReadCloser.Close(); err != nil {
+		return err
+	}
+
+	return werr
+}
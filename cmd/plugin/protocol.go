@@ -0,0 +1,111 @@
+package plugin
+
+import (
+	md "github.com/leotaku/kojirou/mangadex"
+)
+
+// Protocol is incremented whenever the messages exchanged with plugins
+// change incompatibly.
+const Protocol = 1
+
+// Kind names a role that a plugin may fill.
+type Kind string
+
+const (
+	KindSource    Kind = "source"
+	KindProcessor Kind = "processor"
+	KindDeliverer Kind = "deliverer"
+)
+
+// Plugins are executables that serve JSON-RPC 1.0 on their standard
+// input and output.  Every plugin implements the "Plugin.Info" method,
+// as well as the methods of all kinds it declares:
+//
+//	Source.Match, Source.Series, Source.Chapters, Source.Covers, Source.Pages
+//	Processor.Process
+//	Deliverer.Deliver
+//
+// Images are passed as encoded image files, which JSON represents as
+// base64 strings.
+
+type InfoArgs struct {
+	Protocol int
+}
+
+type InfoReply struct {
+	Name     string
+	Protocol int
+	Kinds    []Kind
+}
+
+type MatchArgs struct {
+	Identifier string
+}
+
+type MatchReply struct {
+	Match bool
+}
+
+type SeriesArgs struct {
+	Identifier string
+}
+
+type SeriesReply struct {
+	Manga md.MangaInfo
+}
+
+type ChaptersArgs struct {
+	Identifier string
+	Manga      md.MangaInfo
+}
+
+type ChaptersReply struct {
+	Chapters []md.ChapterInfo
+}
+
+type CoversArgs struct {
+	Identifier string
+	Manga      md.MangaInfo
+}
+
+type Cover struct {
+	Volume md.Identifier
+	Data   []byte
+}
+
+type CoversReply struct {
+	Covers []Cover
+}
+
+type PagesArgs struct {
+	Identifier string
+	Chapter    md.ChapterInfo
+}
+
+// PagesReply contains the pages of a chapter in reading order.
+type PagesReply struct {
+	Pages [][]byte
+}
+
+// ProcessArgs pass a page as a PNG file.
+type ProcessArgs struct {
+	Manga   md.MangaInfo
+	Volume  md.Identifier
+	Chapter md.Identifier
+	Page    int
+	Data    []byte
+}
+
+// ProcessReply replaces the page, unless the data is empty.
+type ProcessReply struct {
+	Data []byte
+}
+
+type DeliverArgs struct {
+	Manga     md.MangaInfo
+	Volume    md.Identifier
+	Directory string
+	Files     []string
+}
+
+type DeliverReply struct{}
@@ -0,0 +1,172 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+
+	"github.com/leotaku/kojirou/cmd/formats"
+	"github.com/leotaku/kojirou/cmd/hooks"
+	"github.com/leotaku/kojirou/codec"
+	md "github.com/leotaku/kojirou/mangadex"
+)
+
+// Match reports whether the source plugin can download the manga with
+// the given identifier.
+func (c *Client) Match(ctx context.Context, identifier string) (bool, error) {
+	reply := MatchReply{}
+	if err := c.call(ctx, "Source.Match", MatchArgs{Identifier: identifier}, &reply); err != nil {
+		return false, err
+	}
+
+	return reply.Match, nil
+}
+
+// Provider returns a provider that downloads the manga with the given
+// identifier using the source plugin.
+func (c *Client) Provider(identifier string) *Provider {
+	return &Provider{client: c, identifier: identifier}
+}
+
+type Provider struct {
+	client     *Client
+	identifier string
+}
+
+func (p *Provider) Name() string {
+	return p.client.Name()
+}
+
+func (p *Provider) FetchSeries(ctx context.Context) (*md.Manga, error) {
+	reply := SeriesReply{}
+	if err := p.client.call(ctx, "Source.Series", SeriesArgs{Identifier: p.identifier}, &reply); err != nil {
+		return nil, err
+	}
+
+	return &md.Manga{
+		Info:    reply.Manga,
+		Volumes: make(map[md.Identifier]md.Volume),
+	}, nil
+}
+
+func (p *Provider) FetchChapters(ctx context.Context, manga md.Manga, prog formats.Progress) (md.ChapterList, error) {
+	reply := ChaptersReply{}
+	args := ChaptersArgs{Identifier: p.identifier, Manga: manga.Info}
+	if err := p.client.call(ctx, "Source.Chapters", args, &reply); err != nil {
+		return nil, err
+	}
+
+	result := make(md.ChapterList, 0)
+	for _, info := range reply.Chapters {
+		result = append(result, md.Chapter{
+			Info:  info,
+			Pages: make(map[int]image.Image),
+		})
+	}
+
+	return formats.WithSource(result, p), nil
+}
+
+func (p *Provider) FetchCovers(ctx context.Context, manga md.Manga, prog formats.Progress) (md.ImageList, error) {
+	reply := CoversReply{}
+	args := CoversArgs{Identifier: p.identifier, Manga: manga.Info}
+	if err := p.client.call(ctx, "Source.Covers", args, &reply); err != nil {
+		return nil, err
+	}
+
+	prog.Increase(len(reply.Covers))
+	result := make(md.ImageList, 0)
+	for _, cover := range reply.Covers {
+		img, err := codec.DecodeBytes(cover.Data)
+		if err != nil {
+			return nil, fmt.Errorf("volume %v: %w", cover.Volume, &md.DecodeError{Err: err})
+		}
+		prog.AddBytes(int64(len(cover.Data)))
+		prog.Add(1)
+		result = append(result, md.Image{Image: img, VolumeIdentifier: cover.Volume})
+	}
+
+	return result, nil
+}
+
+func (p *Provider) FetchPages(ctx context.Context, cl md.ChapterList, prog formats.Progress) (md.ImageList, error) {
+	prog.Increase(len(cl))
+	result := make(md.ImageList, 0)
+	for _, chapter := range cl {
+		reply := PagesReply{}
+		args := PagesArgs{Identifier: p.identifier, Chapter: chapter.Info}
+		if err := p.client.call(ctx, "Source.Pages", args, &reply); err != nil {
+			return nil, fmt.Errorf("chapter %v: %w", chapter.Info.Identifier, err)
+		}
+		for i, data := range reply.Pages {
+			img, err := codec.DecodeBytes(data)
+			if err != nil {
+				return nil, fmt.Errorf("chapter %v: page %v: %w", chapter.Info.Identifier, i, &md.DecodeError{Err: err})
+			}
+			prog.AddBytes(int64(len(data)))
+			result = append(result, md.Image{
+				Image:             img,
+				ImageIdentifier:   i,
+				ChapterIdentifier: chapter.Info.Identifier,
+				VolumeIdentifier:  chapter.Info.VolumeIdentifier,
+			})
+		}
+		prog.Add(1)
+	}
+
+	return result, nil
+}
+
+// ImageFunc returns a hook that passes every page to the processor
+// plugin.
+func (c *Client) ImageFunc() hooks.ImageFunc {
+	return func(ctx context.Context, t hooks.Target, img *md.Image) error {
+		decoded, err := codec.Decoded(img.Image)
+		if err != nil {
+			return fmt.Errorf("decode: %w", err)
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, decoded); err != nil {
+			return fmt.Errorf("encode: %w", err)
+		}
+
+		reply := ProcessReply{}
+		args := ProcessArgs{
+			Manga:   t.Manga,
+			Volume:  t.Volume,
+			Chapter: img.ChapterIdentifier,
+			Page:    img.ImageIdentifier,
+			Data:    buf.Bytes(),
+		}
+		if err := c.call(ctx, "Processor.Process", args, &reply); err != nil {
+			return err
+		} else if len(reply.Data) == 0 {
+			return nil
+		}
+
+		replaced, _, err := codec.Decode(bytes.NewReader(reply.Data))
+		if err != nil {
+			return fmt.Errorf("decode: %w", err)
+		}
+		img.Image = replaced
+
+		return nil
+	}
+}
+
+// VolumeFunc returns a hook that passes the files of every written
+// volume to the deliverer plugin.
+func (c *Client) VolumeFunc() hooks.VolumeFunc {
+	return func(ctx context.Context, t hooks.Target, files []string) error {
+		args := DeliverArgs{
+			Manga:     t.Manga,
+			Volume:    t.Volume,
+			Directory: t.Directory,
+			Files:     files,
+		}
+
+		return c.call(ctx, "Deliverer.Deliver", args, &DeliverReply{})
+	}
+}
@@ -0,0 +1,135 @@
+package plugin
+
+import (
+	"fmt"
+	"io"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+)
+
+// Source downloads manga from a site that Kojirou does not support.
+type Source interface {
+	Match(args MatchArgs) (MatchReply, error)
+	Series(args SeriesArgs) (SeriesReply, error)
+	Chapters(args ChaptersArgs) (ChaptersReply, error)
+	Covers(args CoversArgs) (CoversReply, error)
+	Pages(args PagesArgs) (PagesReply, error)
+}
+
+// Processor modifies pages after they have been cropped.
+type Processor interface {
+	Process(args ProcessArgs) (ProcessReply, error)
+}
+
+// Deliverer receives the files of every written volume, for example
+// to upload them.
+type Deliverer interface {
+	Deliver(args DeliverArgs) (DeliverReply, error)
+}
+
+// Plugin combines the implementations of a plugin.  Kinds that are
+// nil are not declared to Kojirou.
+type Plugin struct {
+	Name      string
+	Source    Source
+	Processor Processor
+	Deliverer Deliverer
+}
+
+// Serve answers requests from Kojirou on standard input and output
+// until Kojirou closes the connection.  It is meant to be called from
+// the main function of a plugin written in Go.
+func Serve(p Plugin) error {
+	return ServeConn(p, stdio{os.Stdin, os.Stdout})
+}
+
+func ServeConn(p Plugin, conn io.ReadWriteCloser) error {
+	server := rpc.NewServer()
+	kinds := make([]Kind, 0)
+	if p.Source != nil {
+		kinds = append(kinds, KindSource)
+		if err := server.RegisterName("Source", &sourceService{p.Source}); err != nil {
+			return err
+		}
+	}
+	if p.Processor != nil {
+		kinds = append(kinds, KindProcessor)
+		if err := server.RegisterName("Processor", &processorService{p.Processor}); err != nil {
+			return err
+		}
+	}
+	if p.Deliverer != nil {
+		kinds = append(kinds, KindDeliverer)
+		if err := server.RegisterName("Deliverer", &delivererService{p.Deliverer}); err != nil {
+			return err
+		}
+	}
+	info := InfoReply{Name: p.Name, Protocol: Protocol, Kinds: kinds}
+	if err := server.RegisterName("Plugin", &pluginService{info}); err != nil {
+		return err
+	}
+	server.ServeCodec(jsonrpc.NewServerCodec(conn))
+
+	return nil
+}
+
+type pluginService struct {
+	info InfoReply
+}
+
+func (s *pluginService) Info(args InfoArgs, reply *InfoReply) error {
+	if args.Protocol != Protocol {
+		return fmt.Errorf("unsupported protocol version %v, expected %v", args.Protocol, Protocol)
+	}
+	*reply = s.info
+
+	return nil
+}
+
+type sourceService struct {
+	source Source
+}
+
+func (s *sourceService) Match(args MatchArgs, reply *MatchReply) (err error) {
+	*reply, err = s.source.Match(args)
+	return err
+}
+
+func (s *sourceService) Series(args SeriesArgs, reply *SeriesReply) (err error) {
+	*reply, err = s.source.Series(args)
+	return err
+}
+
+func (s *sourceService) Chapters(args ChaptersArgs, reply *ChaptersReply) (err error) {
+	*reply, err = s.source.Chapters(args)
+	return err
+}
+
+func (s *sourceService) Covers(args CoversArgs, reply *CoversReply) (err error) {
+	*reply, err = s.source.Covers(args)
+	return err
+}
+
+func (s *sourceService) Pages(args PagesArgs, reply *PagesReply) (err error) {
+	*reply, err = s.source.Pages(args)
+	return err
+}
+
+type processorService struct {
+	processor Processor
+}
+
+func (s *processorService) Process(args ProcessArgs, reply *ProcessReply) (err error) {
+	*reply, err = s.processor.Process(args)
+	return err
+}
+
+type delivererService struct {
+	deliverer Deliverer
+}
+
+func (s *delivererService) Deliver(args DeliverArgs, reply *DeliverReply) (err error) {
+	*reply, err = s.deliverer.Deliver(args)
+	return err
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/leotaku/kojirou/cmd/formats"
+	"github.com/leotaku/kojirou/cmd/hooks"
+	"github.com/leotaku/kojirou/cmd/plugin"
+)
+
+var (
+	pluginsArg     string
+	plugins        []*plugin.Client
+	startedPlugins string
+)
+
+// startPlugins runs all plugins given on the command line, which keep
+// running until closePlugins is called.
+func startPlugins(ctx context.Context) error {
+	startedPlugins = pluginsArg
+	for _, filename := range strings.Split(pluginsArg, ",") {
+		if filename = strings.TrimSpace(filename); filename == "" {
+			continue
+		}
+		c, err := plugin.Start(ctx, filename)
+		if err != nil {
+			return fmt.Errorf("%v: %w", filename, err)
+		}
+		formats.Debug("Started plugin", "name", c.Name(), "file", filename)
+		plugins = append(plugins, c)
+	}
+
+	return nil
+}
+
+// restartPlugins runs the plugins given by --plugins if they differ
+// from the running plugins, as they may be recorded for every series.
+func restartPlugins(ctx context.Context) error {
+	if pluginsArg == startedPlugins {
+		return nil
+	}
+	closePlugins()
+
+	return startPlugins(ctx)
+}
+
+func closePlugins() {
+	for _, c := range plugins {
+		if err := c.Close(); err != nil {
+			formats.Debug("Plugin exited", "name", c.Name(), "error", err)
+		}
+	}
+	plugins = nil
+}
+
+// pluginSource returns the first source plugin that supports the
+// identifier, or nil if there is none.
+func pluginSource(ctx context.Context, identifier string) (formats.Provider, error) {
+	for _, c := range plugins {
+		if !c.Has(plugin.KindSource) {
+			continue
+		}
+		if ok, err := c.Match(ctx, identifier); err != nil {
+			return nil, err
+		} else if ok {
+			return c.Provider(identifier), nil
+		}
+	}
+
+	return nil, nil
+}
+
+// pluginHooks returns hooks for all processor and deliverer plugins.
+func pluginHooks() hooks.Hooks {
+	result := hooks.Hooks{}
+	for _, c := range plugins {
+		if c.Has(plugin.KindProcessor) {
+			result.PostImage = append(result.PostImage, c.ImageFunc())
+		}
+		if c.Has(plugin.KindDeliverer) {
+			result.PostVolume = append(result.PostVolume, c.VolumeFunc())
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/leotaku/kojirou/cmd/formats/kindle"
+	"github.com/spf13/cobra"
+)
+
+var repairing bool
+
+var repairCmd = &cobra.Command{
+	Use:   "repair [flags..] <report>",
+	Short: "Rebuild e-books reported as missing or corrupted by verify",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return repair(cmd.Context(), args[0])
+	},
+}
+
+func repair(ctx context.Context, report string) error {
+	data, err := os.ReadFile(report)
+	if err != nil {
+		return fmt.Errorf("report: %w", err)
+	}
+	results := make([]kindle.VerifyResult, 0)
+	if err := json.Unmarshal(data, &results); err != nil {
+		return fmt.Errorf("report: %w", err)
+	}
+
+	broken := make(map[string][]string)
+	directories := make([]string, 0)
+	for _, result := range results {
+		switch result.Status {
+		case kindle.StatusMissing, kindle.StatusMismatch, kindle.StatusCorrupt:
+			if _, ok := broken[result.Directory]; !ok {
+				directories = append(directories, result.Directory)
+			}
+			broken[result.Directory] = append(broken[result.Directory], result.Filename)
+		}
+	}
+
+	defaults := defaultFlags(manifestFlags)
+	for _, directory := range directories {
+		if err := repairDirectory(ctx, directory, broken[directory], defaults); err != nil {
+			return fmt.Errorf("%v: %w", directory, err)
+		}
+	}
+	manifestDirectory = ""
+
+	return nil
+}
+
+// repairDirectory rebuilds the given files using the flags recorded in
+// the manifest of the directory.  Flags recorded for other directories
+// are reset to the given defaults first.
+func repairDirectory(ctx context.Context, directory string, filenames []string, defaults map[string]string) error {
+	m, err := readManifest(directory)
+	if err != nil {
+		return fmt.Errorf("manifest: %w", err)
+	} else if m.Identifier == "" {
+		return fmt.Errorf("manifest: not found, cannot repair")
+	}
+
+	volumes := make([]string, 0)
+	for _, filename := range filenames {
+		if volume, ok := m.Volumes[filename]; !ok {
+			return fmt.Errorf("manifest: unknown file %v", filename)
+		} else {
+			volumes = append(volumes, volume)
+		}
+	}
+
+	if err := applyManifestFlags(ctx, manifestFlags, defaults, m); err != nil {
+		return err
+	}
+	identifierArg = m.Identifier
+	volumesFilter = strings.Join(volumes, ",")
+	useManifestDirectory(directory, m)
+	forceArg = true
+	repairing = true
+
+	return run(ctx)
+}
+
+func init() {
+	rootCmd.AddCommand(repairCmd)
+}
@@ -0,0 +1,201 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image/png"
+	"runtime"
+
+	"github.com/leotaku/kojirou/codec"
+	md "github.com/leotaku/kojirou/mangadex"
+)
+
+// processingFingerprint identifies the options that change pages, so
+// that processed pages are only reused if they would be processed in
+// the same way again.  This includes --data-saver, as it changes the
+// pages that are processed.  Pages are not stored if no pages are processed,
+// as they are already cached as downloaded, or if programs embedding
+// Kojirou have registered hooks, which cannot be identified.
+func processingFingerprint() (string, bool) {
+	if pageCache == nil {
+		return "", false
+	}
+	if len(Hooks.PreChapter)+len(Hooks.PostChapter)+len(Hooks.PreImage)+len(Hooks.PostImage) > 0 {
+		return "", false
+	}
+
+	if !autocropArg && !einkOptimizeArg && resizeArg == "" &&
+		preChapterHookArg == "" && postChapterHookArg == "" &&
+		preImageHookArg == "" && postImageHookArg == "" && pluginsArg == "" {
+		return "", false
+	}
+
+	options := fmt.Sprintln(dataSaverArg, autocropArg, einkOptimizeArg, resizeArg, resizeFilterArg, upscaleCommandArg, preChapterHookArg, postChapterHookArg, preImageHookArg, postImageHookArg, pluginsArg)
+	sum := sha256.Sum256([]byte(options))
+
+	return hex.EncodeToString(sum[:8]), true
+}
+
+func processedKey(fingerprint, chapterID string) string {
+	return fmt.Sprintf("processed/%v/%v", fingerprint, chapterID)
+}
+
+func processedPageKey(fingerprint, chapterID string, page int) string {
+	return fmt.Sprintf("processed/%v/%v/%v", fingerprint, chapterID, page)
+}
+
+// reusable reports whether the processed pages of the chapter may be
+// stored.  Chapters from disk may change without changing their ID.
+func reusable(chapter md.ChapterInfo) bool {
+	return chapter.Source != "Disk" && chapter.Pages > 0
+}
+
+// reuseProcessed loads the pages of all chapters of the volume that
+// were processed by an earlier run, and returns the volume without
+// those chapters.
+func reuseProcessed(ctx context.Context, volume md.Volume) (md.ImageList, md.Volume) {
+	fingerprint, ok := processingFingerprint()
+	if !ok {
+		return nil, volume
+	}
+
+	reused := make(md.ImageList, 0)
+	remaining := volume
+	remaining.Chapters = make(map[md.Identifier]md.Chapter)
+	for identifier, chapter := range volume.Chapters {
+		pages, err := loadProcessed(ctx, fingerprint, chapter.Info)
+		if err != nil {
+			remaining.Chapters[identifier] = chapter
+			continue
+		}
+		reused = append(reused, pages...)
+	}
+
+	return reused, remaining
+}
+
+func loadProcessed(ctx context.Context, fingerprint string, chapter md.ChapterInfo) (md.ImageList, error) {
+	if !reusable(chapter) {
+		return nil, fmt.Errorf("not reusable")
+	}
+	data, err := pageCache.Get(ctx, processedKey(fingerprint, chapter.ID))
+	if err != nil {
+		return nil, err
+	}
+	identifiers := make([]int, 0)
+	if err := json.Unmarshal(data, &identifiers); err != nil {
+		return nil, fmt.Errorf("decode: %w", err)
+	}
+
+	result := make(md.ImageList, 0, len(identifiers))
+	for _, identifier := range identifiers {
+		data, err := pageCache.Get(ctx, processedPageKey(fingerprint, chapter.ID, identifier))
+		if err != nil {
+			return nil, err
+		}
+		img, err := codec.DecodeBytes(data)
+		if err != nil {
+			return nil, fmt.Errorf("decode: %w", err)
+		}
+		result = append(result, md.Image{
+			Image:             img,
+			ImageIdentifier:   identifier,
+			ChapterIdentifier: chapter.Identifier,
+			VolumeIdentifier:  chapter.VolumeIdentifier,
+		})
+	}
+
+	return result, nil
+}
+
+// storeProcessed stores the processed pages of all complete chapters of
+// the volume, so that later runs can reuse them.  Pages that were not
+// modified are stored as downloaded, while all others are stored as PNG
+// so that they lose no quality.
+func storeProcessed(ctx context.Context, volume md.Volume, pages md.ImageList) error {
+	fingerprint, ok := processingFingerprint()
+	if !ok {
+		return nil
+	}
+
+	for _, chapter := range volume.Chapters {
+		chapterPages := make(md.ImageList, 0)
+		for _, page := range pages {
+			if page.ChapterIdentifier == chapter.Info.Identifier {
+				chapterPages = append(chapterPages, page)
+			}
+		}
+		if !reusable(chapter.Info) || len(chapterPages) != chapter.Info.Pages {
+			continue
+		}
+
+		identifiers := make([]int, 0, len(chapterPages))
+		for _, page := range chapterPages {
+			data, err := encodeProcessed(page)
+			if err != nil {
+				return fmt.Errorf("chapter %v: page %v: %w", chapter.Info.Identifier, page.ImageIdentifier, err)
+			}
+			key := processedPageKey(fingerprint, chapter.Info.ID, page.ImageIdentifier)
+			err = pageCache.Put(ctx, key, data)
+			runtime.KeepAlive(page.Image)
+			if err != nil {
+				return err
+			}
+			identifiers = append(identifiers, page.ImageIdentifier)
+		}
+
+		// The list of pages is written last, so that chapters are only
+		// reused once all of their pages have been stored.
+		data, err := json.Marshal(identifiers)
+		if err != nil {
+			return err
+		}
+		if err := pageCache.Put(ctx, processedKey(fingerprint, chapter.Info.ID), data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// encodeProcessed returns the data stored for a processed page.  The
+// original data of unmodified pages is returned as is, so the page must
+// be kept alive while the data is used.
+func encodeProcessed(page md.Image) ([]byte, error) {
+	if encoded, ok := codec.Original(page.Image); ok {
+		return encoded.Data, nil
+	}
+
+	decoded, err := codec.Decoded(page.Image)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, decoded); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// withoutPages returns the volume without the chapters of the pages.
+func withoutPages(volume md.Volume, pages md.ImageList) md.Volume {
+	chapters := make(map[md.Identifier]bool)
+	for _, page := range pages {
+		chapters[page.ChapterIdentifier] = true
+	}
+
+	result := volume
+	result.Chapters = make(map[md.Identifier]md.Chapter)
+	for identifier, chapter := range volume.Chapters {
+		if !chapters[identifier] {
+			result.Chapters[identifier] = chapter
+		}
+	}
+
+	return result
+}
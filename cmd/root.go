@@ -1,10 +1,21 @@
 package cmd
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"os"
+	"os/signal"
 	"runtime/pprof"
 
+	"github.com/fatih/color"
+	"github.com/leotaku/kojirou/cmd/cache"
+	"github.com/leotaku/kojirou/cmd/events"
+	"github.com/leotaku/kojirou/cmd/formats"
+	"github.com/leotaku/kojirou/cmd/formats/download"
+	md "github.com/leotaku/kojirou/mangadex"
 	"github.com/spf13/cobra"
+	"golang.org/x/text/language"
 )
 
 var (
@@ -12,6 +23,7 @@ var (
 	languageArg         string
 	rankArg             string
 	autocropArg         bool
+	einkOptimizeArg     bool
 	kindleFolderModeArg bool
 	dryRunArg           bool
 	outArg              string
@@ -19,10 +31,38 @@ var (
 	leftToRightArg      bool
 	fillVolumeNumberArg int
 	diskArg             string
+	mangaupdatesArg     bool
+	setTitleArg         string
+	setAuthorsArg       string
+	setSeriesArg        string
+	setLanguageArg      string
 	cpuprofileArg       string
+	jsonArg             bool
+	verboseArg          bool
+	quietArg            bool
+	logLevelArg         string
+	progressArg         string
+	noColorArg          bool
+	logFileArg          string
+	logFormatArg        string
+	metricsListenArg    string
+	cacheArg            string
+	cacheMaxSizeArg     string
+	connectionsArg      int
+	pageRetriesArg      int
+	http1Arg            bool
+	dataSaverArg        bool
+	strictArg           bool
+	noCacheArg          bool
+	volumeJobsArg       int
+	chapterJobsArg      int
+	imageJobsArg        int
 	groupsFilter        string
 	chaptersFilter      string
 	volumesFilter       string
+	expressionFilter    string
+	sinceFilter         string
+	untilFilter         string
 	helpRankingFlag     bool
 	helpFilterFlag      bool
 )
@@ -31,14 +71,126 @@ var rootCmd = &cobra.Command{
 	Use:     "kojirou [flags..] <identifier>",
 	Short:   "Generate Kindle-compatible e-books from MangaDex",
 	Version: "0.1",
-	Args:    cobra.ExactArgs(1),
+	Args: func(cmd *cobra.Command, args []string) error {
+		if fromFileArg != "" || followedArg || updateArg {
+			return cobra.NoArgs(cmd, args)
+		} else if planArg != "" {
+			return cobra.MaximumNArgs(1)(cmd, args)
+		}
+
+		return cobra.ExactArgs(1)(cmd, args)
+	},
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cmd.SilenceUsage = true
+		if fromFileArg == "-" && interactiveArg {
+			return fmt.Errorf("cannot select chapters interactively when reading identifiers from stdin")
+		} else if fromFileArg != "" && followedArg {
+			return fmt.Errorf("cannot read identifiers from a file and download followed manga at once")
+		} else if updateArg && (fromFileArg != "" || followedArg) {
+			return fmt.Errorf("cannot update a library and download other manga at once")
+		} else if updateArg {
+			return runUpdate(cmd.Context(), updateRoot())
+		} else if fromFileArg != "" {
+			return runBatch(cmd.Context(), fromFileArg)
+		} else if followedArg {
+			return runFollowed(cmd.Context())
+		}
+		if planArg != "" {
+			plan, err := loadPlan(planArg)
+			if err != nil {
+				return fmt.Errorf("plan: %w", err)
+			}
+			activePlan = plan
+			args = append(args, plan.Identifier)
+		}
 		identifierArg = args[0]
 
-		return run()
+		return run(cmd.Context())
 	},
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if cmd.Name() == cobra.ShellCompRequestCmd {
+			return nil
+		}
+		if err := applyConfig(cmd, args); err != nil {
+			cmd.SilenceUsage = true
+			return fmt.Errorf("config: %w", err)
+		}
+		if updateArg {
+			// Every series is updated using the language it was
+			// generated with.
+			cmd.Flags().SetAnnotation("language", cobra.BashCompOneRequiredFlag, []string{"false"}) //nolint:errcheck
+		}
+		if err := setLogLevel(); err != nil {
+			cmd.SilenceUsage = true
+			return err
+		}
+		if noColorArg {
+			color.NoColor = true
+		}
+		if logFileArg != "" {
+			if err := formats.OpenLogFile(logFileArg, logFormatArg); err != nil {
+				cmd.SilenceUsage = true
+				return fmt.Errorf("log file: %w", err)
+			}
+		}
+		if metricsListenArg != "" {
+			if err := serveMetrics(metricsListenArg); err != nil {
+				cmd.SilenceUsage = true
+				return fmt.Errorf("metrics: %w", err)
+			}
+		}
+		if pprofArg != "" {
+			if err := servePprof(pprofArg); err != nil {
+				cmd.SilenceUsage = true
+				return fmt.Errorf("pprof: %w", err)
+			}
+		}
+		watchProfileSignal()
+		if err := setMemoryLimit(); err != nil {
+			cmd.SilenceUsage = true
+			return err
+		}
+		if err := openCache(cmd); err != nil {
+			cmd.SilenceUsage = true
+			return fmt.Errorf("cache: %w", err)
+		}
+		if volumeJobsArg < 0 {
+			cmd.SilenceUsage = true
+			return fmt.Errorf("not a valid number of volume jobs: %v", volumeJobsArg)
+		}
+		if chapterJobsArg < 1 {
+			cmd.SilenceUsage = true
+			return fmt.Errorf("not a valid number of chapter jobs: %v", chapterJobsArg)
+		}
+		if imageJobsArg < 1 {
+			cmd.SilenceUsage = true
+			return fmt.Errorf("not a valid number of image jobs: %v", imageJobsArg)
+		}
+		// Every download needs its own connection, so raising the
+		// number of jobs raises the default number of connections.
+		if !cmd.Flags().Changed("connections") {
+			connectionsArg = max(connectionsArg, imageJobsArg)
+		}
+		if pageRetriesArg < 0 {
+			cmd.SilenceUsage = true
+			return fmt.Errorf("not a valid number of page retries: %v", pageRetriesArg)
+		}
+		if err := formats.SetProgressMode(progressArg); err != nil {
+			cmd.SilenceUsage = true
+			return err
+		}
+		if err := validateNotify(); err != nil {
+			cmd.SilenceUsage = true
+			return err
+		}
+		if err := startPlugins(cmd.Context()); err != nil {
+			cmd.SilenceUsage = true
+			return fmt.Errorf("plugin: %w", err)
+		}
+		if err := validateSeriesFlags(); err != nil {
+			cmd.SilenceUsage = true
+			return err
+		}
 		if cpuprofileArg != "" {
 			f, err := os.Create(cpuprofileArg)
 			if err != nil {
@@ -132,6 +284,28 @@ the given regular expression.  If you remove the "!" prefix
 of the regular expression, Kojirou will instead only download
 chapters by groups that match the regular expression.
 
+  $ kojirou ID --language LANG --since 7d
+
+The previous command will only download chapters that became
+readable within the last seven days.  Both "--since" and
+"--until" accept dates like 2023-01-31, RFC 3339 timestamps
+and durations in hours, days or weeks like 12h, 7d or 2w.
+Chapters without a known date, such as chapters loaded from
+disk, are never selected by these filters.
+
+  $ kojirou ID --language LANG --filter 'chapter >= 100 && !contains(groups, "GROUP")'
+
+For selections that are too complex for the previous filters,
+the "--filter" option accepts an expression that is evaluated
+for every chapter.  Expressions may compare the fields chapter,
+volume, title, lang, group, groups, pages, views, published,
+readable and id using ==, !=, <, <=, >, >= and =~ (match
+against a quoted regular expression), combine them using &&, ||
+and !, and use contains(LIST, VALUE) to check for a group or a
+substring.  Expressions are checked before any chapter is
+selected, so that mistakes are reported instead of silently
+leaving out chapters.
+
   $ kojirou ID --language BCP_47_LANGUAGE_TAG
 
 Technically, the "--language" option is also implemented
@@ -139,36 +313,249 @@ as a filter, however it is non-optional and must always be
 given.  It accepts the format of BCP 47 language tags.`,
 }
 
+func setLogLevel() error {
+	level, err := formats.ParseLevel(logLevelArg)
+	if err != nil {
+		return err
+	}
+	if verboseArg {
+		level = formats.LevelDebug
+	} else if quietArg {
+		level = formats.LevelError
+		formats.EnableResultLine()
+	}
+	formats.SetLevel(level)
+
+	return nil
+}
+
+// errorHint suggests how to resolve errors caused by MangaDex.
+func errorHint(err error) string {
+	switch {
+	case errors.Is(err, md.ErrRateLimited):
+		return "MangaDex is limiting requests, try again later"
+	case errors.Is(err, md.ErrUnauthorized):
+		return `Log in to MangaDex again using "kojirou login"`
+	case errors.Is(err, md.ErrPagesMissing):
+		return "MangaDex may still be processing the chapter, try again later"
+	case errors.Is(err, md.ErrChapterUnavailable):
+		return "Exclude unavailable chapters using --chapters or --filter"
+	case errors.Is(err, md.ErrNotFound):
+		return "Check that the identifier refers to an existing manga"
+	default:
+		return ""
+	}
+}
+
+// validateSeriesFlags checks the flags that may be recorded for a
+// series, which are checked again for every series that is updated or
+// repaired using the flags from its manifest.
+func validateSeriesFlags() error {
+	if _, err := newWriters(); err != nil {
+		return err
+	} else if err := sanitizer().Validate(); err != nil {
+		return err
+	} else if err := validateLanguages("language", languageArg); err != nil {
+		return err
+	} else if err := validateLanguages("fallback-language", fallbackLanguageArg); err != nil {
+		return err
+	} else if _, err := parseNoVolume(noVolumeArg); err != nil {
+		return err
+	} else if err := validatePDFFlags(); err != nil {
+		return err
+	} else if err := validateScaleFlags(); err != nil {
+		return err
+	} else if err := validateImageFlags(); err != nil {
+		return err
+	} else if _, err := language.Parse(setLanguageArg); setLanguageArg != "" && err != nil {
+		return fmt.Errorf("set-language: %w", err)
+	}
+
+	return nil
+}
+
+// openCache opens the page cache, so that pages downloaded by runs that
+// were interrupted or failed do not have to be downloaded again.  The
+// default cache in the user cache directory is skipped if there is no
+// such directory.  Caches in directories are pruned to the maximum size
+// before every run.
+func openCache(cmd *cobra.Command) error {
+	if noCacheArg {
+		return nil
+	}
+
+	c, err := cache.Open(cacheArg)
+	if err != nil && !cmd.Flags().Changed("cache") {
+		formats.Debug("Page cache disabled", "error", err)
+		return nil
+	} else if err != nil {
+		return err
+	}
+	pageCache = c
+
+	if cacheMaxSizeArg == "0" {
+		return nil
+	} else if maxSize, err := parseSize(cacheMaxSizeArg); err != nil {
+		return fmt.Errorf("cache-max-size: %w", err)
+	} else if disk, ok := c.(*cache.Disk); ok {
+		if err := disk.Prune(cmd.Context(), maxSize); err != nil {
+			formats.Debug("Pruning page cache failed", "error", err)
+		}
+	}
+
+	return nil
+}
+
+// interruptContext returns a context that is canceled once the user
+// interrupts the program, so that running downloads stop and locks
+// are released.  A second interrupt exits immediately.
+func interruptContext() context.Context {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	return ctx
+}
+
 func Execute() {
 	if helpRankingFlag {
 		helpRankingCmd.Help() //nolint:errcheck
 	} else if helpFilterFlag {
 		helpFilterCmd.Help() //nolint:errcheck
-	} else if err := rootCmd.Execute(); err != nil {
-		os.Exit(1)
+	} else if err := rootCmd.ExecuteContext(interruptContext()); err != nil {
+		// Partial results have already been printed.
+		if !isPartial(err) {
+			formats.PrintResultError(identifierArg, err)
+		}
+		if hint := errorHint(err); hint != "" {
+			formats.Report("Hint", "%v", hint)
+		}
+		formats.PrintReport()
+		events.Publish(events.Finished{Err: err})
+		closePlugins()
+		formats.LogError(err)
+		formats.CloseLogFile()
+		os.Exit(exitCode(err))
+	} else {
+		formats.PrintReport()
+		events.Publish(events.Finished{})
+		closePlugins()
+		formats.CloseLogFile()
 	}
 }
 
+// activeManga is the manga that is being built, which is added to the
+// events of the downloader, as it does not know about the manga.
+var activeManga md.MangaInfo
+
 func init() {
-	rootCmd.Flags().StringVarP(&languageArg, "language", "l", "en", "language for chapter downloads")
+	events.Subscribe(func(e events.Event) {
+		switch e := e.(type) {
+		case events.ChapterStarted:
+			formats.PrintEvent(formats.Event{
+				Event:   "chapter",
+				Manga:   activeManga.Title,
+				Volume:  e.Chapter.VolumeIdentifier.String(),
+				Chapter: e.Chapter.Identifier.String(),
+				Total:   int64(e.Chapter.Pages),
+				Message: e.Chapter.Title,
+			})
+		case events.PageFetched:
+			formats.PrintEvent(formats.Event{
+				Event:   "page",
+				Manga:   activeManga.Title,
+				Volume:  e.Volume.String(),
+				Chapter: e.Chapter.String(),
+				Page:    e.Page,
+			})
+		case events.VolumeBuilt:
+			formats.PrintEvent(formats.Event{
+				Event:    "volume",
+				Manga:    e.Manga.Title,
+				Volume:   e.Volume.String(),
+				Total:    int64(e.Pages),
+				Duration: e.Duration.Seconds(),
+			})
+		case events.Finished:
+			if e.Err != nil {
+				formats.PrintErrorEvent(e.Err)
+			}
+		}
+	})
+	rootCmd.Flags().StringVarP(&languageArg, "language", "l", "en", "languages for chapter downloads, in order of preference")
 	rootCmd.Flags().StringVarP(&rankArg, "rank", "r", "most", "chapter ranking method to use")
+	rootCmd.Flags().StringVarP(&fallbackLanguageArg, "fallback-language", "", "", "comma-separated languages used to fill missing chapters")
 	rootCmd.Flags().BoolVarP(&autocropArg, "autocrop", "a", false, "crop whitespace from pages automatically")
+	rootCmd.Flags().BoolVarP(&einkOptimizeArg, "eink-optimize", "", false, "convert pages to 16 gray levels tuned for e-ink screens")
 	rootCmd.Flags().BoolVarP(&kindleFolderModeArg, "kindle-folder-mode", "k", false, "generate folder structure for Kindle devices")
 	rootCmd.Flags().BoolVarP(&leftToRightArg, "left-to-right", "p", false, "make reading direction left to right")
 	rootCmd.Flags().IntVarP(&fillVolumeNumberArg, "fill-volume-number", "n", 0, "fill volume number with leading zeros in title")
+	rootCmd.Flags().BoolVarP(&interactiveArg, "interactive", "i", false, "select volumes and chapters interactively")
 	rootCmd.Flags().BoolVarP(&dryRunArg, "dry-run", "d", false, "disable writing of any files")
+	rootCmd.Flags().StringVarP(&fromFileArg, "from-file", "", "", "read identifiers from this file, or stdin if -")
+	rootCmd.Flags().BoolVarP(&followedArg, "followed", "", false, "download all manga followed by your MangaDex account")
+	rootCmd.Flags().BoolVarP(&updateArg, "update", "", false, "update every series in the output directory")
+	rootCmd.Flags().StringVarP(&formatArg, "format", "", "azw3", "comma-separated output formats")
 	rootCmd.Flags().StringVarP(&outArg, "out", "o", "", "output directory")
 	rootCmd.Flags().BoolVarP(&forceArg, "force", "f", false, "overwrite existing volumes")
+	rootCmd.Flags().BoolVarP(&waitArg, "wait", "w", false, "wait for other runs on the same output directory")
+	rootCmd.Flags().StringVarP(&setTitleArg, "set-title", "", "", "override title of generated e-books")
+	rootCmd.Flags().StringVarP(&setAuthorsArg, "set-authors", "", "", "override comma-separated authors of generated e-books")
+	rootCmd.Flags().StringVarP(&setSeriesArg, "set-series", "", "", "override series name used for metadata and the output directory")
+	rootCmd.Flags().StringVarP(&setLanguageArg, "set-language", "", "", "override language of generated e-books")
+	addPDFFlags(rootCmd.Flags())
+	addScaleFlags(rootCmd.Flags())
+	addImageFlags(rootCmd.Flags())
+	addFilenameFlags(rootCmd.Flags())
+	addHookFlags(rootCmd.Flags())
 	rootCmd.Flags().StringVarP(&diskArg, "disk", "D", "", "load additional content from disk")
+	rootCmd.Flags().StringVarP(&noVolumeArg, "no-volume", "", "special", "chapters without a volume go to special, last or group[:N]")
+	rootCmd.Flags().BoolVarP(&dataSaverArg, "data-saver", "", false, "download compressed pages to save bandwidth")
+	rootCmd.Flags().BoolVarP(&strictArg, "strict", "", false, "fail volumes if any of their chapters cannot be downloaded")
+	rootCmd.Flags().IntVarP(&volumeJobsArg, "volume-jobs", "", 0, "volumes processed and written at once, or 0 for automatic")
+	rootCmd.Flags().BoolVarP(&mangaupdatesArg, "mangaupdates", "", false, "enrich metadata using MangaUpdates")
+	rootCmd.PersistentFlags().BoolVarP(&verboseArg, "verbose", "v", false, "print details about every step")
+	rootCmd.PersistentFlags().BoolVarP(&quietArg, "quiet", "q", false, "only print errors and a final result line")
+	rootCmd.PersistentFlags().StringVarP(&logLevelArg, "log-level", "", "info", "one of error, warning, info or debug")
+	rootCmd.PersistentFlags().StringVarP(&progressArg, "progress", "", "bar", "progress output, one of bar or json")
+	rootCmd.PersistentFlags().BoolVarP(&noColorArg, "no-color", "", false, "disable colored output")
+	rootCmd.PersistentFlags().StringVarP(&logFileArg, "log-file", "", "", "append detailed logs to this file, or stderr if -")
+	rootCmd.PersistentFlags().StringVarP(&logFormatArg, "log-format", "", "text", "log file format, one of text or json")
+	rootCmd.PersistentFlags().StringVarP(&notifyArg, "notify", "", "", "notify on completion using bell, title or desktop")
+	rootCmd.PersistentFlags().StringVarP(&metricsListenArg, "metrics-listen", "", "", "serve Prometheus metrics on this address")
+	rootCmd.PersistentFlags().StringVarP(&pprofArg, "pprof", "", "", "serve runtime profiles on this address, e.g. :6060")
+	rootCmd.PersistentFlags().StringVarP(&pluginsArg, "plugins", "", "", "comma-separated plugin executables to load")
+	rootCmd.PersistentFlags().StringVarP(&maxMemoryArg, "max-memory", "", "", "limit memory use to this size, e.g. 1G, by downloading fewer pages at once")
+	rootCmd.PersistentFlags().IntVarP(&connectionsArg, "connections", "", download.DefaultTransportOptions().MaxConnsPerHost, "maximum connections to each server, or 0 for no limit")
+	rootCmd.PersistentFlags().IntVarP(&chapterJobsArg, "chapter-jobs", "", download.DefaultChapterJobs, "chapters whose pages are listed at once")
+	rootCmd.PersistentFlags().IntVarP(&imageJobsArg, "image-jobs", "", download.DefaultImageJobs, "pages downloaded at once")
+	rootCmd.PersistentFlags().IntVarP(&pageRetriesArg, "page-retries", "", download.DefaultPageRetries, "how often corrupted pages are downloaded again")
+	rootCmd.PersistentFlags().BoolVarP(&http1Arg, "http1", "", false, "disable HTTP/2 for all requests")
+	rootCmd.PersistentFlags().StringVarP(&cacheArg, "cache", "", "user", "cache pages in this directory, user, memory or an s3:// bucket")
+	rootCmd.PersistentFlags().StringVarP(&cacheMaxSizeArg, "cache-max-size", "", "2G", "prune cache directories to this size before every run, or 0 for no limit")
+	rootCmd.PersistentFlags().BoolVarP(&noCacheArg, "no-cache", "", false, "disable the page cache")
+	rootCmd.PersistentFlags().BoolVarP(&jsonArg, "json", "j", false, "print results as JSON")
+	rootCmd.PersistentFlags().StringVarP(&configArg, "config", "", "", "read default options from this file")
+	rootCmd.Flags().StringVarP(&savePlanArg, "save-plan", "", "", "write the planned volumes and chapters to this file, or stdout if -")
+	rootCmd.Flags().StringVarP(&planArg, "plan", "", "", "only build the chapters of a plan written by --save-plan")
 	rootCmd.Flags().StringVarP(&cpuprofileArg, "cpuprofile", "", "", "write CPU profile to this file")
 	rootCmd.Flags().StringVarP(&volumesFilter, "volumes", "V", "", "volume identifiers for chapter downloads")
 	rootCmd.Flags().StringVarP(&chaptersFilter, "chapters", "C", "", "chapter identifiers for chapter downloads")
 	rootCmd.Flags().StringVarP(&groupsFilter, "groups", "G", "", "scantlation groups for chapter downloads")
+	rootCmd.Flags().StringVarP(&sinceFilter, "since", "", "", "only chapters readable since this date or duration")
+	rootCmd.Flags().StringVarP(&untilFilter, "until", "", "", "only chapters readable until this date or duration")
+	rootCmd.Flags().StringVarP(&expressionFilter, "filter", "", "", "expression for complex chapter selections")
+	rootCmd.Flags().StringVarP(&preferGroupArg, "prefer-group", "P", "", "prefer uploads by these comma-separated groups, in order")
 	rootCmd.Flags().BoolVarP(&helpRankingFlag, "help-ranking", "R", false, "Help for chapter ranking")
 	rootCmd.Flags().BoolVarP(&helpFilterFlag, "help-filter", "F", false, "Help for chapter filtering")
 	rootCmd.Flags().SortFlags = false
+	markFilters(rootCmd.Flags(), "volumes", "chapters", "groups", "since", "until", "filter")
 	rootCmd.Flags().MarkHidden("cpuprofile") //nolint:errcheck
 	rootCmd.MarkFlagRequired("language")     //nolint:errcheck
+	manifestFlags = rootCmd.Flags()
+	registerCompletions()
 	rootCmd.SetHelpFunc(help)
 	rootCmd.SetUsageFunc(usage)
 	rootCmd.ParseFlags(os.Args) //nolint:errcheck
@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"github.com/leotaku/kojirou/cmd/formats/kindle"
+	"github.com/spf13/pflag"
+)
+
+var (
+	filenameReservedArg    string
+	filenameReplacementArg string
+	filenameMaxLengthArg   int
+	filenameASCIIArg       bool
+)
+
+func sanitizer() kindle.Sanitizer {
+	return kindle.Sanitizer{
+		Reserved:    filenameReservedArg,
+		Replacement: filenameReplacementArg,
+		MaxLength:   filenameMaxLengthArg,
+		ASCII:       filenameASCIIArg,
+	}
+}
+
+func addFilenameFlags(flags *pflag.FlagSet) {
+	flags.StringVarP(&filenameReservedArg, "filename-reserved", "", "auto", "reserved characters, one of auto, windows, darwin, posix or portable")
+	flags.StringVarP(&filenameReplacementArg, "filename-replacement", "", "", "replacement for reserved characters in filenames")
+	flags.IntVarP(&filenameMaxLengthArg, "filename-max-length", "", 0, "maximum length of filenames in bytes")
+	flags.BoolVarP(&filenameASCIIArg, "filename-ascii", "", false, "transliterate filenames to ASCII")
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/leotaku/kojirou/cmd/formats"
+	"github.com/leotaku/kojirou/cmd/hooks"
+	"github.com/leotaku/kojirou/cmd/scale"
+	md "github.com/leotaku/kojirou/mangadex"
+	"github.com/spf13/pflag"
+)
+
+var (
+	resizeArg         string
+	resizeFilterArg   string
+	upscaleCommandArg string
+)
+
+func addScaleFlags(flags *pflag.FlagSet) {
+	flags.StringVarP(&resizeArg, "resize", "", "", "scale pages to fit this resolution, e.g. scribe or 1860x2480")
+	flags.StringVarP(&resizeFilterArg, "resize-filter", "", "lanczos", "filter used to scale pages (bilinear, bicubic, lanczos)")
+	flags.StringVarP(&upscaleCommandArg, "upscale-command", "", "", "run this command to upscale pages smaller than the resolution")
+}
+
+func validateScaleFlags() error {
+	if _, err := scale.ParseResolution(resizeArg); resizeArg != "" && err != nil {
+		return fmt.Errorf("resize: %w", err)
+	} else if _, err := scale.ParseFilter(resizeFilterArg); err != nil {
+		return fmt.Errorf("resize-filter: %w", err)
+	} else if upscaleCommandArg != "" && resizeArg == "" {
+		return fmt.Errorf("upscale-command: requires --resize")
+	}
+
+	return nil
+}
+
+// scalePages scales pages to fit the requested resolution.  Pages that
+// are too small are first passed to the upscale command, if any, whose
+// result is then scaled to fit as well.
+func scalePages(ctx context.Context, t hooks.Target, pages md.ImageList, p formats.CliProgress) error {
+	target, _ := scale.ParseResolution(resizeArg)
+	filter, _ := scale.ParseFilter(resizeFilterArg)
+	p.Increase(len(pages))
+
+	for i, page := range pages {
+		if upscaleCommandArg != "" {
+			if err := hooks.UpscaleCommand(upscaleCommandArg, target)(ctx, t, &pages[i]); err != nil {
+				p.Cancel("Error")
+				return fmt.Errorf("chapter %v: page %v: %w", page.ChapterIdentifier, page.ImageIdentifier, err)
+			}
+		}
+		size := scale.Fit(pages[i].Image.Bounds().Size(), target)
+		if scaled, err := scale.Scale(pages[i].Image, size, filter); err != nil {
+			p.Cancel("Error")
+			return fmt.Errorf("chapter %v: page %v: %w", page.ChapterIdentifier, page.ImageIdentifier, err)
+		} else {
+			pages[i].Image = scaled
+			p.Add(1)
+		}
+	}
+	p.Done()
+
+	return nil
+}
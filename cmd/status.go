@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/leotaku/kojirou/cmd/formats"
+	md "github.com/leotaku/kojirou/mangadex"
+)
+
+// Exit statuses of the program.  A run succeeds partially if some
+// volumes or manga failed, while others were built.
+const (
+	exitSuccess = 0
+	exitFailure = 1
+	exitPartial = 2
+)
+
+// partialError is returned by runs that succeeded partially.  It wraps
+// the errors of all failed volumes, if any, so that hints still apply.
+type partialError struct {
+	message string
+	err     error
+}
+
+func (e *partialError) Error() string {
+	if e.err == nil {
+		return e.message
+	}
+
+	return fmt.Sprintf("%v: %v", e.message, e.err)
+}
+
+func (e *partialError) Unwrap() error {
+	return e.err
+}
+
+func isPartial(err error) bool {
+	var partial *partialError
+	return errors.As(err, &partial)
+}
+
+// exitCode returns the exit status for the error returned by a command.
+func exitCode(err error) int {
+	switch {
+	case err == nil:
+		return exitSuccess
+	case isPartial(err):
+		return exitPartial
+	default:
+		return exitFailure
+	}
+}
+
+// failVolume marks the result of the volume as failed and records the
+// failure in the manifest, so that it can be retried later.
+func failVolume(dir string, manga md.Manga, volume md.Volume, result *formats.VolumeResult, err error) error {
+	err = fmt.Errorf("volume %v: %w", volume.Info.Identifier, err)
+	if result.Volume == "" {
+		result.Volume = volume.Info.Identifier.String()
+		result.Chapters = len(volume.Chapters)
+	}
+	result.Status = formats.VolumeFailed
+	result.Error = err.Error()
+	formats.Report("Failed", "Volume %v: %v", volume.Info.Identifier, errors.Unwrap(err))
+	if result.Filename != "" {
+		if err := recordFailure(dir, manga.Info.Title, result.Filename, err); err != nil {
+			formats.Debug("Recording failure failed", "volume", volume.Info.Identifier, "error", err)
+		}
+	}
+
+	return err
+}
+
+// volumesError returns the error for a run in which the given volumes
+// failed, which is partial if any volume did not fail.
+func volumesError(errs []error, total int) error {
+	switch {
+	case len(errs) == 0:
+		return nil
+	case len(errs) == total:
+		return errors.Join(errs...)
+	default:
+		message := fmt.Sprintf("%v of %v volumes failed", len(errs), total)
+		return &partialError{message: message, err: errors.Join(errs...)}
+	}
+}
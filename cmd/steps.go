@@ -0,0 +1,180 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/leotaku/kojirou/cmd/eink"
+	"github.com/leotaku/kojirou/cmd/events"
+	"github.com/leotaku/kojirou/cmd/formats"
+	"github.com/leotaku/kojirou/cmd/formats/kindle"
+	"github.com/leotaku/kojirou/cmd/hooks"
+	"github.com/leotaku/kojirou/cmd/pipeline"
+	md "github.com/leotaku/kojirou/mangadex"
+)
+
+// volumePipeline returns the steps that build a volume from the given
+// sources and record it in the manifest of the directory.
+func volumePipeline(sources []formats.Provider, dir kindle.NormalizedDirectory, writers []formats.FormatWriter) pipeline.Pipeline {
+	return pipeline.New(
+		fetchStep(sources),
+		processStep(dir),
+		writeStep(dir, writers),
+		deliverStep(dir, writers, true),
+	)
+}
+
+// fetchStep downloads the cover and pages of the volume.  Covers are
+// only fetched for the volume being built, so that memory use does not
+// grow with the number of volumes in a series.  Pages of chapters that
+// were processed by an earlier run are reused instead.
+func fetchStep(sources []formats.Provider) pipeline.Step {
+	return pipeline.NewStep(pipeline.Fetch, func(ctx context.Context, job *pipeline.Job) error {
+		identifier := job.Volume.Info.Identifier
+		single := md.Manga{
+			Info:    job.Manga.Info,
+			Volumes: map[md.Identifier]md.Volume{identifier: job.Volume},
+		}
+		covers, err := getCovers(ctx, sources, single)
+		if err != nil {
+			return fmt.Errorf("covers: %w", err)
+		}
+		job.Manga = single.WithCovers(covers)
+		job.Volume = job.Manga.Volumes[identifier]
+
+		reused, remaining := reuseProcessed(ctx, job.Volume)
+		pages, err := getPages(ctx, sources, remaining, job.Progress)
+		if err != nil {
+			return fmt.Errorf("pages: %w", err)
+		} else if len(pages)+len(reused) == 0 && len(job.Volume.Chapters) > 0 {
+			return fmt.Errorf("pages: %w", md.ErrChapterUnavailable)
+		}
+		formats.Debug("Fetched pages", "volume", job.Volume.Info.Identifier, "pages", len(pages), "reused", len(reused))
+		reportMissing(job.Volume, append(append(md.ImageList{}, pages...), reused...))
+		job.Pages = pages
+		job.Reused = reused
+
+		return nil
+	})
+}
+
+// processStep runs the pre-volume hooks and modifies pages using page
+// hooks, cropping, scaling and e-ink optimization.  Reused pages are
+// only added once all other pages have been processed.
+func processStep(dir kindle.NormalizedDirectory) pipeline.Step {
+	return pipeline.NewStep(pipeline.Process, func(ctx context.Context, job *pipeline.Job) error {
+		h := activeHooks()
+		target := hookTarget(job, dir)
+		if err := hooks.RunVolume(ctx, h.PreVolume, target, nil); err != nil {
+			return fmt.Errorf("hook: %w", err)
+		}
+		volume := withoutPages(job.Volume, job.Reused)
+		if err := runPageHooks(ctx, target, volume, job.Pages, h.PreChapter, h.PreImage, false); err != nil {
+			return fmt.Errorf("hook: %w", err)
+		}
+
+		if autocropArg {
+			if err := autoCrop(job.Pages, stepProgress(job, "Cropping..")); err != nil {
+				return fmt.Errorf("autocrop: %w", err)
+			}
+		}
+
+		if resizeArg != "" {
+			if err := scalePages(ctx, target, job.Pages, stepProgress(job, "Scaling..")); err != nil {
+				return fmt.Errorf("resize: %w", err)
+			}
+		}
+
+		if einkOptimizeArg {
+			if err := filterPages(job.Pages, stepProgress(job, "Optimizing.."), eink.Optimize); err != nil {
+				return fmt.Errorf("eink-optimize: %w", err)
+			}
+		}
+
+		if err := runPageHooks(ctx, target, volume, job.Pages, h.PostChapter, h.PostImage, true); err != nil {
+			return fmt.Errorf("hook: %w", err)
+		}
+
+		if err := storeProcessed(ctx, volume, job.Pages); err != nil {
+			formats.Debug("Storing processed pages failed", "volume", volume.Info.Identifier, "error", err)
+		}
+		job.Pages = append(job.Pages, job.Reused...)
+		job.Reused = nil
+
+		return nil
+	})
+}
+
+func writeStep(dir kindle.NormalizedDirectory, writers []formats.FormatWriter) pipeline.Step {
+	return pipeline.NewStep(pipeline.Write, func(ctx context.Context, job *pipeline.Job) error {
+		identifier := job.Volume.Info.Identifier
+		for _, w := range writers {
+			p := stepProgress(job, "Writing...")
+			if err := feedWriter(w, job.Manga, job.Volume, job.Pages); err != nil {
+				formats.Abort(w)
+				p.Cancel("Error")
+				return fmt.Errorf("write: %w", err)
+			}
+			if err := dir.Write(identifier, w, p); err != nil {
+				formats.Abort(w)
+				p.Cancel("Error")
+				return fmt.Errorf("write: %w", err)
+			}
+			p.Done()
+			filename := filepath.Join(dir.Directory(), dir.Filename(identifier, w.Extension()))
+			formats.Debug("Wrote volume", "volume", identifier, "file", filename, "bytes", fileSize(filename))
+			job.Files = append(job.Files, filename)
+		}
+
+		return nil
+	})
+}
+
+// deliverStep runs the post-volume hooks and, if record is set, adds
+// the written files to the manifest of the directory.
+func deliverStep(dir kindle.NormalizedDirectory, writers []formats.FormatWriter, record bool) pipeline.Step {
+	return pipeline.NewStep(pipeline.Deliver, func(ctx context.Context, job *pipeline.Job) error {
+		if err := hooks.RunVolume(ctx, activeHooks().PostVolume, hookTarget(job, dir), job.Files); err != nil {
+			return fmt.Errorf("hook: %w", err)
+		}
+
+		identifier := job.Volume.Info.Identifier
+		for _, w := range writers {
+			if !record {
+				break
+			}
+			filename := dir.Filename(identifier, w.Extension())
+			if err := recordVolume(dir.Directory(), job.Manga.Info.Title, filename, job.Volume); err != nil {
+				return fmt.Errorf("manifest: %w", err)
+			}
+		}
+		events.Publish(events.VolumeBuilt{
+			Manga:    job.Manga.Info,
+			Volume:   identifier,
+			Pages:    len(job.Pages),
+			Duration: time.Since(job.Started),
+		})
+
+		return nil
+	})
+}
+
+// stepProgress returns the progress of a step, which is only drawn for
+// jobs in the foreground.
+func stepProgress(job *pipeline.Job, title string) formats.CliProgress {
+	if job.Background {
+		return formats.BackgroundProgress(title)
+	}
+
+	return formats.VanishingProgress(title)
+}
+
+func hookTarget(job *pipeline.Job, dir kindle.NormalizedDirectory) hooks.Target {
+	return hooks.Target{
+		Manga:     job.Manga.Info,
+		Volume:    job.Volume.Info.Identifier,
+		Directory: dir.Directory(),
+	}
+}
@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/leotaku/kojirou/cmd/formats"
+	"github.com/leotaku/kojirou/cmd/tracker"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var (
+	trackerClientIDArg     string
+	trackerUsernameArg     string
+	trackerChaptersReadArg int
+	trackerVolumesReadArg  int
+	trackerListStatusArg   string
+)
+
+var trackerCmd = &cobra.Command{
+	Use:   "tracker",
+	Short: "Synchronize progress with MyAnimeList or Kitsu",
+}
+
+var trackerLoginCmd = &cobra.Command{
+	Use:       "login [flags..] <mal|kitsu>",
+	Short:     "Authenticate with a tracker",
+	Args:      cobra.ExactValidArgs(1),
+	ValidArgs: []string{"mal", "kitsu"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return trackerLogin(cmd.Context(), args[0])
+	},
+}
+
+var trackerProgressCmd = &cobra.Command{
+	Use:   "progress [flags..] <mal|kitsu> <identifier>",
+	Short: "Update reading progress on a tracker",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return trackerProgress(cmd.Context(), args[0], args[1])
+	},
+}
+
+func trackerLogin(ctx context.Context, name string) error {
+	switch name {
+	case "mal":
+		if trackerClientIDArg == "" {
+			return fmt.Errorf("mal: client ID is required")
+		}
+		return tracker.LoginMyAnimeList(ctx, trackerClientIDArg, os.Stdin, os.Stdout)
+	case "kitsu":
+		if trackerUsernameArg == "" {
+			return fmt.Errorf("kitsu: username is required")
+		}
+		password, err := readPassword("Password: ")
+		if err != nil {
+			return fmt.Errorf("read: %w", err)
+		}
+		return tracker.LoginKitsu(ctx, trackerUsernameArg, password)
+	default:
+		return fmt.Errorf("unsupported tracker: %v", name)
+	}
+}
+
+func trackerProgress(ctx context.Context, name, identifier string) error {
+	id, err := trackerID(ctx, name, identifier)
+	if err != nil {
+		return err
+	}
+	t, err := tracker.Load(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	progress, err := t.UpdateProgress(ctx, id, tracker.Progress{
+		Status:   trackerListStatusArg,
+		Chapters: trackerChaptersReadArg,
+		Volumes:  trackerVolumesReadArg,
+	})
+	if err != nil {
+		return fmt.Errorf("update: %w", err)
+	}
+
+	if jsonArg {
+		return formats.PrintJSON(progress)
+	}
+
+	formats.PrintValue("Status", progress.Status)
+	formats.PrintValue("Volumes", progress.Volumes)
+	formats.PrintValue("Chapters", progress.Chapters)
+
+	return nil
+}
+
+// trackerID returns the ID of the manga on the named tracker, which
+// does not require logging in to the tracker.
+func trackerID(ctx context.Context, name, identifier string) (string, error) {
+	if _, id, ok := tracker.Parse(identifier); ok {
+		return id, nil
+	}
+
+	t, err := tracker.Lookup(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	mangaID, err := resolveIdentifier(ctx, identifier)
+	if err != nil {
+		return "", err
+	}
+	manga, err := getDownloader().MangadexSkeleton(ctx, mangaID)
+	if err != nil {
+		return "", fmt.Errorf("mangadex: %w", err)
+	}
+	if link, ok := manga.Info.Links[t.Site()]; !ok {
+		return "", fmt.Errorf("mangadex: no %v link for %v", t.Site(), mangaID)
+	} else {
+		return link, nil
+	}
+}
+
+// readPassword prompts for a password, which is not echoed if standard
+// input is a terminal.
+func readPassword(prompt string) (string, error) {
+	fmt.Print(prompt)
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		password, err := term.ReadPassword(fd)
+		fmt.Println()
+		return string(password), err
+	}
+
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && !(errors.Is(err, io.EOF) && password != "") {
+		return "", err
+	}
+
+	return strings.TrimSpace(password), nil
+}
+
+func init() {
+	trackerLoginCmd.Flags().StringVarP(&trackerClientIDArg, "client-id", "c", "", "client ID of your MyAnimeList API application")
+	trackerLoginCmd.Flags().StringVarP(&trackerUsernameArg, "username", "u", "", "username or email for Kitsu")
+	trackerProgressCmd.Flags().IntVarP(&trackerChaptersReadArg, "chapters-read", "", 0, "number of chapters read")
+	trackerProgressCmd.Flags().IntVarP(&trackerVolumesReadArg, "volumes-read", "", 0, "number of volumes read")
+	trackerProgressCmd.Flags().StringVarP(&trackerListStatusArg, "list-status", "", "", "one of reading, completed, on_hold, dropped or planned")
+	trackerProgressCmd.Flags().SortFlags = false
+	trackerCmd.AddCommand(trackerLoginCmd, trackerProgressCmd)
+	rootCmd.AddCommand(trackerCmd)
+}
@@ -0,0 +1,116 @@
+package tracker
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/leotaku/kojirou/kitsu"
+)
+
+var kitsuStatus = map[string]string{
+	StatusReading:   "current",
+	StatusCompleted: "completed",
+	StatusOnHold:    "on_hold",
+	StatusDropped:   "dropped",
+	StatusPlanned:   "planned",
+}
+
+type kitsuTracker struct {
+	client *kitsu.Client
+}
+
+type kitsuCredentials struct {
+	Token *kitsu.Token
+}
+
+func LoginKitsu(ctx context.Context, username, password string) error {
+	token, err := kitsu.NewClient().Login(ctx, username, password)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+
+	return saveCredentials("kitsu", kitsuCredentials{token})
+}
+
+func loadKitsu(ctx context.Context) (*kitsuTracker, error) {
+	creds := kitsuCredentials{}
+	if err := loadCredentials("kitsu", &creds); err != nil {
+		return nil, err
+	}
+
+	client := kitsu.NewClient()
+	if creds.Token != nil && creds.Token.Expired() {
+		token, err := client.Refresh(ctx, creds.Token)
+		if err != nil {
+			return nil, fmt.Errorf("refresh: %w", err)
+		}
+		creds.Token = token
+		if err := saveCredentials("kitsu", creds); err != nil {
+			return nil, err
+		}
+	}
+
+	return &kitsuTracker{client.WithToken(creds.Token)}, nil
+}
+
+// lookupKitsu returns the logged in tracker or an anonymous tracker,
+// which can look up manga but not update progress.
+func lookupKitsu(ctx context.Context) (*kitsuTracker, error) {
+	if t, err := loadKitsu(ctx); err == nil {
+		return t, nil
+	}
+
+	return &kitsuTracker{kitsu.NewClient()}, nil
+}
+
+func (t *kitsuTracker) Site() string {
+	return "kt"
+}
+
+func (t *kitsuTracker) Titles(ctx context.Context, id string) ([]string, error) {
+	manga, err := t.client.GetManga(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return manga.Data.AllTitles(), nil
+}
+
+// UpdateProgress updates the library entry for the given manga.  Kitsu
+// does not track read volumes, so only chapters and status are synced.
+func (t *kitsuTracker) UpdateProgress(ctx context.Context, id string, progress Progress) (*Progress, error) {
+	manga, err := t.client.GetManga(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("manga: %w", err)
+	}
+	user, err := t.client.GetSelf(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("user: %w", err)
+	}
+	entries, err := t.client.GetLibraryEntries(ctx, user.ID, manga.Data.ID)
+	if err != nil {
+		return nil, fmt.Errorf("library: %w", err)
+	}
+
+	attrs := kitsu.LibraryEntryAttributes{
+		Status:   kitsuStatus[progress.Status],
+		Progress: progress.Chapters,
+	}
+	entry := new(kitsu.LibraryEntry)
+	if len(entries.Data) == 0 {
+		if attrs.Status == "" {
+			attrs.Status = kitsuStatus[StatusReading]
+		}
+		entry, err = t.client.PostLibraryEntry(ctx, user.ID, manga.Data.ID, attrs)
+	} else {
+		entry, err = t.client.PatchLibraryEntry(ctx, entries.Data[0].ID, attrs)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("library: %w", err)
+	}
+
+	return &Progress{
+		Status:   entry.Data.Attributes.Status,
+		Chapters: entry.Data.Attributes.Progress,
+	}, nil
+}
@@ -0,0 +1,154 @@
+package tracker
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	mal "github.com/leotaku/kojirou/myanimelist"
+)
+
+var malStatus = map[string]string{
+	StatusReading:   "reading",
+	StatusCompleted: "completed",
+	StatusOnHold:    "on_hold",
+	StatusDropped:   "dropped",
+	StatusPlanned:   "plan_to_read",
+}
+
+type myAnimeList struct {
+	client *mal.Client
+}
+
+type malCredentials struct {
+	ClientID string
+	Token    *mal.Token
+}
+
+func LoginMyAnimeList(ctx context.Context, clientID string, in io.Reader, out io.Writer) error {
+	verifier, err := randomString(64)
+	if err != nil {
+		return fmt.Errorf("verifier: %w", err)
+	}
+	state, err := randomString(16)
+	if err != nil {
+		return fmt.Errorf("state: %w", err)
+	}
+
+	client := mal.NewClient(clientID)
+	fmt.Fprintf(out, "Visit the following URL to authorize Kojirou:\n\n  %v\n\n", client.AuthorizeURL(verifier, state))
+	fmt.Fprintf(out, "Paste the URL you were redirected to: ")
+	line, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("read: %w", err)
+	}
+
+	code := strings.TrimSpace(line)
+	if redirect, err := url.Parse(code); err == nil && redirect.Query().Get("code") != "" {
+		if redirect.Query().Get("state") != state {
+			return fmt.Errorf("state mismatch")
+		}
+		code = redirect.Query().Get("code")
+	}
+
+	token, err := client.Exchange(ctx, code, verifier)
+	if err != nil {
+		return fmt.Errorf("exchange: %w", err)
+	}
+
+	return saveCredentials("mal", malCredentials{
+		ClientID: clientID,
+		Token:    token,
+	})
+}
+
+func loadMyAnimeList(ctx context.Context) (*myAnimeList, error) {
+	creds := malCredentials{}
+	if err := loadCredentials("mal", &creds); err != nil {
+		return nil, err
+	}
+
+	client := mal.NewClient(creds.ClientID)
+	if creds.Token != nil && creds.Token.Expired() {
+		token, err := client.Refresh(ctx, creds.Token)
+		if err != nil {
+			return nil, fmt.Errorf("refresh: %w", err)
+		}
+		creds.Token = token
+		if err := saveCredentials("mal", creds); err != nil {
+			return nil, err
+		}
+	}
+
+	return &myAnimeList{client.WithToken(creds.Token)}, nil
+}
+
+// MALClientIDEnv is the environment variable that provides the client
+// ID used to look up manga on MyAnimeList without logging in.
+const MALClientIDEnv = "KOJIROU_MAL_CLIENT_ID"
+
+// lookupMyAnimeList returns the logged in tracker, or a tracker that
+// identifies itself using the client ID from MALClientIDEnv.
+func lookupMyAnimeList(ctx context.Context) (*myAnimeList, error) {
+	if t, err := loadMyAnimeList(ctx); err == nil {
+		return t, nil
+	} else if clientID := os.Getenv(MALClientIDEnv); clientID != "" {
+		return &myAnimeList{mal.NewClient(clientID)}, nil
+	} else {
+		return nil, fmt.Errorf("%w, or set %v", err, MALClientIDEnv)
+	}
+}
+
+func (t *myAnimeList) Site() string {
+	return "mal"
+}
+
+func (t *myAnimeList) Titles(ctx context.Context, id string) ([]string, error) {
+	malID, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, fmt.Errorf("id: %w", err)
+	}
+	manga, err := t.client.GetManga(ctx, malID)
+	if err != nil {
+		return nil, err
+	}
+
+	return manga.Titles(), nil
+}
+
+func (t *myAnimeList) UpdateProgress(ctx context.Context, id string, progress Progress) (*Progress, error) {
+	malID, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, fmt.Errorf("id: %w", err)
+	}
+	status, err := t.client.UpdateListStatus(ctx, malID, mal.ListStatus{
+		Status:          malStatus[progress.Status],
+		NumChaptersRead: progress.Chapters,
+		NumVolumesRead:  progress.Volumes,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Progress{
+		Status:   status.Status,
+		Chapters: status.NumChaptersRead,
+		Volumes:  status.NumVolumesRead,
+	}, nil
+}
+
+func randomString(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buf)[:n], nil
+}
@@ -0,0 +1,120 @@
+package tracker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+const (
+	StatusReading   = "reading"
+	StatusCompleted = "completed"
+	StatusOnHold    = "on_hold"
+	StatusDropped   = "dropped"
+	StatusPlanned   = "planned"
+)
+
+var patterns = map[string][]*regexp.Regexp{
+	"mal": {
+		regexp.MustCompile(`^https?://(?:www\.)?myanimelist\.net/manga/([0-9]+)`),
+		regexp.MustCompile(`^mal:([0-9]+)$`),
+	},
+	"kitsu": {
+		regexp.MustCompile(`^https?://(?:www\.)?kitsu\.(?:io|app)/manga/([^/?#]+)`),
+		regexp.MustCompile(`^kitsu:([^/?#]+)$`),
+	},
+}
+
+type Progress struct {
+	Status   string
+	Chapters int
+	Volumes  int
+}
+
+type Tracker interface {
+	// Site returns the key under which MangaDex links to this tracker.
+	Site() string
+	Titles(ctx context.Context, id string) ([]string, error)
+	UpdateProgress(ctx context.Context, id string, progress Progress) (*Progress, error)
+}
+
+func Load(ctx context.Context, name string) (Tracker, error) {
+	switch name {
+	case "mal", "myanimelist":
+		return loadMyAnimeList(ctx)
+	case "kitsu":
+		return loadKitsu(ctx)
+	default:
+		return nil, fmt.Errorf("unsupported tracker: %v", name)
+	}
+}
+
+// Lookup returns the named tracker for looking up manga, which does not
+// require logging in.  The stored login is used if there is one.
+// MyAnimeList requires a client ID otherwise, which is read from the
+// MALClientIDEnv environment variable.
+func Lookup(ctx context.Context, name string) (Tracker, error) {
+	switch name {
+	case "mal", "myanimelist":
+		return lookupMyAnimeList(ctx)
+	case "kitsu":
+		return lookupKitsu(ctx)
+	default:
+		return nil, fmt.Errorf("unsupported tracker: %v", name)
+	}
+}
+
+// Parse extracts the tracker name and tracker-specific ID from either a
+// tracker URL or an identifier of the form "name:ID".
+func Parse(identifier string) (name, id string, ok bool) {
+	for name, ps := range patterns {
+		for _, p := range ps {
+			if m := p.FindStringSubmatch(identifier); m != nil {
+				return name, m[1], true
+			}
+		}
+	}
+
+	return "", "", false
+}
+
+func loadCredentials(name string, v interface{}) error {
+	filename, err := credentialsPath(name)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf(`not logged in, run "kojirou tracker login %v": %w`, name, err)
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+func saveCredentials(name string, v interface{}) error {
+	filename, err := credentialsPath(name)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0o700); err != nil {
+		return fmt.Errorf("directory: %w", err)
+	}
+
+	return os.WriteFile(filename, data, 0o600)
+}
+
+func credentialsPath(name string) (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("config: %w", err)
+	}
+
+	return filepath.Join(dir, "kojirou", name+".json"), nil
+}
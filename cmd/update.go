@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+)
+
+var updateArg bool
+
+// runUpdate updates every series below the library directory that has a
+// manifest, using the identifier and settings it was generated with.
+// Existing volumes are skipped unless chapters have been added to them,
+// so only new and changed volumes are downloaded and built.
+func runUpdate(ctx context.Context, root string) error {
+	directories := make([]string, 0)
+	manifests := make(map[string]*manifest)
+	err := filepath.WalkDir(root, func(pathname string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || d.Name() != manifestFilename {
+			return err
+		}
+		directory := filepath.ToSlash(filepath.Dir(pathname))
+		m, err := readManifest(directory)
+		if err != nil {
+			return fmt.Errorf("manifest '%v': %w", pathname, err)
+		} else if m.Identifier != "" {
+			directories = append(directories, directory)
+			manifests[directory] = m
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("library: %w", err)
+	} else if len(directories) == 0 {
+		return fmt.Errorf("no series found in '%v'", root)
+	}
+
+	identifiers := make([]string, 0)
+	for _, directory := range directories {
+		identifiers = append(identifiers, manifests[directory].Identifier)
+	}
+	prefetchBatch(ctx, identifiers)
+
+	defaults := defaultFlags(manifestFlags)
+	err = runEach(ctx, directories, func(directory string) error {
+		if err := applyManifestFlags(ctx, manifestFlags, defaults, manifests[directory]); err != nil {
+			return err
+		}
+		identifierArg = manifests[directory].Identifier
+		useManifestDirectory(directory, manifests[directory])
+		return run(ctx)
+	})
+	identifierArg = ""
+	manifestDirectory = ""
+
+	return err
+}
+
+// updateRoot returns the library directory, which is the output
+// directory or the current directory.
+func updateRoot() string {
+	if outArg != "" {
+		return outArg
+	}
+
+	return "."
+}
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/fatih/color"
+	"github.com/leotaku/kojirou/cmd/formats"
+	"github.com/leotaku/kojirou/cmd/formats/kindle"
+	"github.com/spf13/cobra"
+)
+
+var reportArg string
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify [flags..] <directory>",
+	Short: "Check generated e-books for missing or corrupted files",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		return verify(args[0])
+	},
+}
+
+var verifyColors = map[kindle.Status]*color.Color{
+	kindle.StatusOK:        color.New(color.FgGreen),
+	kindle.StatusMissing:   color.New(color.FgRed),
+	kindle.StatusMismatch:  color.New(color.FgRed),
+	kindle.StatusCorrupt:   color.New(color.FgRed),
+	kindle.StatusUntracked: color.New(color.FgYellow),
+}
+
+func verify(root string) error {
+	results, err := verifyLibrary(root)
+	if err != nil {
+		return err
+	}
+
+	if jsonArg {
+		if err := formats.PrintJSON(results); err != nil {
+			return err
+		}
+	}
+
+	problems := 0
+	for _, result := range results {
+		if result.Status != kindle.StatusOK {
+			problems++
+		}
+		if jsonArg {
+			continue
+		}
+
+		status := verifyColors[result.Status].Sprintf("%-10v", result.Status)
+		if result.Detail != "" {
+			fmt.Printf("%v %v (%v)\n", status, filepath.Join(result.Directory, result.Filename), result.Detail)
+		} else {
+			fmt.Printf("%v %v\n", status, filepath.Join(result.Directory, result.Filename))
+		}
+	}
+
+	if reportArg != "" {
+		data, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return fmt.Errorf("report: %w", err)
+		}
+		if err := os.WriteFile(reportArg, data, 0o644); err != nil {
+			return fmt.Errorf("report: %w", err)
+		}
+	}
+
+	switch {
+	case len(results) == 0:
+		return fmt.Errorf("no e-books found in '%v'", root)
+	case problems > 0 && reportArg != "":
+		return fmt.Errorf(`found %v problems, run "kojirou repair %v" to fix them`, problems, reportArg)
+	case problems > 0:
+		return fmt.Errorf("found %v problems, run again with --report to save them for repair", problems)
+	default:
+		return nil
+	}
+}
+
+func verifyLibrary(root string) ([]kindle.VerifyResult, error) {
+	directories := make([]string, 0)
+	err := filepath.WalkDir(root, func(pathname string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return err
+		}
+		if _, err := os.Stat(filepath.Join(pathname, kindle.ChecksumFilename)); err == nil {
+			directories = append(directories, filepath.ToSlash(pathname))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return kindle.Verify(directories...)
+}
+
+func init() {
+	verifyCmd.Flags().StringVarP(&reportArg, "report", "r", "", "write verification report to this file")
+	rootCmd.AddCommand(verifyCmd)
+}
@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	md "github.com/leotaku/kojirou/mangadex"
+)
+
+// noVolumeIdentifier is the volume of chapters that have not been
+// assigned to any volume.
+var noVolumeIdentifier = md.NewIdentifier("Special")
+
+// defaultGroupSize is the number of chapters in each volume created by
+// the "group" strategy.
+const defaultGroupSize = 10
+
+var noVolumeArg string
+
+// noVolumeStrategy decides what happens to chapters without a volume.
+type noVolumeStrategy struct {
+	name string
+	size int
+}
+
+func parseNoVolume(value string) (noVolumeStrategy, error) {
+	name, size, hasSize := strings.Cut(strings.TrimSpace(value), ":")
+	switch {
+	case name == "special" && !hasSize, name == "last" && !hasSize:
+		return noVolumeStrategy{name: name}, nil
+	case name == "group" && !hasSize:
+		return noVolumeStrategy{name: name, size: defaultGroupSize}, nil
+	case name == "group":
+		n, err := strconv.Atoi(size)
+		if err != nil || n < 1 {
+			return noVolumeStrategy{}, fmt.Errorf(`not a valid group size: "%v"`, size)
+		}
+		return noVolumeStrategy{name: name, size: n}, nil
+	default:
+		return noVolumeStrategy{}, fmt.Errorf(`not a valid no-volume strategy: "%v"`, value)
+	}
+}
+
+// assignNoVolume moves chapters without a volume according to the
+// strategy given by the "--no-volume" flag.  By default, they are kept
+// in a separate "Special" volume.
+func assignNoVolume(cl md.ChapterList) (md.ChapterList, error) {
+	strategy, err := parseNoVolume(noVolumeArg)
+	if err != nil || strategy.name == "special" {
+		return cl, err
+	}
+
+	ranges := volumeRanges(cl)
+	result := make(md.ChapterList, 0, len(cl))
+	for _, chapter := range cl {
+		if chapter.Info.VolumeIdentifier.Equal(noVolumeIdentifier) {
+			chapter.Info.VolumeIdentifier = strategy.assign(chapter.Info.Identifier, ranges)
+		}
+		result = append(result, chapter)
+	}
+
+	return result, nil
+}
+
+// assign returns the new volume of a chapter without a volume.
+// Chapters stay in the "Special" volume if there is no better choice.
+func (s noVolumeStrategy) assign(chapter md.Identifier, ranges []volumeRange) md.Identifier {
+	switch {
+	case chapter.IsSpecial():
+		return noVolumeIdentifier
+	case s.name == "last" && len(ranges) > 0:
+		return ranges[len(ranges)-1].volume
+	case s.name == "group":
+		for _, r := range ranges {
+			if r.first.LessOrEqual(chapter) && chapter.LessOrEqual(r.last) {
+				return r.volume
+			}
+		}
+		first := max(chapter.Whole()-1, 0)/s.size*s.size + 1
+		name := fmt.Sprintf("Chapters %v-%v", first, first+s.size-1)
+		return md.NewIdentifier(name)
+	default:
+		return noVolumeIdentifier
+	}
+}
+
+// volumeRange is the range of numbered chapters in a numbered volume.
+type volumeRange struct {
+	volume md.Identifier
+	first  md.Identifier
+	last   md.Identifier
+}
+
+// volumeRanges returns the chapter ranges of all numbered volumes,
+// ordered by volume.
+func volumeRanges(cl md.ChapterList) []volumeRange {
+	ranges := make([]volumeRange, 0)
+	indices := make(map[md.Identifier]int)
+	for _, chapter := range cl {
+		volume, id := chapter.Info.VolumeIdentifier, chapter.Info.Identifier
+		if volume.IsSpecial() || id.IsSpecial() {
+			continue
+		}
+		if i, ok := indices[volume]; !ok {
+			indices[volume] = len(ranges)
+			ranges = append(ranges, volumeRange{volume: volume, first: id, last: id})
+		} else if id.Less(ranges[i].first) {
+			ranges[i].first = id
+		} else if ranges[i].last.Less(id) {
+			ranges[i].last = id
+		}
+	}
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].volume.Less(ranges[j].volume)
+	})
+
+	return ranges
+}
@@ -0,0 +1,115 @@
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+	"sync"
+)
+
+// ErrTruncated is returned by DecodeBytes for data that ends before the
+// end of the image, such as interrupted downloads.
+var ErrTruncated = errors.New("image data is truncated")
+
+// Encoded is an image that keeps the data it was decoded from.  Writers
+// may copy the data instead of encoding the image again, which is
+// faster and preserves the original quality.  Processing that changes
+// an image returns a different image, so that only unmodified images
+// still carry their data.
+//
+// Pixels are only decoded once they are first needed, so images that
+// are copied unmodified are never decoded at all.  The size and color
+// model are known without decoding.
+type Encoded struct {
+	Format string
+	Data   []byte
+
+	config  image.Config
+	decode  func(io.Reader) (image.Image, error)
+	once    sync.Once
+	decoded image.Image
+	err     error
+}
+
+// DecodeBytes reads the header of an image in any enabled format,
+// keeping the given data as part of the result.  Data that is truncated
+// is rejected without decoding it.  Formats that cannot cheaply detect
+// truncated data are decoded once to validate it, discarding the
+// pixels.  Large data is moved to a temporary file, see MappedThreshold.
+func (r *Registry) DecodeBytes(data []byte) (image.Image, error) {
+	br := asPeeker(bytes.NewReader(data))
+	format, err := r.sniff(br)
+	if err != nil {
+		return nil, err
+	}
+	config, err := format.DecodeConfig(br)
+	if err != nil {
+		return nil, err
+	} else if format.Complete != nil && !format.Complete(data) {
+		return nil, ErrTruncated
+	} else if format.Complete == nil {
+		if _, err := format.Decode(bytes.NewReader(data)); err != nil {
+			return nil, err
+		}
+	}
+
+	return mapped(&Encoded{
+		Format: format.Name,
+		Data:   data,
+		config: config,
+		decode: format.Decode,
+	}), nil
+}
+
+func DecodeBytes(data []byte) (image.Image, error) {
+	return Default.DecodeBytes(data)
+}
+
+func (e *Encoded) ColorModel() color.Model {
+	return e.config.ColorModel
+}
+
+func (e *Encoded) Bounds() image.Rectangle {
+	return image.Rect(0, 0, e.config.Width, e.config.Height)
+}
+
+// At returns the color of a pixel, decoding the image on first use.
+// Images that fail to decode are white, use Decode to handle errors.
+func (e *Encoded) At(x, y int) color.Color {
+	if img, err := e.Decode(); err == nil {
+		return img.At(x, y)
+	}
+
+	return color.White
+}
+
+// Decode returns the decoded image, which is only decoded once.
+func (e *Encoded) Decode() (image.Image, error) {
+	e.once.Do(func() {
+		e.decoded, e.err = e.decode(bytes.NewReader(e.Data))
+	})
+
+	return e.decoded, e.err
+}
+
+// Original returns the encoded data of an unmodified image.  The data
+// may be released once the image is no longer reachable, so callers
+// must keep the image alive while using it.
+func Original(img image.Image) (*Encoded, bool) {
+	encoded, ok := img.(*Encoded)
+
+	return encoded, ok
+}
+
+// Decoded returns the decoded pixels of the image, decoding encoded
+// images if needed.  Encoders and image processing are often faster
+// for the concrete image types returned by decoders.
+func Decoded(img image.Image) (image.Image, error) {
+	if encoded, ok := img.(*Encoded); ok {
+		return encoded.Decode()
+	}
+
+	return img, nil
+}
@@ -0,0 +1,108 @@
+package codec
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Encoder encodes images that are written to output files.  Writers
+// copy the data of unmodified images instead, unless told otherwise.
+type Encoder interface {
+	// Format returns the name of the format images are encoded in,
+	// which is one of the names used by the registry.
+	Format() string
+	Encode(w io.Writer, img image.Image) error
+}
+
+// JPEGEncoder encodes images as JPEG with the given quality, ranging
+// from 1 to 100.
+type JPEGEncoder struct {
+	Quality int
+}
+
+func (e JPEGEncoder) Format() string {
+	return JPEG.Name
+}
+
+func (e JPEGEncoder) Encode(w io.Writer, img image.Image) error {
+	return jpeg.Encode(w, img, &jpeg.Options{Quality: e.Quality})
+}
+
+// PNGEncoder encodes images as PNG, which is lossless but results in
+// much larger files for most pages.
+type PNGEncoder struct{}
+
+func (e PNGEncoder) Format() string {
+	return PNG.Name
+}
+
+func (e PNGEncoder) Encode(w io.Writer, img image.Image) error {
+	return (&png.Encoder{CompressionLevel: png.BestCompression}).Encode(w, img)
+}
+
+// DefaultEncoder is used by writers that have not been given an
+// encoder, as the quality of JPEG images is close to that of the
+// original pages, while PNG images are often several times larger.
+var DefaultEncoder Encoder = JPEGEncoder{Quality: jpeg.DefaultQuality}
+
+// Encode decodes the image and encodes it using the encoder, or
+// DefaultEncoder if nil, returning the data and the name of its format.
+func Encode(e Encoder, img image.Image) ([]byte, string, error) {
+	if e == nil {
+		e = DefaultEncoder
+	}
+	decoded, err := Decoded(img)
+	if err != nil {
+		return nil, "", err
+	}
+	buf := new(bytes.Buffer)
+	if err := e.Encode(buf, decoded); err != nil {
+		return nil, "", err
+	}
+
+	return buf.Bytes(), e.Format(), nil
+}
+
+// encoders construct the encoders that are accepted by ParseEncoder in
+// addition to JPEG and PNG, such as WebP when building with libwebp.
+var encoders = make(map[string]func(quality int) (Encoder, error))
+
+// EncoderNames returns the names of all formats accepted by
+// ParseEncoder.
+func EncoderNames() []string {
+	names := make([]string, 0, len(encoders))
+	for name := range encoders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return append([]string{JPEG.Name, PNG.Name}, names...)
+}
+
+// ParseEncoder returns the encoder for the named format, using the
+// quality for formats that are lossy.
+func ParseEncoder(name string, quality int) (Encoder, error) {
+	format := strings.ToLower(strings.TrimSpace(name))
+	switch format {
+	case JPEG.Name, "jpg":
+		if quality < 1 || quality > 100 {
+			return nil, fmt.Errorf("not a valid JPEG quality: %v", quality)
+		}
+		return JPEGEncoder{Quality: quality}, nil
+	case PNG.Name:
+		return PNGEncoder{}, nil
+	}
+	if constructor, ok := encoders[format]; ok {
+		return constructor(quality)
+	} else if format == WebP.Name {
+		return nil, fmt.Errorf(`encoding "%v" requires building with the libwebp tag`, name)
+	}
+
+	return nil, fmt.Errorf(`not a valid image format: "%v"`, name)
+}
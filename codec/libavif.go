@@ -0,0 +1,136 @@
+//go:build libavif && cgo
+
+package codec
+
+/*
+#cgo LDFLAGS: -lavif
+#include <stdlib.h>
+#include <avif/avif.h>
+
+static avifDecoder *avif_new(const uint8_t *data, size_t size, avifResult *result) {
+	avifDecoder *d = avifDecoderCreate();
+	if (d == NULL) {
+		*result = AVIF_RESULT_OUT_OF_MEMORY;
+		return NULL;
+	}
+	*result = avifDecoderSetIOMemory(d, data, size);
+	if (*result == AVIF_RESULT_OK) {
+		*result = avifDecoderParse(d);
+	}
+	if (*result != AVIF_RESULT_OK) {
+		avifDecoderDestroy(d);
+		return NULL;
+	}
+	return d;
+}
+
+// avif_decode decodes the first frame as 8-bit RGBA into the pixels,
+// which are only used until the function returns.
+static avifResult avif_decode(avifDecoder *d, uint8_t *pixels, uint32_t stride) {
+	avifResult result = avifDecoderNextImage(d);
+	if (result != AVIF_RESULT_OK) {
+		return result;
+	}
+	avifRGBImage rgb;
+	avifRGBImageSetDefaults(&rgb, d->image);
+	rgb.format = AVIF_RGB_FORMAT_RGBA;
+	rgb.depth = 8;
+	rgb.pixels = pixels;
+	rgb.rowBytes = stride;
+	return avifImageYUVToRGB(d->image, &rgb);
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"unsafe"
+)
+
+// AVIF decodes AVIF images using libavif.  It is only available when
+// building with the "libavif" tag and cgo, and is then added to the
+// default registry.  Only the first frame of animated images is
+// decoded.
+var AVIF = Format{
+	Name:         "avif",
+	Magic:        avifMagic,
+	Extensions:   []string{".avif"},
+	Decode:       decodeAVIF,
+	DecodeConfig: decodeConfigAVIF,
+}
+
+func init() {
+	Default.Register(AVIF)
+}
+
+// avifDecoder holds the state of libavif for a single image.  The
+// encoded image is copied to C memory, as libavif keeps a pointer to it
+// between calls.
+type avifDecoder struct {
+	d    *C.avifDecoder
+	data unsafe.Pointer
+}
+
+func newAVIFDecoder(r io.Reader) (*avifDecoder, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	} else if len(data) == 0 {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	a := &avifDecoder{data: C.CBytes(data)}
+	var result C.avifResult
+	a.d = C.avif_new((*C.uint8_t)(a.data), C.size_t(len(data)), &result)
+	if a.d == nil {
+		C.free(a.data)
+		return nil, avifError(result)
+	}
+
+	return a, nil
+}
+
+func (a *avifDecoder) free() {
+	C.avifDecoderDestroy(a.d)
+	C.free(a.data)
+}
+
+func (a *avifDecoder) bounds() image.Rectangle {
+	return image.Rect(0, 0, int(a.d.image.width), int(a.d.image.height))
+}
+
+func avifError(result C.avifResult) error {
+	return fmt.Errorf("libavif: %v", C.GoString(C.avifResultToString(result)))
+}
+
+func decodeAVIF(r io.Reader) (image.Image, error) {
+	a, err := newAVIFDecoder(r)
+	if err != nil {
+		return nil, err
+	}
+	defer a.free()
+
+	img := image.NewNRGBA(a.bounds())
+	if len(img.Pix) == 0 {
+		return img, nil
+	}
+	if result := C.avif_decode(a.d, (*C.uint8_t)(unsafe.Pointer(&img.Pix[0])), C.uint32_t(img.Stride)); result != C.AVIF_RESULT_OK {
+		return nil, avifError(result)
+	}
+
+	return img, nil
+}
+
+func decodeConfigAVIF(r io.Reader) (image.Config, error) {
+	a, err := newAVIFDecoder(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+	defer a.free()
+	bounds := a.bounds()
+
+	return image.Config{ColorModel: color.NRGBAModel, Width: bounds.Dx(), Height: bounds.Dy()}, nil
+}
@@ -0,0 +1,67 @@
+//go:build libwebp && cgo
+
+package codec
+
+/*
+#cgo LDFLAGS: -lwebp
+#include <webp/encode.h>
+*/
+import "C"
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"unsafe"
+)
+
+// WebPEncoder encodes images as lossy WebP with the given quality,
+// ranging from 1 to 100, using libwebp.  It is only available when
+// building with the "libwebp" tag and cgo, and is then accepted by
+// ParseEncoder.
+type WebPEncoder struct {
+	Quality int
+}
+
+func init() {
+	encoders[WebP.Name] = func(quality int) (Encoder, error) {
+		if quality < 1 || quality > 100 {
+			return nil, fmt.Errorf("not a valid WebP quality: %v", quality)
+		}
+		return WebPEncoder{Quality: quality}, nil
+	}
+}
+
+func (e WebPEncoder) Format() string {
+	return WebP.Name
+}
+
+// Encode converts the image to non-premultiplied RGBA, which libwebp
+// reads directly from the pixels of the image.
+func (e WebPEncoder) Encode(w io.Writer, img image.Image) error {
+	rgba, ok := img.(*image.NRGBA)
+	if !ok {
+		bounds := img.Bounds()
+		rgba = image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+		draw.Draw(rgba, rgba.Bounds(), img, bounds.Min, draw.Src)
+	}
+	bounds := rgba.Bounds()
+	if bounds.Empty() {
+		return fmt.Errorf("libwebp: empty image")
+	}
+
+	var output *C.uint8_t
+	size := C.WebPEncodeRGBA(
+		(*C.uint8_t)(unsafe.Pointer(&rgba.Pix[0])),
+		C.int(bounds.Dx()), C.int(bounds.Dy()), C.int(rgba.Stride),
+		C.float(e.Quality), &output,
+	)
+	if size == 0 {
+		return fmt.Errorf("libwebp: encoding failed")
+	}
+	defer C.WebPFree(unsafe.Pointer(output))
+	_, err := w.Write(C.GoBytes(unsafe.Pointer(output), C.int(size)))
+
+	return err
+}
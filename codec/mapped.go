@@ -0,0 +1,46 @@
+package codec
+
+import (
+	"os"
+	"runtime"
+)
+
+// MappedThreshold is the size above which DecodeBytes keeps encoded
+// data in a memory-mapped temporary file instead of on the heap.  Large
+// pages such as webtoon strips then neither add to the work of the
+// garbage collector nor need to stay resident.  Zero disables mapping.
+var MappedThreshold = 2 << 20
+
+// mapped moves large data of the image into a temporary file, which is
+// unmapped once the image is no longer reachable.  Every use of the
+// data must therefore be followed by runtime.KeepAlive of the image,
+// and data that is kept longer must be copied.  Data is kept on the
+// heap if mapping is not supported or fails.
+func mapped(encoded *Encoded) *Encoded {
+	if MappedThreshold <= 0 || len(encoded.Data) < MappedThreshold {
+		return encoded
+	}
+	data, err := mapTemp(encoded.Data)
+	if err != nil {
+		return encoded
+	}
+	encoded.Data = data
+	runtime.SetFinalizer(encoded, func(encoded *Encoded) { _ = unmap(encoded.Data) })
+
+	return encoded
+}
+
+// mapTemp copies data into an unlinked temporary file and maps it.
+func mapTemp(data []byte) ([]byte, error) {
+	file, err := os.CreateTemp("", "kojirou-*.img")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	defer os.Remove(file.Name())
+	if _, err := file.Write(data); err != nil {
+		return nil, err
+	}
+
+	return mapFile(file, len(data))
+}
@@ -0,0 +1,16 @@
+//go:build !unix
+
+package codec
+
+import (
+	"errors"
+	"os"
+)
+
+func mapFile(file *os.File, size int) ([]byte, error) {
+	return nil, errors.New("memory mapping is not supported")
+}
+
+func unmap(data []byte) error {
+	return nil
+}
@@ -0,0 +1,293 @@
+package codec
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/image/webp"
+)
+
+// Format describes how to recognize and decode an image format.  The
+// magic string matches the start of encoded images, where "?" matches
+// any byte, as for image.RegisterFormat.  Extensions are used to find
+// images in directories and archives.  Complete, if not nil, cheaply
+// reports whether encoded data ends with the end of the image.
+type Format struct {
+	Name         string
+	Magic        string
+	Extensions   []string
+	Decode       func(io.Reader) (image.Image, error)
+	DecodeConfig func(io.Reader) (image.Config, error)
+	Complete     func([]byte) bool
+}
+
+var (
+	GIF = Format{
+		Name:         "gif",
+		Magic:        "GIF8?a",
+		Extensions:   []string{".gif"},
+		Decode:       gif.Decode,
+		DecodeConfig: gif.DecodeConfig,
+	}
+	JPEG = Format{
+		Name:         "jpeg",
+		Magic:        "\xff\xd8",
+		Extensions:   []string{".jpg", ".jpeg"},
+		Decode:       jpeg.Decode,
+		DecodeConfig: jpeg.DecodeConfig,
+		Complete:     completeJPEG,
+	}
+	PNG = Format{
+		Name:         "png",
+		Magic:        "\x89PNG\r\n\x1a\n",
+		Extensions:   []string{".png"},
+		Decode:       png.Decode,
+		DecodeConfig: png.DecodeConfig,
+		Complete:     completePNG,
+	}
+	WebP = Format{
+		Name:         "webp",
+		Magic:        "RIFF????WEBPVP8",
+		Extensions:   []string{".webp"},
+		Decode:       webp.Decode,
+		DecodeConfig: webp.DecodeConfig,
+		Complete:     completeWebP,
+	}
+)
+
+// completeJPEG reports whether the end of image marker is found near
+// the end of the data.  Markers cannot occur within compressed data, so
+// data that was cut off does not end with one.
+func completeJPEG(data []byte) bool {
+	return bytes.Contains(data[max(0, len(data)-1024):], []byte{0xFF, 0xD9})
+}
+
+// completePNG reports whether the final chunk is found near the end of
+// the data.
+func completePNG(data []byte) bool {
+	return bytes.Contains(data[max(0, len(data)-64):], []byte("IEND"))
+}
+
+// completeWebP reports whether the data is as long as the size given
+// in its RIFF header.
+func completeWebP(data []byte) bool {
+	return len(data) >= 8 && uint64(len(data)) >= uint64(binary.LittleEndian.Uint32(data[4:8]))+8
+}
+
+// Default is used by all image decoding in Kojirou.  Programs that
+// embed Kojirou may register additional decoders, such as cgo bindings
+// for AVIF, or disable formats they do not want to accept.
+var Default = NewRegistry(GIF, JPEG, PNG, WebP)
+
+// Registry decodes images using an explicit set of formats, unlike
+// the image package, which uses all formats registered by imports.
+type Registry struct {
+	formats  []Format
+	disabled map[string]bool
+	mutex    sync.RWMutex
+}
+
+func NewRegistry(formats ...Format) *Registry {
+	r := &Registry{disabled: make(map[string]bool)}
+	for _, format := range formats {
+		r.Register(format)
+	}
+
+	return r
+}
+
+// Register adds a format, replacing any format of the same name.  The
+// format is enabled, even if a format of the same name was disabled.
+func (r *Registry) Register(format Format) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.disabled, format.Name)
+	for i := range r.formats {
+		if r.formats[i].Name == format.Name {
+			r.formats[i] = format
+			return
+		}
+	}
+	r.formats = append(r.formats, format)
+}
+
+func (r *Registry) Enable(names ...string) error {
+	return r.setDisabled(false, names)
+}
+
+func (r *Registry) Disable(names ...string) error {
+	return r.setDisabled(true, names)
+}
+
+func (r *Registry) setDisabled(disabled bool, names []string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, name := range names {
+		if !r.has(name) {
+			return fmt.Errorf("unknown image format: %v", name)
+		}
+	}
+	for _, name := range names {
+		if disabled {
+			r.disabled[name] = true
+		} else {
+			delete(r.disabled, name)
+		}
+	}
+
+	return nil
+}
+
+func (r *Registry) has(name string) bool {
+	for _, format := range r.formats {
+		if format.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Formats returns the names of all enabled formats.
+func (r *Registry) Formats() []string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	result := make([]string, 0)
+	for _, format := range r.formats {
+		if !r.disabled[format.Name] {
+			result = append(result, format.Name)
+		}
+	}
+	sort.Strings(result)
+
+	return result
+}
+
+// Extensions returns the file extensions of all enabled formats, in
+// the order the formats were registered.
+func (r *Registry) Extensions() []string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	result := make([]string, 0)
+	for _, format := range r.formats {
+		if !r.disabled[format.Name] {
+			result = append(result, format.Extensions...)
+		}
+	}
+
+	return result
+}
+
+// HasExtension reports whether the file extension, including the
+// leading dot, belongs to an enabled format.
+func (r *Registry) HasExtension(ext string) bool {
+	for _, known := range r.Extensions() {
+		if strings.EqualFold(known, ext) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Decode decodes an image in any enabled format, returning the name of
+// the format.  Unknown formats result in image.ErrFormat.
+func (r *Registry) Decode(rd io.Reader) (image.Image, string, error) {
+	br := asPeeker(rd)
+	format, err := r.sniff(br)
+	if err != nil {
+		return nil, "", err
+	}
+	img, err := format.Decode(br)
+
+	return img, format.Name, err
+}
+
+// DecodeConfig decodes the dimensions and color model of an image in
+// any enabled format, returning the name of the format.
+func (r *Registry) DecodeConfig(rd io.Reader) (image.Config, string, error) {
+	br := asPeeker(rd)
+	format, err := r.sniff(br)
+	if err != nil {
+		return image.Config{}, "", err
+	}
+	config, err := format.DecodeConfig(br)
+
+	return config, format.Name, err
+}
+
+func (r *Registry) sniff(br peeker) (Format, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	for _, format := range r.formats {
+		if r.disabled[format.Name] {
+			continue
+		}
+		b, err := br.Peek(len(format.Magic))
+		if err == nil && matchMagic(format.Magic, b) {
+			return format, nil
+		}
+	}
+
+	return Format{}, image.ErrFormat
+}
+
+// avifMagic matches the file type box of AVIF images, which are only
+// decoded when building with the "libavif" tag.
+const avifMagic = "????ftypavif"
+
+// ContentType returns the media type of the data, which describes
+// images that no enabled format accepts.  Unlike
+// http.DetectContentType, it recognizes AVIF images.
+func ContentType(data []byte) string {
+	if len(data) >= len(avifMagic) && matchMagic(avifMagic, data[:len(avifMagic)]) {
+		return "image/avif"
+	}
+
+	return http.DetectContentType(data)
+}
+
+func Decode(rd io.Reader) (image.Image, string, error) {
+	return Default.Decode(rd)
+}
+
+func DecodeConfig(rd io.Reader) (image.Config, string, error) {
+	return Default.DecodeConfig(rd)
+}
+
+type peeker interface {
+	io.Reader
+	Peek(int) ([]byte, error)
+}
+
+func asPeeker(rd io.Reader) peeker {
+	if br, ok := rd.(peeker); ok {
+		return br
+	}
+
+	return bufio.NewReader(rd)
+}
+
+func matchMagic(magic string, b []byte) bool {
+	if len(magic) != len(b) {
+		return false
+	}
+	for i, c := range b {
+		if magic[i] != c && magic[i] != '?' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,212 @@
+//go:build turbojpeg && cgo
+
+package codec
+
+/*
+#cgo LDFLAGS: -ljpeg
+#include <stdio.h>
+#include <stdlib.h>
+#include <setjmp.h>
+#include <jpeglib.h>
+
+typedef struct {
+	struct jpeg_decompress_struct cinfo;
+	struct jpeg_error_mgr pub;
+	jmp_buf jump;
+	char message[JMSG_LENGTH_MAX];
+} decoder;
+
+static void decoder_error_exit(j_common_ptr cinfo) {
+	decoder *d = (decoder *)cinfo->client_data;
+	(*cinfo->err->format_message)(cinfo, d->message);
+	longjmp(d->jump, 1);
+}
+
+// decoder_emit_message keeps the first warning instead of printing it.
+static void decoder_emit_message(j_common_ptr cinfo, int level) {
+	decoder *d = (decoder *)cinfo->client_data;
+	if (level < 0) {
+		if (d->pub.num_warnings == 0) {
+			(*cinfo->err->format_message)(cinfo, d->message);
+		}
+		d->pub.num_warnings++;
+	}
+}
+
+static decoder *decoder_new(void) {
+	decoder *d = calloc(1, sizeof(decoder));
+	if (d == NULL) {
+		return NULL;
+	}
+	d->cinfo.err = jpeg_std_error(&d->pub);
+	d->pub.error_exit = decoder_error_exit;
+	d->pub.emit_message = decoder_emit_message;
+	d->cinfo.client_data = d;
+	if (setjmp(d->jump)) {
+		free(d);
+		return NULL;
+	}
+	jpeg_create_decompress(&d->cinfo);
+	return d;
+}
+
+static int decoder_header(decoder *d, unsigned char *data, unsigned long size) {
+	if (setjmp(d->jump)) {
+		return -1;
+	}
+	jpeg_mem_src(&d->cinfo, data, size);
+	jpeg_read_header(&d->cinfo, TRUE);
+	return 0;
+}
+
+static int decoder_gray(decoder *d) {
+	return d->cinfo.jpeg_color_space == JCS_GRAYSCALE;
+}
+
+static int decoder_cmyk(decoder *d) {
+	return d->cinfo.jpeg_color_space == JCS_CMYK || d->cinfo.jpeg_color_space == JCS_YCCK;
+}
+
+static int decoder_decode(decoder *d, unsigned char *pixels, int stride) {
+	if (setjmp(d->jump)) {
+		return -1;
+	}
+	d->cinfo.out_color_space = decoder_gray(d) ? JCS_GRAYSCALE : JCS_EXT_RGBA;
+	jpeg_start_decompress(&d->cinfo);
+	while (d->cinfo.output_scanline < d->cinfo.output_height) {
+		JSAMPROW row = pixels + (size_t)d->cinfo.output_scanline * stride;
+		jpeg_read_scanlines(&d->cinfo, &row, 1);
+	}
+	jpeg_finish_decompress(&d->cinfo);
+	// Warnings are treated as errors, like the standard library does
+	// for truncated and corrupt images.
+	if (d->pub.num_warnings > 0) {
+		return -1;
+	}
+	return 0;
+}
+
+static void decoder_free(decoder *d) {
+	jpeg_destroy_decompress(&d->cinfo);
+	free(d);
+}
+*/
+import "C"
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"unsafe"
+)
+
+// TurboJPEG decodes JPEG images using libjpeg-turbo, which is several
+// times faster than the decoder of the standard library.  It is only
+// available when building with the "turbojpeg" tag and cgo, and then
+// replaces JPEG in the default registry.  Grayscale images are decoded
+// as image.Gray and all other images as image.RGBA, except for CMYK
+// images, which are left to the standard library.
+var TurboJPEG = Format{
+	Name:         JPEG.Name,
+	Magic:        JPEG.Magic,
+	Extensions:   JPEG.Extensions,
+	Decode:       decodeTurbo,
+	DecodeConfig: decodeConfigTurbo,
+	Complete:     JPEG.Complete,
+}
+
+func init() {
+	Default.Register(TurboJPEG)
+}
+
+// turboDecoder holds the state of libjpeg-turbo for a single image.
+// The encoded image is copied to C memory, as libjpeg-turbo keeps a
+// pointer to it between calls.
+type turboDecoder struct {
+	d    *C.decoder
+	data unsafe.Pointer
+}
+
+func newTurboDecoder(r io.Reader) (*turboDecoder, []byte, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	} else if len(data) == 0 {
+		return nil, nil, io.ErrUnexpectedEOF
+	}
+
+	d := C.decoder_new()
+	if d == nil {
+		return nil, nil, errors.New("turbojpeg: out of memory")
+	}
+	t := &turboDecoder{d: d, data: C.CBytes(data)}
+	if C.decoder_header(d, (*C.uchar)(t.data), C.ulong(len(data))) != 0 {
+		err := t.err()
+		t.free()
+		return nil, nil, err
+	}
+
+	return t, data, nil
+}
+
+func (t *turboDecoder) err() error {
+	return fmt.Errorf("turbojpeg: %v", C.GoString(&t.d.message[0]))
+}
+
+func (t *turboDecoder) free() {
+	C.decoder_free(t.d)
+	C.free(t.data)
+}
+
+func decodeTurbo(r io.Reader) (image.Image, error) {
+	t, data, err := newTurboDecoder(r)
+	if err != nil {
+		return nil, err
+	}
+	defer t.free()
+
+	if C.decoder_cmyk(t.d) != 0 {
+		return jpeg.Decode(bytes.NewReader(data))
+	}
+
+	bounds := image.Rect(0, 0, int(t.d.cinfo.image_width), int(t.d.cinfo.image_height))
+	var img image.Image
+	var pixels []byte
+	var stride int
+	if C.decoder_gray(t.d) != 0 {
+		gray := image.NewGray(bounds)
+		img, pixels, stride = gray, gray.Pix, gray.Stride
+	} else {
+		rgba := image.NewRGBA(bounds)
+		img, pixels, stride = rgba, rgba.Pix, rgba.Stride
+	}
+	if len(pixels) == 0 {
+		return img, nil
+	}
+	if C.decoder_decode(t.d, (*C.uchar)(unsafe.Pointer(&pixels[0])), C.int(stride)) != 0 {
+		return nil, t.err()
+	}
+
+	return img, nil
+}
+
+func decodeConfigTurbo(r io.Reader) (image.Config, error) {
+	t, data, err := newTurboDecoder(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+	defer t.free()
+
+	switch {
+	case C.decoder_cmyk(t.d) != 0:
+		return jpeg.DecodeConfig(bytes.NewReader(data))
+	case C.decoder_gray(t.d) != 0:
+		return image.Config{ColorModel: color.GrayModel, Width: int(t.d.cinfo.image_width), Height: int(t.d.cinfo.image_height)}, nil
+	default:
+		return image.Config{ColorModel: color.RGBAModel, Width: int(t.d.cinfo.image_width), Height: int(t.d.cinfo.image_height)}, nil
+	}
+}
@@ -1,21 +1,31 @@
 module github.com/leotaku/kojirou
 
-go 1.16
+go 1.21
 
 require (
 	github.com/cheggaaa/pb/v3 v3.1.2
 	github.com/fatih/color v1.14.1
 	github.com/hashicorp/go-retryablehttp v0.7.2
-	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/leotaku/mobi v0.0.0-20230310202000-12c152e6099c
-	github.com/mattn/go-runewidth v0.0.14 // indirect
-	github.com/rivo/uniseg v0.4.4 // indirect
+	github.com/mattn/go-isatty v0.0.17
+	github.com/nwaples/rardecode v1.1.3
+	github.com/pelletier/go-toml/v2 v2.0.8
 	github.com/spf13/cobra v1.6.1
 	github.com/spf13/pflag v1.0.5
-	go.uber.org/ratelimit v0.2.0
-	golang.org/x/sync v0.1.0
-	golang.org/x/sys v0.6.0 // indirect
-	golang.org/x/text v0.8.0
+	golang.org/x/image v0.18.0
+	golang.org/x/sync v0.7.0
+	golang.org/x/term v0.10.0
+	golang.org/x/text v0.16.0
+)
+
+require (
+	github.com/VividCortex/ewma v1.2.0 // indirect
+	github.com/hashicorp/go-cleanhttp v0.5.2 // indirect
+	github.com/inconshreveable/mousetrap v1.1.0 // indirect
+	github.com/mattn/go-colorable v0.1.13 // indirect
+	github.com/mattn/go-runewidth v0.0.14 // indirect
+	github.com/rivo/uniseg v0.4.4 // indirect
+	golang.org/x/sys v0.10.0 // indirect
 )
 
 // replace github.com/leotaku/mobi => ../mobi
@@ -0,0 +1,207 @@
+package kitsu
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	APIBaseURL, _  = url.Parse(`https://kitsu.io/api/edge/`)
+	AuthBaseURL, _ = url.Parse(`https://kitsu.io/api/oauth/`)
+)
+
+const mediaType = "application/vnd.api+json"
+
+type Client struct {
+	http    *http.Client
+	baseURL url.URL
+	authURL url.URL
+	token   *Token
+}
+
+func NewClient() *Client {
+	return &Client{
+		http:    http.DefaultClient,
+		baseURL: *APIBaseURL,
+		authURL: *AuthBaseURL,
+	}
+}
+
+func (c *Client) WithHTTPClient(http *http.Client) *Client {
+	c.http = http
+	return c
+}
+
+func (c *Client) WithToken(token *Token) *Client {
+	c.token = token
+	return c
+}
+
+func (c *Client) Login(ctx context.Context, username, password string) (*Token, error) {
+	return c.postToken(ctx, url.Values{
+		"grant_type": {"password"},
+		"username":   {username},
+		"password":   {password},
+	})
+}
+
+func (c *Client) Refresh(ctx context.Context, token *Token) (*Token, error) {
+	return c.postToken(ctx, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {token.RefreshToken},
+	})
+}
+
+// GetManga returns the manga for the given numeric ID or slug.
+func (c *Client) GetManga(ctx context.Context, mangaID string) (*Manga, error) {
+	if _, err := strconv.Atoi(mangaID); err == nil {
+		v := new(Manga)
+		err := c.doJSON(ctx, "GET", "manga/"+mangaID, v, nil)
+		return v, err
+	}
+
+	v := new(MangaList)
+	query := url.Values{"filter[slug]": {mangaID}}
+	if err := c.doJSON(ctx, "GET", "manga?"+query.Encode(), v, nil); err != nil {
+		return nil, err
+	} else if len(v.Data) == 0 {
+		return nil, fmt.Errorf("manga not found: %v", mangaID)
+	}
+
+	return &Manga{Data: v.Data[0]}, nil
+}
+
+func (c *Client) GetSelf(ctx context.Context) (*UserData, error) {
+	v := new(UserList)
+	query := url.Values{"filter[self]": {"true"}}
+	if err := c.doJSON(ctx, "GET", "users?"+query.Encode(), v, nil); err != nil {
+		return nil, err
+	} else if len(v.Data) == 0 {
+		return nil, fmt.Errorf("not authenticated")
+	}
+
+	return &v.Data[0], nil
+}
+
+func (c *Client) GetLibraryEntries(ctx context.Context, userID, mangaID string) (*LibraryEntryList, error) {
+	v := new(LibraryEntryList)
+	query := url.Values{
+		"filter[userId]":  {userID},
+		"filter[mangaId]": {mangaID},
+	}
+	err := c.doJSON(ctx, "GET", "library-entries?"+query.Encode(), v, nil)
+	return v, err
+}
+
+func (c *Client) PostLibraryEntry(ctx context.Context, userID, mangaID string, attrs LibraryEntryAttributes) (*LibraryEntry, error) {
+	v := new(LibraryEntry)
+	err := c.doJSON(ctx, "POST", "library-entries", v, map[string]interface{}{
+		"data": map[string]interface{}{
+			"type":       "libraryEntries",
+			"attributes": attrs,
+			"relationships": map[string]interface{}{
+				"user":  relationship("users", userID),
+				"media": relationship("manga", mangaID),
+			},
+		},
+	})
+	return v, err
+}
+
+func (c *Client) PatchLibraryEntry(ctx context.Context, entryID string, attrs LibraryEntryAttributes) (*LibraryEntry, error) {
+	v := new(LibraryEntry)
+	err := c.doJSON(ctx, "PATCH", "library-entries/"+entryID, v, map[string]interface{}{
+		"data": map[string]interface{}{
+			"id":         entryID,
+			"type":       "libraryEntries",
+			"attributes": attrs,
+		},
+	})
+	return v, err
+}
+
+func (c *Client) postToken(ctx context.Context, form url.Values) (*Token, error) {
+	url, err := c.authURL.Parse("token")
+	if err != nil {
+		return nil, fmt.Errorf("url: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", url.String(), strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("prepare: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	v := new(Token)
+	if err := c.do(req, v); err != nil {
+		return nil, err
+	}
+	v.Created = time.Now()
+
+	return v, nil
+}
+
+func (c *Client) doJSON(ctx context.Context, method, ref string, result, body interface{}) error {
+	url, err := c.baseURL.Parse(ref)
+	if err != nil {
+		return fmt.Errorf("url: %w", err)
+	}
+
+	rw := io.ReadWriter(nil)
+	if body != nil {
+		rw = bytes.NewBuffer(nil)
+		if err := json.NewEncoder(rw).Encode(body); err != nil {
+			return fmt.Errorf("encode: %w", err)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url.String(), rw)
+	if err != nil {
+		return fmt.Errorf("prepare: %w", err)
+	}
+	req.Header.Set("Accept", mediaType)
+	req.Header.Set("Content-Type", mediaType)
+	if c.token != nil {
+		req.Header.Set("Authorization", "Bearer "+c.token.AccessToken)
+	}
+
+	return c.do(req, result)
+}
+
+func (c *Client) do(req *http.Request, result interface{}) error {
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("do: %w", err)
+	}
+	defer resp.Body.Close()
+
+	dec := json.NewDecoder(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		errs := new(Errors)
+		if err := dec.Decode(errs); err != nil || len(errs.Errors) == 0 {
+			return fmt.Errorf("status: %v", resp.Status)
+		} else {
+			return fmt.Errorf("detail: %v", errs.Errors[0].Detail)
+		}
+	} else if err := dec.Decode(result); err != nil {
+		return fmt.Errorf("decode: %w", err)
+	}
+
+	return nil
+}
+
+func relationship(tp, id string) map[string]interface{} {
+	return map[string]interface{}{
+		"data": map[string]string{
+			"type": tp,
+			"id":   id,
+		},
+	}
+}
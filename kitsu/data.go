@@ -0,0 +1,88 @@
+package kitsu
+
+import "time"
+
+type Manga struct {
+	Data MangaData `json:"data"`
+}
+
+type MangaList struct {
+	Data []MangaData `json:"data"`
+}
+
+type MangaData struct {
+	ID         string `json:"id"`
+	Type       string `json:"type"`
+	Attributes struct {
+		Slug           string            `json:"slug"`
+		CanonicalTitle string            `json:"canonicalTitle"`
+		Titles         map[string]string `json:"titles"`
+		AbbrTitles     []string          `json:"abbreviatedTitles"`
+		ChapterCount   int               `json:"chapterCount"`
+		VolumeCount    int               `json:"volumeCount"`
+	} `json:"attributes"`
+}
+
+func (m MangaData) AllTitles() []string {
+	result := []string{m.Attributes.CanonicalTitle}
+	for _, title := range m.Attributes.Titles {
+		if title != "" && title != m.Attributes.CanonicalTitle {
+			result = append(result, title)
+		}
+	}
+
+	return append(result, m.Attributes.AbbrTitles...)
+}
+
+type UserList struct {
+	Data []UserData `json:"data"`
+}
+
+type UserData struct {
+	ID         string `json:"id"`
+	Type       string `json:"type"`
+	Attributes struct {
+		Name string `json:"name"`
+	} `json:"attributes"`
+}
+
+type LibraryEntry struct {
+	Data LibraryEntryData `json:"data"`
+}
+
+type LibraryEntryList struct {
+	Data []LibraryEntryData `json:"data"`
+}
+
+type LibraryEntryData struct {
+	ID         string                 `json:"id"`
+	Type       string                 `json:"type"`
+	Attributes LibraryEntryAttributes `json:"attributes"`
+}
+
+type LibraryEntryAttributes struct {
+	Status       string `json:"status,omitempty"`
+	Progress     int    `json:"progress,omitempty"`
+	VolumesOwned int    `json:"volumesOwned,omitempty"`
+}
+
+type Token struct {
+	TokenType    string    `json:"token_type"`
+	ExpiresIn    int       `json:"expires_in"`
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token"`
+	Created      time.Time `json:"created"`
+}
+
+func (t Token) Expired() bool {
+	return time.Since(t.Created) > time.Duration(t.ExpiresIn)*time.Second
+}
+
+type Errors struct {
+	Errors []struct {
+		Title  string `json:"title"`
+		Detail string `json:"detail"`
+		Code   string `json:"code"`
+		Status string `json:"status"`
+	} `json:"errors"`
+}
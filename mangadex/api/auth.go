@@ -0,0 +1,156 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+var AuthBaseURL, _ = url.Parse(`https://auth.mangadex.org/realms/mangadex/protocol/openid-connect/`)
+
+// Credentials identify a personal API client, which can be created in
+// the settings of a MangaDex account, and the account it belongs to.
+type Credentials struct {
+	ClientID     string
+	ClientSecret string
+	Username     string
+	Password     string
+}
+
+// TokenSource returns the access token for a request, which allows
+// refreshing expired tokens transparently.
+type TokenSource interface {
+	AccessToken(ctx context.Context) (string, error)
+}
+
+func (c *Client) WithAuthURL(url url.URL) *Client {
+	c.authURL = url
+	return c
+}
+
+// WithTokenSource authenticates all requests using tokens from the
+// given source, which takes precedence over WithToken.
+func (c *Client) WithTokenSource(source TokenSource) *Client {
+	c.tokens = source
+	return c
+}
+
+// Login requests a token for the account using the password grant of
+// the personal API client.
+func (c *Client) Login(ctx context.Context, creds Credentials) (*Token, error) {
+	return c.postToken(ctx, url.Values{
+		"grant_type":    {"password"},
+		"username":      {creds.Username},
+		"password":      {creds.Password},
+		"client_id":     {creds.ClientID},
+		"client_secret": {creds.ClientSecret},
+	})
+}
+
+// Refresh requests a new token using the refresh token of the given
+// token, which must have been issued to the same client.
+func (c *Client) Refresh(ctx context.Context, clientID, clientSecret string, token *Token) (*Token, error) {
+	return c.postToken(ctx, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {token.RefreshToken},
+		"client_id":     {clientID},
+		"client_secret": {clientSecret},
+	})
+}
+
+func (c *Client) postToken(ctx context.Context, form url.Values) (*Token, error) {
+	url, err := c.authURL.Parse("token")
+	if err != nil {
+		return nil, fmt.Errorf("url: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", url.String(), strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("prepare: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("do: %w", err)
+	}
+	defer resp.Body.Close()
+
+	dec := json.NewDecoder(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		tokenErr := new(TokenError)
+		if err := dec.Decode(tokenErr); err == nil && tokenErr.Error == "invalid_grant" {
+			return nil, fmt.Errorf("%w: %v", ErrUnauthorized, tokenErr.Description)
+		}
+		return nil, &StatusError{StatusCode: resp.StatusCode, Status: resp.Status, Detail: tokenErr.Description}
+	}
+
+	v := new(Token)
+	if err := dec.Decode(v); err != nil {
+		return nil, &DecodeError{Err: err}
+	}
+	v.Created = time.Now()
+
+	return v, nil
+}
+
+// Session is a TokenSource for a login, which refreshes the token once
+// it expires.  Refreshed tokens are passed to the save function, if not
+// nil, so that they can be reused later.
+type Session struct {
+	client       *Client
+	clientID     string
+	clientSecret string
+	token        *Token
+	save         func(*Token) error
+	expired      bool
+	mutex        sync.Mutex
+}
+
+// NewSession returns a session for the given token, which is refreshed
+// using the given client.
+func NewSession(client *Client, clientID, clientSecret string, token *Token, save func(*Token) error) *Session {
+	return &Session{
+		client:       client,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		token:        token,
+		save:         save,
+	}
+}
+
+// AccessToken returns the current access token, refreshing it first if
+// it has expired.  Concurrent requests wait for the same refresh.  Once
+// the refresh token has expired or been rejected, the session returns
+// ErrUnauthorized without trying to refresh it again.
+func (s *Session) AccessToken(ctx context.Context) (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.expired || s.token.RefreshExpired() {
+		s.expired = true
+		return "", fmt.Errorf("session expired: %w", ErrUnauthorized)
+	} else if !s.token.Expired() {
+		return s.token.AccessToken, nil
+	}
+
+	token, err := s.client.Refresh(ctx, s.clientID, s.clientSecret, s.token)
+	if errors.Is(err, ErrUnauthorized) {
+		s.expired = true
+		return "", fmt.Errorf("refresh: %w", err)
+	} else if err != nil {
+		return "", fmt.Errorf("refresh: %w", err)
+	}
+	s.token = token
+	if s.save != nil {
+		if err := s.save(token); err != nil {
+			return "", fmt.Errorf("save: %w", err)
+		}
+	}
+
+	return token.AccessToken, nil
+}
@@ -4,31 +4,30 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
-	"time"
-
-	"go.uber.org/ratelimit"
-)
-
-var (
-	limitGlobal = ratelimit.New(5, ratelimit.Per(time.Second))
-	limitAtHome = ratelimit.New(40, ratelimit.Per(time.Minute))
 )
 
 var APIBaseURL, _ = url.Parse(`https://api.mangadex.org/`)
 
+// Client is a typed client for the MangaDex API.  It does not depend on
+// the rest of Kojirou, so it may be used on its own.
 type Client struct {
 	http    *http.Client
 	baseURL url.URL
+	authURL url.URL
+	token   string
+	tokens  TokenSource
 }
 
 func NewClient() *Client {
 	return &Client{
-		http:    http.DefaultClient,
+		http:    &http.Client{Transport: DefaultRateLimiter.Transport(nil)},
 		baseURL: *APIBaseURL,
+		authURL: *AuthBaseURL,
 	}
 }
 
@@ -37,14 +36,31 @@ func (c *Client) WithBaseURL(url url.URL) *Client {
 	return c
 }
 
+// WithHTTPClient makes requests using the given client, which should
+// respect rate limits, for example using DefaultRateLimiter.Transport.
 func (c *Client) WithHTTPClient(http *http.Client) *Client {
 	c.http = http
 	return c
 }
 
-func (c *Client) GetManga(ctx context.Context, mangaID string) (*Manga, error) {
+// WithToken authenticates all requests using the given session token,
+// which is required for endpoints related to the current user.
+func (c *Client) WithToken(token string) *Client {
+	c.token = token
+	return c
+}
+
+// GetManga returns a manga, expanding relationships of the given types
+// such as "author" so that their attributes are included.
+func (c *Client) GetManga(ctx context.Context, mangaID string, includes ...string) (*Manga, error) {
 	v := new(Manga)
-	err := c.doJSON(ctx, "GET", "/manga/"+mangaID, v, nil)
+	err := c.doJSON(ctx, "GET", "/manga/"+mangaID+"?"+QueryArgs{Includes: includes}.Values().Encode(), v, nil)
+	return v, err
+}
+
+func (c *Client) GetMangaList(ctx context.Context, args QueryArgs) (*MangaList, error) {
+	v := new(MangaList)
+	err := c.doJSON(ctx, "GET", "/manga?"+args.Values().Encode(), v, nil)
 	return v, err
 }
 
@@ -75,7 +91,6 @@ func (c *Client) GetGroups(ctx context.Context, args QueryArgs) (*GroupList, err
 
 func (c *Client) GetAtHome(ctx context.Context, chapterID string) (*AtHome, error) {
 	v := new(AtHome)
-	limitAtHome.Take()
 	err := c.doJSON(ctx, "GET", "/at-home/server/"+chapterID, v, nil)
 	return v, err
 }
@@ -90,6 +105,123 @@ func (c *Client) PostIDMapping(ctx context.Context, tp string, legacyIDs ...int)
 	return v, err
 }
 
+func (c *Client) GetChapter(ctx context.Context, chapterID string) (*Chapter, error) {
+	v := new(Chapter)
+	err := c.doJSON(ctx, "GET", "/chapter/"+chapterID, v, nil)
+	return v, err
+}
+
+func (c *Client) GetChapterList(ctx context.Context, args QueryArgs) (*ChapterList, error) {
+	v := new(ChapterList)
+	err := c.doJSON(ctx, "GET", "/chapter?"+args.Values().Encode(), v, nil)
+	return v, err
+}
+
+func (c *Client) GetCover(ctx context.Context, coverID string) (*Cover, error) {
+	v := new(Cover)
+	err := c.doJSON(ctx, "GET", "/cover/"+coverID, v, nil)
+	return v, err
+}
+
+func (c *Client) GetAuthor(ctx context.Context, authorID string) (*Author, error) {
+	v := new(Author)
+	err := c.doJSON(ctx, "GET", "/author/"+authorID, v, nil)
+	return v, err
+}
+
+func (c *Client) GetGroup(ctx context.Context, groupID string) (*Group, error) {
+	v := new(Group)
+	err := c.doJSON(ctx, "GET", "/group/"+groupID, v, nil)
+	return v, err
+}
+
+func (c *Client) GetTags(ctx context.Context) (*TagList, error) {
+	v := new(TagList)
+	err := c.doJSON(ctx, "GET", "/manga/tag", v, nil)
+	return v, err
+}
+
+func (c *Client) GetCustomList(ctx context.Context, listID string) (*CustomList, error) {
+	v := new(CustomList)
+	err := c.doJSON(ctx, "GET", "/list/"+listID, v, nil)
+	return v, err
+}
+
+func (c *Client) GetCustomListFeed(ctx context.Context, listID string, args QueryArgs) (*ChapterList, error) {
+	v := new(ChapterList)
+	url := fmt.Sprintf("/list/%v/feed?%v", listID, args.Values().Encode())
+	err := c.doJSON(ctx, "GET", url, v, nil)
+	return v, err
+}
+
+func (c *Client) GetMangaStatistics(ctx context.Context, mangaIDs ...string) (*MangaStatistics, error) {
+	v := new(MangaStatistics)
+	err := c.doJSON(ctx, "GET", "/statistics/manga?"+QueryArgs{Mangas: mangaIDs}.Values().Encode(), v, nil)
+	return v, err
+}
+
+func (c *Client) GetChapterStatistics(ctx context.Context, chapterIDs ...string) (*ChapterStatistics, error) {
+	v := new(ChapterStatistics)
+	query := make(url.Values)
+	for _, id := range chapterIDs {
+		query.Add("chapter[]", id)
+	}
+	err := c.doJSON(ctx, "GET", "/statistics/chapter?"+query.Encode(), v, nil)
+	return v, err
+}
+
+// GetFollowedMangaList returns the manga followed by the current user.
+func (c *Client) GetFollowedMangaList(ctx context.Context, args QueryArgs) (*MangaList, error) {
+	v := new(MangaList)
+	err := c.doJSON(ctx, "GET", "/user/follows/manga?"+args.Values().Encode(), v, nil)
+	return v, err
+}
+
+// GetReadMarkers returns the chapters of a manga that the current user
+// has read.
+func (c *Client) GetReadMarkers(ctx context.Context, mangaID string) (*ReadMarkers, error) {
+	v := new(ReadMarkers)
+	err := c.doJSON(ctx, "GET", "/manga/"+mangaID+"/read", v, nil)
+	return v, err
+}
+
+// PostReadMarkers marks chapters of a manga as read or unread for the
+// current user.
+func (c *Client) PostReadMarkers(ctx context.Context, mangaID string, read, unread []string) error {
+	v := new(Response)
+	return c.doJSON(ctx, "POST", "/manga/"+mangaID+"/read", v, map[string]interface{}{
+		"chapterIdsRead":   nonNil(read),
+		"chapterIdsUnread": nonNil(unread),
+	})
+}
+
+// GetReportReasons lists the reasons available for reports in the
+// given category, such as "manga" or "chapter".
+func (c *Client) GetReportReasons(ctx context.Context, category string) (*ReportReasonList, error) {
+	v := new(ReportReasonList)
+	err := c.doJSON(ctx, "GET", "/report/reasons/"+category, v, nil)
+	return v, err
+}
+
+// PostReport reports an object, using a reason from GetReportReasons.
+func (c *Client) PostReport(ctx context.Context, category, objectID, reasonID, details string) error {
+	v := new(Response)
+	return c.doJSON(ctx, "POST", "/report", v, map[string]interface{}{
+		"category": category,
+		"reason":   reasonID,
+		"objectId": objectID,
+		"details":  details,
+	})
+}
+
+func nonNil(ids []string) []string {
+	if ids == nil {
+		return []string{}
+	}
+
+	return ids
+}
+
 func (c *Client) doJSON(ctx context.Context, method, ref string, result, body interface{}) error {
 	url, err := c.baseURL.Parse(ref)
 	if err != nil {
@@ -109,8 +241,21 @@ func (c *Client) doJSON(ctx context.Context, method, ref string, result, body in
 		return fmt.Errorf("prepare: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
+	token := c.token
+	if c.tokens != nil {
+		// Sessions that can no longer be refreshed fall back to
+		// unauthenticated requests, which still work for public
+		// endpoints, while others fail with ErrUnauthorized below.
+		if token, err = c.tokens.AccessToken(ctx); errors.Is(err, ErrUnauthorized) {
+			token = ""
+		} else if err != nil {
+			return fmt.Errorf("token: %w", err)
+		}
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
 
-	limitGlobal.Take()
 	resp, err := c.http.Do(req)
 	if err != nil {
 		return fmt.Errorf("do: %w", err)
@@ -119,16 +264,16 @@ func (c *Client) doJSON(ctx context.Context, method, ref string, result, body in
 
 	dec := json.NewDecoder(resp.Body)
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		statusErr := &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
 		errs := new(Errors)
 		if err := dec.Decode(errs); err != nil {
-			return fmt.Errorf("error decode: %w", err)
+			return fmt.Errorf("%w: error decode: %v", statusErr, err)
 		} else if len(errs.Errors) != 0 {
-			return fmt.Errorf("detail: %v", errs.Errors[0].Detail)
-		} else {
-			return fmt.Errorf("status: %v", resp.Status)
+			statusErr.Detail = errs.Errors[0].Detail
 		}
+		return statusErr
 	} else if err := dec.Decode(result); err != nil {
-		return fmt.Errorf("decode: %w", err)
+		return &DecodeError{Err: err}
 	}
 
 	return nil
@@ -26,6 +26,15 @@ type Manga struct {
 	Data     MangaData
 }
 
+type MangaList struct {
+	Result   string
+	Response string
+	Data     []MangaData
+	Limit    int
+	Offset   int
+	Total    int
+}
+
 type MangaData struct {
 	ID         string
 	Type       string
@@ -43,7 +52,8 @@ type MangaData struct {
 		Year                           int
 		ContentRating                  string
 		ChapterNumbersResetOnNewVolume bool
-		Tags                           Relationships
+		AvailableTranslatedLanguages   []string
+		Tags                           []TagData
 		State                          string
 		Version                        int
 		CreatedAt                      time.Time
@@ -52,6 +62,26 @@ type MangaData struct {
 	Relationships Relationships
 }
 
+type TagData struct {
+	ID         string
+	Type       string
+	Attributes struct {
+		Name        Localized
+		Description Localized
+		Group       string
+		Version     int
+	}
+}
+
+type TagList struct {
+	Result   string
+	Response string
+	Data     []TagData
+	Limit    int
+	Offset   int
+	Total    int
+}
+
 type ChapterList struct {
 	Result   string
 	Response string
@@ -117,6 +147,12 @@ type CoverData struct {
 	Relationships Relationships
 }
 
+type Author struct {
+	Result   string
+	Response string
+	Data     AuthorData
+}
+
 type AuthorList struct {
 	Result   string
 	Response string
@@ -227,6 +263,82 @@ type AtHome struct {
 	}
 }
 
+type CustomList struct {
+	Result   string
+	Response string
+	Data     CustomListData
+}
+
+type CustomListData struct {
+	ID         string
+	Type       string
+	Attributes struct {
+		Name       string
+		Visibility string
+		Version    int
+	}
+	Relationships Relationships
+}
+
+type MangaStatistics struct {
+	Result     string
+	Statistics map[string]MangaStatisticsData
+}
+
+type MangaStatisticsData struct {
+	Comments *CommentStatistics
+	Rating   struct {
+		Average      float64
+		Bayesian     float64
+		Distribution map[string]int
+	}
+	Follows int
+}
+
+type ChapterStatistics struct {
+	Result     string
+	Statistics map[string]ChapterStatisticsData
+}
+
+type ChapterStatisticsData struct {
+	Comments *CommentStatistics
+}
+
+type CommentStatistics struct {
+	ThreadID     int
+	RepliesCount int
+}
+
+type ReadMarkers struct {
+	Result string
+	Data   []string
+}
+
+type ReportReasonList struct {
+	Result   string
+	Response string
+	Data     []ReportReasonData
+	Limit    int
+	Offset   int
+	Total    int
+}
+
+type ReportReasonData struct {
+	ID         string
+	Type       string
+	Attributes struct {
+		Reason          Localized
+		DetailsRequired bool
+		Category        string
+		Version         int
+	}
+}
+
+// Response is the result of requests that do not return any data.
+type Response struct {
+	Result string
+}
+
 type Relationships struct {
 	Manga      []string
 	Chapter    []string
@@ -239,6 +351,18 @@ type Relationships struct {
 	CoverArt   []string
 	Leader     []string
 	Member     []string
+	Creator    []string
+
+	// Included holds the relationships that were expanded using the
+	// includes[] parameter, by their ID.
+	Included map[string]Relationship
+}
+
+// Name returns the name of an included relationship, or an empty string
+// if it was not included.
+func (rs Relationships) Name(id string) string {
+	name, _ := rs.Included[id].Attributes["name"].(string)
+	return name
 }
 
 func (rs *Relationships) UnmarshalJSON(data []byte) error {
@@ -248,6 +372,12 @@ func (rs *Relationships) UnmarshalJSON(data []byte) error {
 	}
 
 	for _, r := range parsed {
+		if r.Attributes != nil {
+			if rs.Included == nil {
+				rs.Included = make(map[string]Relationship)
+			}
+			rs.Included[r.ID] = r
+		}
 		switch r.Type {
 		case "manga":
 			rs.Manga = append(rs.Manga, r.ID)
@@ -271,6 +401,8 @@ func (rs *Relationships) UnmarshalJSON(data []byte) error {
 			rs.Leader = append(rs.Leader, r.ID)
 		case "member":
 			rs.Member = append(rs.Member, r.ID)
+		case "creator":
+			rs.Creator = append(rs.Creator, r.ID)
 		default:
 			return fmt.Errorf("unsupported relationship: %v", r.Type)
 		}
@@ -285,6 +417,37 @@ type Relationship struct {
 	Attributes map[string]interface{}
 }
 
+// Token is issued by the MangaDex authentication server.  Access tokens
+// expire after a few minutes, while refresh tokens are valid for much
+// longer and are used to request new access tokens.
+type Token struct {
+	TokenType        string    `json:"token_type"`
+	ExpiresIn        int       `json:"expires_in"`
+	RefreshExpiresIn int       `json:"refresh_expires_in"`
+	AccessToken      string    `json:"access_token"`
+	RefreshToken     string    `json:"refresh_token"`
+	Created          time.Time `json:"created"`
+}
+
+// Expired reports whether the access token has expired or is about to
+// expire, so that it is refreshed before requests fail.
+func (t Token) Expired() bool {
+	return time.Since(t.Created) > time.Duration(t.ExpiresIn)*time.Second-time.Minute
+}
+
+// RefreshExpired reports whether the refresh token has expired, after
+// which a new login is required.  Refresh tokens without an expiry
+// never expire.
+func (t Token) RefreshExpired() bool {
+	return t.RefreshExpiresIn > 0 && time.Since(t.Created) > time.Duration(t.RefreshExpiresIn)*time.Second
+}
+
+// TokenError is the error response of the authentication server.
+type TokenError struct {
+	Error       string `json:"error"`
+	Description string `json:"error_description"`
+}
+
 type Errors struct {
 	Errors []ErrorData
 	Result string
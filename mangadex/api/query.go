@@ -9,15 +9,28 @@ import (
 )
 
 type QueryArgs struct {
-	IDs           []string          `url:"ids"`
-	Languages     []language.Tag    `url:"translatedLanguage"`
-	Mangas        []string          `url:"manga"`
-	Order         map[string]string `url:"order"`
-	Limit         int               `url:"limit"`
-	Offset        int               `url:"offset"`
-	EmptyPages    string            `url:"includeEmptyPages"`
-	FuturePublish string            `url:"includeFuturePublishAt"`
-	ExternalURL   string            `url:"includeExternalUrl"`
+	IDs               []string          `url:"ids"`
+	Title             string            `url:"title"`
+	Name              string            `url:"name"`
+	Languages         []language.Tag    `url:"translatedLanguage"`
+	OriginalLanguages []language.Tag    `url:"originalLanguage"`
+	Mangas            []string          `url:"manga"`
+	Authors           []string          `url:"authors"`
+	Artists           []string          `url:"artists"`
+	Groups            []string          `url:"groups"`
+	IncludedTags      []string          `url:"includedTags"`
+	ExcludedTags      []string          `url:"excludedTags"`
+	Status            []string          `url:"status"`
+	ContentRating     []string          `url:"contentRating"`
+	Year              int               `url:"year"`
+	Locales           []language.Tag    `url:"locales"`
+	Order             map[string]string `url:"order"`
+	Limit             int               `url:"limit"`
+	Offset            int               `url:"offset"`
+	EmptyPages        string            `url:"includeEmptyPages"`
+	FuturePublish     string            `url:"includeFuturePublishAt"`
+	ExternalURL       string            `url:"includeExternalUrl"`
+	Includes          []string          `url:"includes"`
 }
 
 func (a QueryArgs) Values() url.Values {
@@ -0,0 +1,217 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimit allows the given number of requests per interval to paths
+// that start with the prefix.  An empty prefix matches all paths.
+type RateLimit struct {
+	Prefix   string
+	Requests int
+	Per      time.Duration
+}
+
+// DefaultRateLimits are the published limits of the MangaDex API.  The
+// global limit also applies to endpoints with their own limits.
+var DefaultRateLimits = []RateLimit{
+	{Prefix: "", Requests: 5, Per: time.Second},
+	{Prefix: "/at-home/server/", Requests: 40, Per: time.Minute},
+}
+
+// DefaultRateLimiter is shared by all clients that use the default
+// HTTP client, and by all Kojirou downloads, so that concurrent clients
+// never exceed the limits of the MangaDex API together.
+var DefaultRateLimiter = NewRateLimiter(APIBaseURL.Host, DefaultRateLimits...)
+
+// RateLimiter delays requests to a host to respect per-endpoint rate
+// limits.  Every limit is a token bucket holding a single token, which
+// spaces requests evenly instead of sending them in bursts.  Endpoints
+// are paused when the server reports that their limit is exhausted.
+type RateLimiter struct {
+	host    string
+	buckets []*bucket
+	mutex   sync.Mutex
+}
+
+type bucket struct {
+	limit       RateLimit
+	next        time.Time
+	pausedUntil time.Time
+}
+
+func NewRateLimiter(host string, limits ...RateLimit) *RateLimiter {
+	buckets := make([]*bucket, 0, len(limits))
+	for _, limit := range limits {
+		if limit.Requests > 0 && limit.Per > 0 {
+			buckets = append(buckets, &bucket{limit: limit})
+		}
+	}
+
+	return &RateLimiter{host: host, buckets: buckets}
+}
+
+// Wait blocks until the request may be sent without exceeding any of
+// the limits that apply to it.  Requests to other hosts never wait.
+// Requests that are canceled while waiting give up their slot, unless
+// later requests have already been scheduled after it.
+func (l *RateLimiter) Wait(req *http.Request) error {
+	matched := l.match(req)
+	if len(matched) == 0 {
+		return nil
+	}
+
+	l.mutex.Lock()
+	now := time.Now()
+	at := now
+	for _, b := range matched {
+		at = latest(at, b.next, b.pausedUntil)
+	}
+	previous := make([]time.Time, len(matched))
+	reserved := make([]time.Time, len(matched))
+	for i, b := range matched {
+		previous[i] = b.next
+		reserved[i] = at.Add(b.limit.Per / time.Duration(b.limit.Requests))
+		b.next = reserved[i]
+	}
+	l.mutex.Unlock()
+
+	if err := sleep(req.Context(), at.Sub(now)); err != nil {
+		l.mutex.Lock()
+		defer l.mutex.Unlock()
+		for i, b := range matched {
+			if b.next.Equal(reserved[i]) {
+				b.next = previous[i]
+			}
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Observe pauses the endpoint of the response until its limit resets,
+// if the response was rate limited or the server reports that no
+// requests remain.  Only the most specific limit of the endpoint is
+// paused, as the server does not report which limit was exhausted.
+func (l *RateLimiter) Observe(resp *http.Response) {
+	if resp.Request == nil {
+		return
+	}
+	remaining := resp.Header.Get("X-RateLimit-Remaining")
+	if resp.StatusCode != http.StatusTooManyRequests && remaining != "0" {
+		return
+	}
+	until, ok := RetryAfter(resp)
+	if !ok {
+		return
+	}
+	matched := l.match(resp.Request)
+	if len(matched) == 0 {
+		return
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	b := matched[len(matched)-1]
+	b.pausedUntil = latest(b.pausedUntil, until)
+}
+
+// Transport wraps the given transport, or the default transport if
+// nil, to wait for the limiter before every request and to observe
+// every response.
+func (l *RateLimiter) Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return &limitedTransport{next: next, limiter: l}
+}
+
+// match returns the buckets that apply to the request, ordered from the
+// least to the most specific.
+func (l *RateLimiter) match(req *http.Request) []*bucket {
+	if req.URL == nil || !strings.EqualFold(req.URL.Host, l.host) {
+		return nil
+	}
+
+	matched := make([]*bucket, 0, len(l.buckets))
+	for _, b := range l.buckets {
+		if strings.HasPrefix(req.URL.Path, b.limit.Prefix) {
+			matched = append(matched, b)
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		return len(matched[i].limit.Prefix) < len(matched[j].limit.Prefix)
+	})
+
+	return matched
+}
+
+type limitedTransport struct {
+	next    http.RoundTripper
+	limiter *RateLimiter
+}
+
+func (t *limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.limiter.Wait(req); err != nil {
+		return nil, err
+	}
+	resp, err := t.next.RoundTrip(req)
+	if err == nil {
+		t.limiter.Observe(resp)
+	}
+
+	return resp, err
+}
+
+// RetryAfter returns when the server allows requests again, using the
+// X-RateLimit-Retry-After header sent by MangaDex, which is a Unix
+// timestamp, or the standard Retry-After header in seconds or as a
+// date.
+func RetryAfter(resp *http.Response) (time.Time, bool) {
+	if header := resp.Header.Get("X-RateLimit-Retry-After"); header != "" {
+		if seconds, err := strconv.ParseInt(header, 10, 64); err == nil {
+			return time.Unix(seconds, 0), true
+		}
+	}
+	if header := resp.Header.Get("Retry-After"); header != "" {
+		if seconds, err := strconv.ParseInt(header, 10, 64); err == nil {
+			return time.Now().Add(time.Duration(seconds) * time.Second), true
+		} else if date, err := http.ParseTime(header); err == nil {
+			return date, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+func latest(t time.Time, others ...time.Time) time.Time {
+	for _, other := range others {
+		if other.After(t) {
+			t = other
+		}
+	}
+
+	return t
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
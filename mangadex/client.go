@@ -7,24 +7,64 @@ import (
 	"net/url"
 
 	"github.com/leotaku/kojirou/mangadex/api"
+	"golang.org/x/text/language"
 )
 
 var CoverBaseURL, _ = url.Parse("https://uploads.mangadex.org/covers/")
 
+// batchLimit is the maximum number of results of a single request.
+const batchLimit = 100
+
+// contentRatings are all content ratings, as lists of manga otherwise
+// exclude pornographic manga.
+var contentRatings = []string{"safe", "suggestive", "erotica", "pornographic"}
+
+type (
+	Token       = api.Token
+	Credentials = api.Credentials
+)
+
 type Client struct {
 	base         *api.Client
+	http         *http.Client
 	coverBaseURL url.URL
+	dataSaver    bool
 }
 
 func NewClient() *Client {
 	return &Client{
 		base:         api.NewClient(),
+		http:         http.DefaultClient,
 		coverBaseURL: *CoverBaseURL,
 	}
 }
 
+// WithHTTPClient makes requests using the given client, which should
+// respect rate limits, see api.Client.WithHTTPClient.
 func (c *Client) WithHTTPClient(http *http.Client) *Client {
 	c.base.WithHTTPClient(http)
+	c.http = http
+	return c
+}
+
+// WithDataSaver makes FetchPaths return the compressed pages of the
+// data-saver mode, which are much smaller than the original pages.
+func (c *Client) WithDataSaver(enabled bool) *Client {
+	c.dataSaver = enabled
+	return c
+}
+
+// Login requests a token for the account of a personal API client,
+// which can be used with WithSession.
+func (c *Client) Login(ctx context.Context, creds Credentials) (*Token, error) {
+	return c.base.Login(ctx, creds)
+}
+
+// WithSession authenticates all requests using the given token, which
+// is refreshed transparently once it expires.  Refreshed tokens are
+// passed to the save function, if not nil.
+func (c *Client) WithSession(clientID, clientSecret string, token *Token, save func(*Token) error) *Client {
+	c.base.WithTokenSource(api.NewSession(c.base, clientID, clientSecret, token, save))
 	return c
 }
 
@@ -35,82 +75,132 @@ func (c *Client) FetchLegacy(ctx context.Context, tp string, legacyID int) (stri
 	}
 
 	if len(mapping.Data) != 1 {
-		return "", fmt.Errorf("%v not found: %v", tp, legacyID)
+		return "", fmt.Errorf("%v %w: %v", tp, ErrNotFound, legacyID)
 	}
 
 	return mapping.Data[0].Attributes.NewID, nil
 }
 
-func (c *Client) FetchManga(ctx context.Context, mangaID string) (*Manga, error) {
-	base, err := c.base.GetManga(ctx, mangaID)
-	if err != nil {
-		return nil, fmt.Errorf("get manga: %w", err)
-	}
+func (c *Client) FetchLinked(ctx context.Context, site, siteID string, titles ...string) (string, error) {
+	for _, title := range titles {
+		list, err := c.base.GetMangaList(ctx, api.QueryArgs{
+			Title: title,
+			Limit: 100,
+		})
+		if err != nil {
+			return "", fmt.Errorf("get manga: %w", err)
+		}
 
-	// Only retrieves at most 100 authors
-	authors, err := c.base.GetAuthors(ctx, api.QueryArgs{
-		IDs:   base.Data.Relationships.Author,
-		Limit: 100,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("get authors: %w", err)
+		for _, manga := range list.Data {
+			if manga.Attributes.Links[site] == siteID {
+				return manga.ID, nil
+			}
+		}
 	}
 
-	// Only retrieves at most 100 artists
-	artists, err := c.base.GetAuthors(ctx, api.QueryArgs{
-		IDs:   base.Data.Relationships.Artist,
-		Limit: 100,
-	})
+	return "", fmt.Errorf("%v %w: %v", site, ErrNotFound, siteID)
+}
+
+// FetchManga returns the skeleton of a manga.  Authors and artists
+// are included in the same request.
+func (c *Client) FetchManga(ctx context.Context, mangaID string) (*Manga, error) {
+	base, err := c.base.GetManga(ctx, mangaID, "author", "artist")
 	if err != nil {
-		return nil, fmt.Errorf("get artists: %w", err)
+		return nil, fmt.Errorf("get manga: %w", err)
 	}
 
 	return &Manga{
-		Info:    convertManga(base, authors, artists),
+		Info:    convertManga(base.Data),
 		Volumes: make(map[Identifier]Volume),
 	}, nil
 }
 
-func (c *Client) FetchChapters(ctx context.Context, mangaID string) (ChapterList, error) {
-	chapters := make([]api.ChapterData, 0)
-
-	limit := 500
-	for offset := 0; ; offset += limit {
-		feed, err := c.base.GetFeed(ctx, mangaID, api.QueryArgs{
-			Limit:         limit,
-			Offset:        offset,
-			Order:         map[string]string{"updatedAt": "asc"},
-			EmptyPages:    "0",
-			FuturePublish: "0",
-			ExternalURL:   "0",
+// FetchMangas returns the skeletons of many manga using one request per
+// hundred manga.  Manga that could not be found are missing from the
+// result.
+func (c *Client) FetchMangas(ctx context.Context, mangaIDs ...string) (map[string]*Manga, error) {
+	result := make(map[string]*Manga)
+	for _, ids := range chunks(mangaIDs, batchLimit) {
+		list, err := c.base.GetMangaList(ctx, api.QueryArgs{
+			IDs:           ids,
+			ContentRating: contentRatings,
+			Includes:      []string{"author", "artist"},
+			Limit:         batchLimit,
 		})
 		if err != nil {
-			return nil, fmt.Errorf("get chapters: %w", err)
-		} else {
-			chapters = append(chapters, feed.Data...)
+			return nil, fmt.Errorf("get manga: %w", err)
+		}
+		for _, data := range list.Data {
+			result[data.ID] = &Manga{
+				Info:    convertManga(data),
+				Volumes: make(map[Identifier]Volume),
+			}
 		}
+	}
 
-		if offset+limit >= feed.Total {
-			break
+	return result, nil
+}
+
+func (c *Client) FetchChapters(ctx context.Context, mangaID string) (ChapterList, error) {
+	result := make(ChapterList, 0)
+	var streamErr error
+	c.StreamChapters(ctx, mangaID)(func(chapter Chapter, err error) bool {
+		if err != nil {
+			streamErr = err
+			return false
 		}
+		result = append(result, chapter)
+		return true
+	})
+	if streamErr != nil {
+		return nil, streamErr
 	}
 
-	groupMap, err := c.fetchGroupMap(ctx, chapters)
+	reverse(result)
+	return result, nil
+}
+
+func (c *Client) FetchCovers(ctx context.Context, mangaID string, locales ...language.Tag) (PathList, error) {
+	covers, err := c.fetchCovers(ctx, []string{mangaID}, locales...)
 	if err != nil {
-		return nil, fmt.Errorf("get groups: %w", err)
+		return nil, err
+	}
+
+	return convertCovers(c.coverBaseURL.String(), mangaID, covers), nil
+}
+
+// FetchCoversOf returns the covers of many manga, requesting the covers
+// of up to a hundred manga at once.
+func (c *Client) FetchCoversOf(ctx context.Context, mangaIDs ...string) (map[string]PathList, error) {
+	byManga := make(map[string][]api.CoverData)
+	for _, ids := range chunks(mangaIDs, batchLimit) {
+		covers, err := c.fetchCovers(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		for _, cover := range covers {
+			for _, id := range cover.Relationships.Manga {
+				byManga[id] = append(byManga[id], cover)
+			}
+		}
 	}
 
-	return convertChapters(chapters, groupMap), nil
+	result := make(map[string]PathList)
+	for _, id := range mangaIDs {
+		result[id] = convertCovers(c.coverBaseURL.String(), id, byManga[id])
+	}
+
+	return result, nil
 }
 
-func (c *Client) FetchCovers(ctx context.Context, mangaID string) (PathList, error) {
+func (c *Client) fetchCovers(ctx context.Context, mangaIDs []string, locales ...language.Tag) ([]api.CoverData, error) {
 	covers := make([]api.CoverData, 0)
-	limit := 100
-	for offset := 0; ; offset += limit {
+	for offset := 0; ; offset += batchLimit {
 		feed, err := c.base.GetCovers(ctx, api.QueryArgs{
-			Mangas: []string{mangaID},
-			Limit:  limit,
-			Offset: offset,
+			Mangas:  mangaIDs,
+			Locales: locales,
+			Limit:   batchLimit,
+			Offset:  offset,
 		})
 		if err != nil {
 			return nil, fmt.Errorf("get covers: %w", err)
@@ -118,12 +208,36 @@ func (c *Client) FetchCovers(ctx context.Context, mangaID string) (PathList, err
 			covers = append(covers, feed.Data...)
 		}
 
-		if offset+limit >= feed.Total {
+		if offset+batchLimit >= feed.Total {
 			break
 		}
 	}
 
-	return convertCovers(c.coverBaseURL.String(), mangaID, covers), nil
+	return covers, nil
+}
+
+// FetchFollows returns the IDs of all manga followed by the logged in
+// user, see WithSession.
+func (c *Client) FetchFollows(ctx context.Context) ([]string, error) {
+	ids := make([]string, 0)
+	for offset := 0; ; offset += batchLimit {
+		list, err := c.base.GetFollowedMangaList(ctx, api.QueryArgs{
+			Limit:  batchLimit,
+			Offset: offset,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("get follows: %w", err)
+		}
+		for _, data := range list.Data {
+			ids = append(ids, data.ID)
+		}
+
+		if offset+batchLimit >= list.Total {
+			break
+		}
+	}
+
+	return ids, nil
 }
 
 func (c *Client) FetchPaths(ctx context.Context, chapter *Chapter) (PathList, error) {
@@ -132,42 +246,31 @@ func (c *Client) FetchPaths(ctx context.Context, chapter *Chapter) (PathList, er
 		return nil, fmt.Errorf("get at home: %w", err)
 	}
 
-	return convertChapter(chapter, ah), nil
+	return convertChapter(chapter, ah, c.dataSaver), nil
 }
 
-func (c *Client) fetchGroupMap(ctx context.Context, chapters []api.ChapterData) (map[string]api.GroupData, error) {
-	dedup := make(map[string]struct{})
-	groupIDs := make([]string, 0)
-	for _, chap := range chapters {
-		for _, id := range chap.Relationships.Group {
-			if _, ok := dedup[id]; !ok {
-				groupIDs = append(groupIDs, id)
-				dedup[id] = struct{}{}
-			}
-		}
+// FetchBaseURL returns the MangaDex@Home server currently assigned to
+// the chapter, which changes when servers become unavailable.
+func (c *Client) FetchBaseURL(ctx context.Context, chapterID string) (string, error) {
+	ah, err := c.base.GetAtHome(ctx, chapterID)
+	if err != nil {
+		return "", fmt.Errorf("get at home: %w", err)
 	}
 
-	result := make(map[string]api.GroupData)
-	limit := 100
-	for offset := 0; offset < len(groupIDs); offset += limit {
-		// Always send at most `limit` IDs
-		end := len(groupIDs)
-		if end > offset+limit {
-			end = offset + limit
-		}
+	return ah.BaseURL, nil
+}
 
-		gs, err := c.base.GetGroups(ctx, api.QueryArgs{
-			IDs:   groupIDs[offset:end],
-			Limit: limit,
-		})
-		if err != nil {
-			return nil, err
-		} else {
-			for _, group := range gs.Data {
-				result[group.ID] = group
-			}
+// chunks splits the IDs into parts of at most the given size, as the
+// API limits the number of IDs and results per request.
+func chunks(ids []string, size int) [][]string {
+	result := make([][]string, 0)
+	for offset := 0; offset < len(ids); offset += size {
+		end := len(ids)
+		if end > offset+size {
+			end = offset + size
 		}
+		result = append(result, ids[offset:end])
 	}
 
-	return result, nil
+	return result
 }
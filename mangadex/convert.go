@@ -9,51 +9,70 @@ import (
 	"golang.org/x/text/language"
 )
 
-func convertManga(b *api.Manga, authors, artists *api.AuthorList) MangaInfo {
+func convertManga(b api.MangaData) MangaInfo {
 	authorNames := make([]string, 0)
-	for _, a := range authors.Data {
-		authorNames = append(authorNames, a.Attributes.Name)
+	for _, id := range b.Relationships.Author {
+		if name := b.Relationships.Name(id); name != "" {
+			authorNames = append(authorNames, name)
+		}
 	}
 
 	artistNames := make([]string, 0)
-	for _, a := range artists.Data {
-		artistNames = append(artistNames, a.Attributes.Name)
+	for _, id := range b.Relationships.Artist {
+		if name := b.Relationships.Name(id); name != "" {
+			artistNames = append(artistNames, name)
+		}
+	}
+
+	altTitles := make([]string, 0)
+	for _, t := range b.Attributes.AltTitles {
+		for _, title := range t {
+			altTitles = append(altTitles, title)
+		}
+	}
+
+	tagNames := make([]string, 0)
+	for _, t := range b.Attributes.Tags {
+		tagNames = append(tagNames, preferred(t.Attributes.Name, "en"))
 	}
 
 	return MangaInfo{
-		Title:   first(b.Data.Attributes.Title),
-		Authors: authorNames,
-		Artists: artistNames,
-		ID:      b.Data.ID,
+		Title:       first(b.Attributes.Title),
+		AltTitles:   altTitles,
+		Authors:     authorNames,
+		Artists:     artistNames,
+		Description: preferred(b.Attributes.Description, "en"),
+		Tags:        tagNames,
+		Status:      b.Attributes.Status,
+		Year:        b.Attributes.Year,
+		Languages:   b.Attributes.AvailableTranslatedLanguages,
+		Links:       b.Attributes.Links,
+		ID:          b.ID,
 	}
 }
 
-func convertChapters(ca []api.ChapterData, groupMap map[string]api.GroupData) ChapterList {
-	sorted := make(ChapterList, 0)
-	for _, info := range ca {
-		lang, _ := language.Parse(info.Attributes.TranslatedLanguage)
-		groups := make([]string, 0)
-		for _, id := range info.Relationships.Group {
-			groups = append(groups, groupMap[id].Attributes.Name)
-		}
-
-		sorted = append(sorted, Chapter{
-			Info: ChapterInfo{
-				Title:            info.Attributes.Title,
-				Language:         lang,
-				Views:            0, // FIXME
-				GroupNames:       groups,
-				Published:        info.Attributes.PublishAt,
-				ID:               info.ID,
-				Identifier:       NewWithFallback(info.Attributes.Chapter, info.Attributes.Title),
-				VolumeIdentifier: NewWithFallback(info.Attributes.Volume, "Special"),
-			},
-			Pages: make(map[int]image.Image),
-		})
+func convertChapterData(info api.ChapterData) Chapter {
+	lang, _ := language.Parse(info.Attributes.TranslatedLanguage)
+	groups := make([]string, 0)
+	for _, id := range info.Relationships.Group {
+		groups = append(groups, info.Relationships.Name(id))
 	}
 
-	reverse(sorted)
-	return sorted
+	return Chapter{
+		Info: ChapterInfo{
+			Title:            info.Attributes.Title,
+			Language:         lang,
+			Views:            0, // FIXME
+			GroupNames:       groups,
+			Pages:            info.Attributes.Pages,
+			Published:        info.Attributes.PublishAt,
+			Readable:         info.Attributes.ReadableAt,
+			ID:               info.ID,
+			Identifier:       NewWithFallback(info.Attributes.Chapter, info.Attributes.Title),
+			VolumeIdentifier: NewWithFallback(info.Attributes.Volume, "Special"),
+		},
+		Pages: make(map[int]image.Image),
+	}
 }
 
 func convertCovers(coverBaseURL string, mangaID string, co []api.CoverData) PathList {
@@ -71,12 +90,18 @@ func convertCovers(coverBaseURL string, mangaID string, co []api.CoverData) Path
 	return result
 }
 
-func convertChapter(ch *Chapter, ah *api.AtHome) PathList {
+func convertChapter(ch *Chapter, ah *api.AtHome, dataSaver bool) PathList {
+	quality, filenames := "data", ah.Chapter.Data
+	if dataSaver {
+		quality, filenames = "data-saver", ah.Chapter.DataSaver
+	}
+
 	result := make(PathList, 0)
-	for i, filename := range ah.Chapter.Data {
-		url := strings.Join([]string{ah.BaseURL, "data", ah.Chapter.Hash, filename}, "/")
+	for i, filename := range filenames {
+		url := strings.Join([]string{ah.BaseURL, quality, ah.Chapter.Hash, filename}, "/")
 		result = append(result, Path{
 			URL:               url,
+			ChapterID:         ch.Info.ID,
 			ImageIdentifier:   i,
 			ChapterIdentifier: ch.Info.Identifier,
 			VolumeIdentifier:  ch.Info.VolumeIdentifier,
@@ -109,6 +134,17 @@ func reverse(v interface{}) {
 	}
 }
 
+func preferred(m map[string]string, lang string) string {
+	if val, ok := m[lang]; ok {
+		return val
+	}
+	for _, val := range m {
+		return val
+	}
+
+	return ""
+}
+
 func first(m map[string]string) string {
 	for _, val := range m {
 		return val
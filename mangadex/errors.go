@@ -0,0 +1,45 @@
+package mangadex
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/leotaku/kojirou/mangadex/api"
+)
+
+// Errors returned by the client and the download pipeline wrap these
+// values, so callers can inspect them using errors.Is.
+var (
+	ErrNotFound           = api.ErrNotFound
+	ErrRateLimited        = api.ErrRateLimited
+	ErrUnauthorized       = api.ErrUnauthorized
+	ErrDecodeFailed       = api.ErrDecode
+	ErrChapterUnavailable = errors.New("chapter unavailable")
+	ErrPagesMissing       = errors.New("pages missing")
+	ErrCorrupted          = errors.New("download corrupted")
+)
+
+type (
+	StatusError = api.StatusError
+	DecodeError = api.DecodeError
+)
+
+// ChapterUnavailableError is returned when the pages of a chapter
+// cannot be retrieved.  It matches ErrChapterUnavailable using
+// errors.Is.
+type ChapterUnavailableError struct {
+	Chapter Identifier
+	Err     error
+}
+
+func (e *ChapterUnavailableError) Error() string {
+	return fmt.Sprintf("chapter %v: %v", e.Chapter, e.Err)
+}
+
+func (e *ChapterUnavailableError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ChapterUnavailableError) Is(target error) bool {
+	return target == ErrChapterUnavailable
+}
@@ -7,10 +7,17 @@ import (
 	"strings"
 )
 
+// Identifier is the number of a volume or chapter, such as "10" or
+// "10.5", or a special name, such as "Oneshot".  Numbers are ordered
+// numerically, so "10.05" < "10.1" < "10.5" < "100.1", and special
+// identifiers follow all numbers in natural order, so "Extra 2" comes
+// before "Extra 10".
 type Identifier struct {
-	special  bool
-	before   int
-	after    int
+	special bool
+	before  int
+	// after holds the digits after the decimal point without trailing
+	// zeros, so that equal numbers are equal values.
+	after    string
 	fallback string
 }
 
@@ -49,18 +56,29 @@ func (n Identifier) String() string {
 	return n.StringFilled(0, 0, false)
 }
 
+// StringFilled formats numbers with at least the given number of digits
+// before and after the decimal point, padding with zeros that do not
+// change the value, so that "10.5" becomes "0010.50" and "10.05" becomes
+// "0010.05" for four and two digits.
 func (n Identifier) StringFilled(before, after int, forceAfter bool) string {
 	switch {
 	case n.IsUnknown():
 		return "Unknown"
 	case n.IsSpecial():
 		return n.fallback
-	case n.after == 0 && !forceAfter:
+	case n.after == "" && !forceAfter:
 		f := fmt.Sprintf("%%0%dd", before)
 		return fmt.Sprintf(f, n.before)
 	default:
-		f := fmt.Sprintf("%%0%dd.%%0%dd", before, after)
-		return fmt.Sprintf(f, n.before, n.after)
+		digits := n.after
+		if digits == "" {
+			digits = "0"
+		}
+		if len(digits) < after {
+			digits += strings.Repeat("0", after-len(digits))
+		}
+		f := fmt.Sprintf("%%0%dd.%%v", before)
+		return fmt.Sprintf(f, n.before, digits)
 	}
 }
 
@@ -84,7 +102,7 @@ func (n Identifier) Less(o Identifier) bool {
 	case !n.IsSpecial() && o.IsSpecial():
 		return true
 	case n.IsSpecial() && o.IsSpecial():
-		return n.fallback < o.fallback
+		return NaturalLess(n.fallback, o.fallback)
 	case n.before == o.before:
 		return n.after < o.after
 	default:
@@ -104,13 +122,19 @@ func (n Identifier) IsUnknown() bool {
 	return n.IsSpecial() && len(n.fallback) == 0
 }
 
+// Whole returns the part of a number before the decimal point, which is
+// zero for special identifiers.
+func (n Identifier) Whole() int {
+	return n.before
+}
+
 func (n Identifier) IsNext(o Identifier) bool {
 	switch {
 	case n.IsSpecial() || o.IsSpecial():
 		return true
 	case n.before == o.before && n.after < o.after:
 		return true
-	case n.before+1 == o.before && o.after == 0:
+	case n.before+1 == o.before && o.after == "":
 		return true
 	default:
 		return false
@@ -139,25 +163,68 @@ func (n *Identifier) UnmarshalJSON(data []byte) error {
 	return n.UnmarshalText([]byte(text))
 }
 
-func parseTwoPart(s string) (before, after int, ok bool) {
-	split := strings.Split(s, ".")
+// parseTwoPart parses a decimal number, returning the digits after the
+// decimal point without trailing zeros.
+func parseTwoPart(s string) (before int, after string, ok bool) {
+	split := strings.Split(strings.TrimSpace(s), ".")
 	if len(split) == 0 || len(split) > 2 {
-		return 0, 0, false
+		return 0, "", false
 	} else if len(split) == 1 {
 		split = append(split, "0")
 	}
 
 	if parsed, err := strconv.ParseUint(split[0], 10, 0); err != nil {
-		return 0, 0, false
+		return 0, "", false
 	} else {
 		before = int(parsed)
 	}
 
-	if parsed, err := strconv.ParseUint(split[1], 10, 0); err != nil {
-		return 0, 0, false
+	if _, err := strconv.ParseUint(split[1], 10, 0); err != nil {
+		return 0, "", false
 	} else {
-		after = int(parsed)
+		after = strings.TrimRight(split[1], "0")
 	}
 
 	return before, after, true
 }
+
+// NaturalLess compares runs of digits numerically and all other text
+// lexically, falling back to a lexical comparison of the whole strings
+// for a consistent order.  It orders identifiers that are not numbers,
+// as well as the names of pages in archives.
+func NaturalLess(a, b string) bool {
+	as, bs := splitDigits(a), splitDigits(b)
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aerr := strconv.Atoi(as[i])
+		bn, berr := strconv.Atoi(bs[i])
+		switch {
+		case aerr == nil && berr == nil && an != bn:
+			return an < bn
+		case (aerr != nil || berr != nil) && as[i] != bs[i]:
+			return as[i] < bs[i]
+		}
+	}
+	if len(as) != len(bs) {
+		return len(as) < len(bs)
+	}
+
+	return a < b
+}
+
+// splitDigits splits the string into runs of digits and other text.
+func splitDigits(s string) []string {
+	result := make([]string, 0)
+	start := 0
+	for i := 1; i <= len(s); i++ {
+		if i == len(s) || isDigit(s[i]) != isDigit(s[i-1]) {
+			result = append(result, s[start:i])
+			start = i
+		}
+	}
+
+	return result
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,77 @@
+package mangadex
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestIdentifierLess(t *testing.T) {
+	ordered := []string{"1", "10", "10.05", "10.1", "10.5", "11", "100", "100.1", "Extra 2", "Extra 10", "Oneshot"}
+	for i := range ordered {
+		for j := range ordered {
+			a, b := NewIdentifier(ordered[i]), NewIdentifier(ordered[j])
+			if got, want := a.Less(b), i < j; got != want {
+				t.Errorf("%v < %v = %v, want %v", ordered[i], ordered[j], got, want)
+			}
+		}
+	}
+
+	shuffled := []string{"Oneshot", "100.1", "10.5", "Extra 10", "10.05", "1", "Extra 2", "11", "10.1", "100", "10"}
+	identifiers := make([]Identifier, 0)
+	for _, s := range shuffled {
+		identifiers = append(identifiers, NewIdentifier(s))
+	}
+	sort.Slice(identifiers, func(i, j int) bool {
+		return identifiers[i].Less(identifiers[j])
+	})
+	for i, identifier := range identifiers {
+		if identifier.String() != ordered[i] {
+			t.Errorf("position %v: got %v, want %v", i, identifier, ordered[i])
+		}
+	}
+}
+
+func TestIdentifierEqual(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		equal bool
+	}{
+		{"10.5", "10.50", true},
+		{"10.5", "10.05", false},
+		{"10", "10.0", true},
+		{"100.1", "10.01", false},
+		{"Oneshot", "Oneshot", true},
+		{"Oneshot", "Extra", false},
+	}
+	for _, test := range tests {
+		if got := NewIdentifier(test.a).Equal(NewIdentifier(test.b)); got != test.equal {
+			t.Errorf("%v == %v = %v, want %v", test.a, test.b, got, test.equal)
+		}
+	}
+}
+
+func TestIdentifierStringFilled(t *testing.T) {
+	tests := []struct {
+		id   string
+		want string
+	}{
+		{"10", "0010"},
+		{"10.5", "0010.50"},
+		{"10.05", "0010.05"},
+		{"100.1", "0100.10"},
+		{"10.125", "0010.125"},
+		{"Oneshot", "Oneshot"},
+		{"Unknown", "Unknown"},
+	}
+	seen := make(map[string]string)
+	for _, test := range tests {
+		got := NewIdentifier(test.id).StringFilled(4, 2, false)
+		if got != test.want {
+			t.Errorf("%v: got %v, want %v", test.id, got, test.want)
+		}
+		if other, ok := seen[got]; ok {
+			t.Errorf("%v and %v are both written as %v", other, test.id, got)
+		}
+		seen[got] = test.id
+	}
+}
@@ -0,0 +1,108 @@
+package mangadex
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"net/http"
+
+	"github.com/leotaku/kojirou/codec"
+	"github.com/leotaku/kojirou/mangadex/api"
+)
+
+// ChapterSeq yields chapters as they are received.  It has the same
+// type as iter.Seq2[Chapter, error] and stops after yielding an error.
+type ChapterSeq func(yield func(Chapter, error) bool)
+
+// ImageSeq yields images as they are downloaded.  It has the same type
+// as iter.Seq2[Image, error] and stops after yielding an error.
+type ImageSeq func(yield func(Image, error) bool)
+
+// StreamChapters yields the chapters of a manga in the order they were
+// last updated, without waiting for the complete feed.  Groups are
+// included in the feed, so no further requests are needed.
+func (c *Client) StreamChapters(ctx context.Context, mangaID string) ChapterSeq {
+	return func(yield func(Chapter, error) bool) {
+		limit := 500
+		for offset := 0; ; offset += limit {
+			feed, err := c.base.GetFeed(ctx, mangaID, api.QueryArgs{
+				Limit:         limit,
+				Offset:        offset,
+				Order:         map[string]string{"updatedAt": "asc"},
+				EmptyPages:    "0",
+				FuturePublish: "0",
+				ExternalURL:   "0",
+				Includes:      []string{"scanlation_group"},
+			})
+			if err != nil {
+				yield(Chapter{}, fmt.Errorf("get chapters: %w", err))
+				return
+			}
+
+			for _, info := range feed.Data {
+				if !yield(convertChapterData(info), nil) {
+					return
+				}
+			}
+			if offset+limit >= feed.Total {
+				return
+			}
+		}
+	}
+}
+
+// StreamImages yields the pages of the given chapters in order, one
+// page at a time.  Pages are fetched once using the HTTP client of the
+// client, without failing over to other MangaDex@Home nodes, fetching
+// corrupted pages again or using a cache, and the stream stops after
+// the first page that fails.  The downloader of the command provides
+// all of these through its StreamPages method instead.
+func (c *Client) StreamImages(ctx context.Context, chapters ChapterList) ImageSeq {
+	return func(yield func(Image, error) bool) {
+		for i := range chapters {
+			paths, err := c.FetchPaths(ctx, &chapters[i])
+			if err != nil {
+				yield(Image{}, &ChapterUnavailableError{Chapter: chapters[i].Info.Identifier, Err: err})
+				return
+			}
+			for _, path := range paths {
+				img, err := c.fetchImage(ctx, path.URL)
+				if err != nil {
+					err = fmt.Errorf("chapter %v: image %v: %w", path.ChapterIdentifier, path.ImageIdentifier, err)
+					yield(Image{}, err)
+					return
+				}
+				if !yield(path.WithImage(img), nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+func (c *Client) fetchImage(ctx context.Context, url string) (image.Image, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("prepare: %w", err)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("do: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read: %w", err)
+	}
+	img, err := codec.DecodeBytes(data)
+	if err != nil {
+		return nil, &DecodeError{Err: err}
+	}
+
+	return img, nil
+}
@@ -8,10 +8,29 @@ import (
 )
 
 type MangaInfo struct {
-	Title   string
-	Authors multiple
-	Artists multiple
-	ID      string
+	Title       string
+	Series      string
+	AltTitles   multiple
+	Authors     multiple
+	Artists     multiple
+	Publisher   string
+	Description string
+	Tags        multiple
+	Status      string
+	Year        int
+	Languages   multiple
+	Links       map[string]string
+	ID          string
+}
+
+// SeriesName returns the name of the series the manga belongs to, which
+// defaults to its title.
+func (i MangaInfo) SeriesName() string {
+	if i.Series != "" {
+		return i.Series
+	}
+
+	return i.Title
 }
 
 type VolumeInfo struct {
@@ -23,8 +42,11 @@ type ChapterInfo struct {
 	Views      int
 	Language   language.Tag
 	GroupNames multiple
+	Pages      int
 	Published  time.Time
+	Readable   time.Time
 	ID         string
+	Source     string
 
 	// identifiers
 	Identifier       Identifier
@@ -42,6 +64,9 @@ type Image struct {
 
 type Path struct {
 	URL string
+	// ChapterID is the MangaDex ID of the chapter of pages, which is
+	// needed to request another MangaDex@Home server for them.
+	ChapterID string
 
 	// identifiers
 	ImageIdentifier   int
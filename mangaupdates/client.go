@@ -0,0 +1,119 @@
+package mangaupdates
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+var APIBaseURL, _ = url.Parse(`https://api.mangaupdates.com/v1/`)
+
+type Client struct {
+	http    *http.Client
+	baseURL url.URL
+}
+
+func NewClient() *Client {
+	return &Client{
+		http:    http.DefaultClient,
+		baseURL: *APIBaseURL,
+	}
+}
+
+func (c *Client) WithHTTPClient(http *http.Client) *Client {
+	c.http = http
+	return c
+}
+
+func (c *Client) GetSeries(ctx context.Context, seriesID int64) (*Series, error) {
+	v := new(Series)
+	err := c.doJSON(ctx, "GET", fmt.Sprintf("series/%v", seriesID), v, nil)
+	return v, err
+}
+
+func (c *Client) SearchSeries(ctx context.Context, title string) (*SearchResults, error) {
+	v := new(SearchResults)
+	err := c.doJSON(ctx, "POST", "series/search", v, map[string]interface{}{
+		"search":  title,
+		"perpage": 25,
+	})
+	return v, err
+}
+
+// FetchSeries finds the series for a MangaDex "mu" link.  Current links
+// are base36 encoded series IDs, while legacy numeric links can only be
+// resolved by searching for the given title.
+func (c *Client) FetchSeries(ctx context.Context, link, title string) (*Series, error) {
+	if id, err := strconv.ParseInt(link, 36, 64); err == nil && !isNumeric(link) {
+		return c.GetSeries(ctx, id)
+	}
+
+	results, err := c.SearchSeries(ctx, title)
+	if err != nil {
+		return nil, fmt.Errorf("search: %w", err)
+	} else if len(results.Results) == 0 {
+		return nil, fmt.Errorf("not found: %v", title)
+	}
+
+	best := results.Results[0].Record
+	for _, result := range results.Results {
+		if strings.EqualFold(result.HitTitle, title) {
+			best = result.Record
+			break
+		}
+	}
+
+	return c.GetSeries(ctx, best.SeriesID)
+}
+
+func (c *Client) doJSON(ctx context.Context, method, ref string, result, body interface{}) error {
+	url, err := c.baseURL.Parse(ref)
+	if err != nil {
+		return fmt.Errorf("url: %w", err)
+	}
+
+	rw := io.ReadWriter(nil)
+	if body != nil {
+		rw = bytes.NewBuffer(nil)
+		if err := json.NewEncoder(rw).Encode(body); err != nil {
+			return fmt.Errorf("encode: %w", err)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url.String(), rw)
+	if err != nil {
+		return fmt.Errorf("prepare: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("do: %w", err)
+	}
+	defer resp.Body.Close()
+
+	dec := json.NewDecoder(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		e := new(Error)
+		if err := dec.Decode(e); err != nil || e.Reason == "" {
+			return fmt.Errorf("status: %v", resp.Status)
+		} else {
+			return fmt.Errorf("detail: %v", e.Reason)
+		}
+	} else if err := dec.Decode(result); err != nil {
+		return fmt.Errorf("decode: %w", err)
+	}
+
+	return nil
+}
+
+func isNumeric(s string) bool {
+	_, err := strconv.ParseInt(s, 10, 64)
+	return err == nil
+}
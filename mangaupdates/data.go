@@ -0,0 +1,69 @@
+package mangaupdates
+
+import (
+	"regexp"
+	"strconv"
+)
+
+var volumesPattern = regexp.MustCompile(`([0-9]+) Volumes?`)
+
+type Series struct {
+	SeriesID      int64  `json:"series_id"`
+	Title         string `json:"title"`
+	URL           string `json:"url"`
+	Type          string `json:"type"`
+	Year          string `json:"year"`
+	Status        string `json:"status"`
+	Licensed      bool   `json:"licensed"`
+	Completed     bool   `json:"completed"`
+	LatestChapter int    `json:"latest_chapter"`
+	Publishers    []struct {
+		PublisherName string `json:"publisher_name"`
+		PublisherID   int64  `json:"publisher_id"`
+		Type          string `json:"type"`
+		Notes         string `json:"notes"`
+	} `json:"publishers"`
+}
+
+// Publisher returns the name of the first publisher of the given type,
+// which is either "Original" or "English".
+func (s Series) Publisher(tp string) string {
+	for _, p := range s.Publishers {
+		if p.Type == tp {
+			return p.PublisherName
+		}
+	}
+
+	return ""
+}
+
+// EnglishVolumes returns the number of volumes published in English, as
+// noted by MangaUpdates, or zero if the number is unknown.
+func (s Series) EnglishVolumes() int {
+	for _, p := range s.Publishers {
+		if p.Type != "English" {
+			continue
+		}
+		if m := volumesPattern.FindStringSubmatch(p.Notes); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			return n
+		}
+	}
+
+	return 0
+}
+
+type SearchResults struct {
+	TotalHits int `json:"total_hits"`
+	Page      int `json:"page"`
+	PerPage   int `json:"per_page"`
+	Results   []struct {
+		Record   Series `json:"record"`
+		HitTitle string `json:"hit_title"`
+	} `json:"results"`
+}
+
+type Error struct {
+	Status string `json:"status"`
+	Reason string `json:"reason"`
+}
@@ -0,0 +1,171 @@
+package myanimelist
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	APIBaseURL, _  = url.Parse(`https://api.myanimelist.net/v2/`)
+	AuthBaseURL, _ = url.Parse(`https://myanimelist.net/v1/oauth2/`)
+)
+
+type Client struct {
+	http     *http.Client
+	baseURL  url.URL
+	authURL  url.URL
+	clientID string
+	token    *Token
+}
+
+func NewClient(clientID string) *Client {
+	return &Client{
+		http:     http.DefaultClient,
+		baseURL:  *APIBaseURL,
+		authURL:  *AuthBaseURL,
+		clientID: clientID,
+	}
+}
+
+func (c *Client) WithHTTPClient(http *http.Client) *Client {
+	c.http = http
+	return c
+}
+
+func (c *Client) WithToken(token *Token) *Client {
+	c.token = token
+	return c
+}
+
+// AuthorizeURL returns the page users have to visit in order to grant
+// access to their list.  MyAnimeList only supports the "plain" PKCE
+// method, so the verifier is sent as the challenge unchanged.
+func (c *Client) AuthorizeURL(verifier, state string) string {
+	ref, _ := c.authURL.Parse("authorize")
+	ref.RawQuery = url.Values{
+		"response_type":         {"code"},
+		"client_id":             {c.clientID},
+		"code_challenge":        {verifier},
+		"code_challenge_method": {"plain"},
+		"state":                 {state},
+	}.Encode()
+
+	return ref.String()
+}
+
+func (c *Client) Exchange(ctx context.Context, code, verifier string) (*Token, error) {
+	return c.postToken(ctx, url.Values{
+		"client_id":     {c.clientID},
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"code_verifier": {verifier},
+	})
+}
+
+func (c *Client) Refresh(ctx context.Context, token *Token) (*Token, error) {
+	return c.postToken(ctx, url.Values{
+		"client_id":     {c.clientID},
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {token.RefreshToken},
+	})
+}
+
+func (c *Client) GetManga(ctx context.Context, mangaID int) (*Manga, error) {
+	v := new(Manga)
+	ref := fmt.Sprintf("manga/%v?fields=alternative_titles,num_volumes,num_chapters,my_list_status", mangaID)
+	err := c.do(ctx, "GET", ref, nil, v)
+	return v, err
+}
+
+func (c *Client) UpdateListStatus(ctx context.Context, mangaID int, status ListStatus) (*ListStatus, error) {
+	if c.token == nil {
+		return nil, fmt.Errorf("not authenticated")
+	}
+
+	form := make(url.Values)
+	if status.Status != "" {
+		form.Set("status", status.Status)
+	}
+	if status.NumVolumesRead != 0 {
+		form.Set("num_volumes_read", strconv.Itoa(status.NumVolumesRead))
+	}
+	if status.NumChaptersRead != 0 {
+		form.Set("num_chapters_read", strconv.Itoa(status.NumChaptersRead))
+	}
+
+	v := new(ListStatus)
+	ref := fmt.Sprintf("manga/%v/my_list_status", mangaID)
+	err := c.do(ctx, "PATCH", ref, strings.NewReader(form.Encode()), v)
+	return v, err
+}
+
+func (c *Client) postToken(ctx context.Context, form url.Values) (*Token, error) {
+	url, err := c.authURL.Parse("token")
+	if err != nil {
+		return nil, fmt.Errorf("url: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", url.String(), strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("prepare: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	v := new(Token)
+	if err := c.doRequest(req, v); err != nil {
+		return nil, err
+	}
+	v.Created = time.Now()
+
+	return v, nil
+}
+
+func (c *Client) do(ctx context.Context, method, ref string, body io.Reader, result interface{}) error {
+	url, err := c.baseURL.Parse(ref)
+	if err != nil {
+		return fmt.Errorf("url: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url.String(), body)
+	if err != nil {
+		return fmt.Errorf("prepare: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if c.token != nil {
+		req.Header.Set("Authorization", "Bearer "+c.token.AccessToken)
+	} else {
+		req.Header.Set("X-MAL-CLIENT-ID", c.clientID)
+	}
+
+	return c.doRequest(req, result)
+}
+
+func (c *Client) doRequest(req *http.Request, result interface{}) error {
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("do: %w", err)
+	}
+	defer resp.Body.Close()
+
+	dec := json.NewDecoder(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		e := new(Error)
+		if err := dec.Decode(e); err != nil || e.Error == "" {
+			return fmt.Errorf("status: %v", resp.Status)
+		} else {
+			return fmt.Errorf("detail: %v: %v", e.Error, e.Message)
+		}
+	} else if err := dec.Decode(result); err != nil {
+		return fmt.Errorf("decode: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package myanimelist
+
+import "time"
+
+type Manga struct {
+	ID                int    `json:"id"`
+	Title             string `json:"title"`
+	AlternativeTitles struct {
+		Synonyms []string `json:"synonyms"`
+		En       string   `json:"en"`
+		Ja       string   `json:"ja"`
+	} `json:"alternative_titles"`
+	NumVolumes   int         `json:"num_volumes"`
+	NumChapters  int         `json:"num_chapters"`
+	MyListStatus *ListStatus `json:"my_list_status"`
+}
+
+func (m Manga) Titles() []string {
+	result := []string{m.Title}
+	if m.AlternativeTitles.En != "" {
+		result = append(result, m.AlternativeTitles.En)
+	}
+	result = append(result, m.AlternativeTitles.Synonyms...)
+	if m.AlternativeTitles.Ja != "" {
+		result = append(result, m.AlternativeTitles.Ja)
+	}
+
+	return result
+}
+
+type ListStatus struct {
+	Status          string    `json:"status,omitempty"`
+	NumVolumesRead  int       `json:"num_volumes_read,omitempty"`
+	NumChaptersRead int       `json:"num_chapters_read,omitempty"`
+	UpdatedAt       time.Time `json:"updated_at,omitempty"`
+}
+
+type Token struct {
+	TokenType    string    `json:"token_type"`
+	ExpiresIn    int       `json:"expires_in"`
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token"`
+	Created      time.Time `json:"created"`
+}
+
+func (t Token) Expired() bool {
+	return time.Since(t.Created) > time.Duration(t.ExpiresIn)*time.Second
+}
+
+type Error struct {
+	Error   string `json:"error"`
+	Message string `json:"message"`
+}